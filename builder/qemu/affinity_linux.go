@@ -0,0 +1,34 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package qemu
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkHostCPUs checks the CPUs are allowed to the process, threads can't
+// be pinned to the others.
+func checkHostCPUs(cpus []int) error {
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		return fmt.Errorf("failed to get the allowed CPUs: %s", err)
+	}
+	for _, cpu := range cpus {
+		if !allowed.IsSet(cpu) {
+			return fmt.Errorf("CPU %d isn't available to the process", cpu)
+		}
+	}
+	return nil
+}
+
+// schedSetThreadAffinity pins the thread to the CPU.
+func schedSetThreadAffinity(tid, cpu int) error {
+	var set unix.CPUSet
+	set.Set(cpu)
+	return unix.SchedSetaffinity(tid, &set)
+}
@@ -0,0 +1,18 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !linux
+
+package qemu
+
+import (
+	"errors"
+)
+
+func checkHostCPUs(cpus []int) error {
+	return errors.New("vcpu pinning is only supported on Linux hosts")
+}
+
+func schedSetThreadAffinity(tid, cpu int) error {
+	return errors.New("vcpu pinning is only supported on Linux hosts")
+}
@@ -0,0 +1,133 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// guestArchitecture holds the defaults used to build images of a guest
+// architecture.
+type guestArchitecture struct {
+	// QemuBinary is the QEMU system emulator of the architecture.
+	QemuBinary string
+	// MachineType is the default machine type.
+	MachineType string
+	// TCGCPUModel and KVMCPUModel are the CPU models used when cpu_model is
+	// not set, depending on the accelerator. The default CPU model of QEMU
+	// is used when empty.
+	TCGCPUModel string
+	KVMCPUModel string
+	// EFIFirmwareCode and EFIFirmwareVars are the default UEFI firmware
+	// code and variables paths.
+	EFIFirmwareCode string
+	EFIFirmwareVars string
+	// RequireEFI enables efi_boot by default, for architectures with no
+	// other firmware able to boot an installer.
+	RequireEFI bool
+	// CDROMInterface is the default cdrom_interface, for machine types with
+	// no IDE controller.
+	CDROMInterface string
+	// CCW is set when virtio devices are attached to the channel subsystem
+	// instead of PCI.
+	CCW bool
+	// Devices are added to the VM, for machine types with no display or
+	// input devices to type the boot command with.
+	Devices []string
+	// GoArch is the GOARCH of the hosts able to run the architecture with
+	// KVM.
+	GoArch string
+}
+
+var guestArchitectures = map[string]guestArchitecture{
+	"x86_64": {
+		QemuBinary:      "qemu-system-x86_64",
+		MachineType:     "pc",
+		EFIFirmwareCode: "/usr/share/OVMF/OVMF_CODE.fd",
+		EFIFirmwareVars: "/usr/share/OVMF/OVMF_VARS.fd",
+		GoArch:          "amd64",
+	},
+	"aarch64": {
+		QemuBinary:      "qemu-system-aarch64",
+		MachineType:     "virt",
+		TCGCPUModel:     "max",
+		KVMCPUModel:     "host",
+		EFIFirmwareCode: "/usr/share/AAVMF/AAVMF_CODE.fd",
+		EFIFirmwareVars: "/usr/share/AAVMF/AAVMF_VARS.fd",
+		RequireEFI:      true,
+		CDROMInterface:  "virtio-scsi",
+		Devices:         []string{"virtio-gpu-pci", "qemu-xhci", "usb-kbd", "usb-tablet"},
+		GoArch:          "arm64",
+	},
+	"riscv64": {
+		QemuBinary:      "qemu-system-riscv64",
+		MachineType:     "virt",
+		TCGCPUModel:     "rv64",
+		KVMCPUModel:     "host",
+		EFIFirmwareCode: "/usr/share/qemu-efi-riscv64/RISCV_VIRT_CODE.fd",
+		EFIFirmwareVars: "/usr/share/qemu-efi-riscv64/RISCV_VIRT_VARS.fd",
+		CDROMInterface:  "virtio-scsi",
+		Devices:         []string{"virtio-gpu-pci", "qemu-xhci", "usb-kbd", "usb-tablet"},
+		GoArch:          "riscv64",
+	},
+	"ppc64le": {
+		QemuBinary:  "qemu-system-ppc64",
+		MachineType: "pseries",
+		TCGCPUModel: "power9",
+		KVMCPUModel: "host",
+		GoArch:      "ppc64le",
+	},
+	"s390x": {
+		QemuBinary:     "qemu-system-s390x",
+		MachineType:    "s390-ccw-virtio",
+		TCGCPUModel:    "max",
+		KVMCPUModel:    "host",
+		CDROMInterface: "virtio-scsi",
+		CCW:            true,
+		GoArch:         "s390x",
+	},
+}
+
+// guestArchitectureNames returns the supported architectures, for error
+// messages.
+func guestArchitectureNames() string {
+	names := make([]string, 0, len(guestArchitectures))
+	for name := range guestArchitectures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// native tells whether the host can run the architecture with KVM.
+func (a guestArchitecture) native() bool {
+	return a.GoArch == runtime.GOARCH
+}
+
+// cpuModel returns the default CPU model for the accelerator.
+func (a guestArchitecture) cpuModel(accelerator string) string {
+	if accelerator == "kvm" {
+		return a.KVMCPUModel
+	}
+	return a.TCGCPUModel
+}
+
+// virtioDevice returns the name of a virtio device for the bus of the
+// architecture.
+func (a guestArchitecture) virtioDevice(name string) string {
+	if a.CCW {
+		return name + "-ccw"
+	}
+	return name + "-pci"
+}
+
+// guestArchitecture returns the defaults of the configured architecture.
+func (c *Config) guestArchitecture() guestArchitecture {
+	if arch, ok := guestArchitectures[c.Architecture]; ok {
+		return arch
+	}
+	return guestArchitectures["x86_64"]
+}
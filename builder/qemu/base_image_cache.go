@@ -0,0 +1,99 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+)
+
+// literalChecksum matches the checksums given as a value, unlike the
+// checksum files or `none`.
+var literalChecksum = regexp.MustCompile(`^(md5|sha1|sha256|sha512):[0-9a-fA-F]+$`)
+
+// sourceChecksum identifies the source image by its checksum, or by the
+// SHA-256 of its content when the checksum is not given as a value.
+func sourceChecksum(sourcePath, checksum string) (string, error) {
+	if literalChecksum.MatchString(checksum) {
+		return strings.ToLower(checksum), nil
+	}
+	sum, err := fileSHA256(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + sum, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// baseImageCacheKey returns the key of the converted source image in the
+// cache.
+func baseImageCacheKey(sourcePath, checksum, format string, convertArgs []string) (string, error) {
+	source, err := sourceChecksum(sourcePath, checksum)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", source, format, strings.Join(convertArgs, " "))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedBaseImage returns the path of the source image converted in the
+// cache directory, converting it with convert when it's not cached yet.
+// Builds converting the same image wait for each other.
+func cachedBaseImage(cacheDir, key, format string, convert func(targetPath string) error) (string, bool, error) {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", false, err
+	}
+
+	path := filepath.Join(cacheDir, key+"."+format)
+	lock := filelock.New(path + ".lock")
+	if err := lock.Lock(); err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			log.Printf("cannot unlock lockfile %s: %v", path+".lock", err)
+		}
+	}()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, true, nil
+	}
+
+	// Convert to a temporary file, so an interrupted conversion is never
+	// used as a base image.
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	if err := convert(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return "", false, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", false, err
+	}
+	return path, false, nil
+}
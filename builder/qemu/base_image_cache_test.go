@@ -0,0 +1,95 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseImageCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.img")
+	if err := os.WriteFile(source, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := func(path, checksum, format string, args ...string) string {
+		k, err := baseImageCacheKey(path, checksum, format, args)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		return k
+	}
+
+	// The literal checksum identifies the source, which isn't read
+	literal := key(filepath.Join(dir, "missing"), "sha256:ABCD", "qcow2")
+	if literal != key(filepath.Join(dir, "missing"), "sha256:abcd", "qcow2") {
+		t.Fatal("the checksum should be case insensitive")
+	}
+	if literal == key(filepath.Join(dir, "missing"), "sha256:abcd", "raw") {
+		t.Fatal("the format should be part of the key")
+	}
+	if literal == key(filepath.Join(dir, "missing"), "sha256:abcd", "qcow2", "-o", "cluster_size=2M") {
+		t.Fatal("the convert arguments should be part of the key")
+	}
+
+	// Otherwise the content does
+	none := key(source, "none", "qcow2")
+	if none != key(source, "file:http://example.com/SHA256SUMS", "qcow2") {
+		t.Fatal("the content should identify the source without a literal checksum")
+	}
+	if err := os.WriteFile(source, []byte("other image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if none == key(source, "none", "qcow2") {
+		t.Fatal("the key should change with the content")
+	}
+
+	if _, err := baseImageCacheKey(filepath.Join(dir, "missing"), "none", "qcow2", nil); err == nil {
+		t.Fatal("should have error hashing a missing source")
+	}
+}
+
+func TestCachedBaseImage(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	conversions := 0
+	convert := func(targetPath string) error {
+		conversions++
+		return os.WriteFile(targetPath, []byte("converted"), 0644)
+	}
+
+	path, cached, err := cachedBaseImage(cacheDir, "key", "qcow2", convert)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if cached || path != filepath.Join(cacheDir, "key.qcow2") {
+		t.Fatalf("bad base image: %s, %t", path, cached)
+	}
+
+	path, cached, err = cachedBaseImage(cacheDir, "key", "qcow2", convert)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !cached || conversions != 1 {
+		t.Fatalf("the base image should be cached: %s, %d conversions", path, conversions)
+	}
+
+	// A failed conversion is not cached
+	_, _, err = cachedBaseImage(cacheDir, "failed", "qcow2", func(targetPath string) error {
+		os.WriteFile(targetPath, []byte("partial"), 0644)
+		return errors.New("convert failed")
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	for _, name := range []string{"failed.qcow2", "failed.qcow2.tmp"} {
+		if _, err := os.Stat(filepath.Join(cacheDir, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s should not exist: %v", name, err)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
@@ -36,7 +37,28 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, warnings, errs
 	}
 
-	return nil, warnings, nil
+	generatedData := make([]string, 0, len(b.config.PortForwards))
+	for _, pf := range b.config.PortForwards {
+		generatedData = append(generatedData, pf.generatedDataKey())
+	}
+
+	if b.config.HTTPDir != "" || len(b.config.HTTPContent) > 0 {
+		generatedData = append(generatedData, httpFetchedPathsKey)
+	}
+
+	if b.config.LayeredOutput {
+		generatedData = append(generatedData, layerParentKey, layerParentChecksumKey)
+	}
+
+	if len(b.config.SharedFolders) > 0 {
+		generatedData = append(generatedData, sharedFoldersTypeKey, sharedFolderTagsKey)
+	}
+
+	if b.config.VCPUPinning != "" {
+		generatedData = append(generatedData, vcpuPinningKey)
+	}
+
+	return generatedData, warnings, nil
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
@@ -46,6 +68,45 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, fmt.Errorf("Failed creating Qemu driver: %s", err)
 	}
 
+	commNIC := b.config.communicatorInterface()
+
+	// Steps running processes alongside the VM abort the build with the
+	// cause when these fail.
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+
+	customConnect := map[string]multistep.Step{
+		"qga": &stepConnectGuestAgent{
+			Timeout: b.config.CommConfig.QGATimeout,
+		},
+	}
+	if b.config.CommConfig.Comm.Type == "serial" {
+		customConnect["serial"] = &stepConnectSerial{
+			SocketPath:  b.config.CommConfig.SerialSocketPath,
+			Credentials: b.config.CommConfig.serialCredentials(),
+			Timeout:     b.config.CommConfig.SerialTimeout,
+		}
+	}
+	if b.config.CommConfig.usesVsock() {
+		customConnect["ssh"] = &stepConnectVsock{
+			Config:    &b.config.CommConfig.Comm,
+			SSHConfig: b.config.CommConfig.Comm.SSHConfigFunc(),
+		}
+	}
+
+	// Reproducible builds pretend to run at the source date
+	var sourceDate time.Time
+	if b.config.Reproducible {
+		sourceDate = b.config.sourceDate()
+	}
+
+	// The guest restored from a checkpoint only knows the temporary SSH key
+	// of the build which saved it
+	var checkpointKeyPath string
+	if b.config.usesTemporarySSHKey() {
+		checkpointKeyPath = b.config.checkpointKeyPath()
+	}
+
 	steps := []multistep.Step{}
 	if !b.config.ISOSkipCache {
 		steps = append(steps, &commonsteps.StepDownload{
@@ -64,11 +125,27 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	}
 
 	steps = append(steps, new(stepPrepareOutputDir),
+		multistep.If(b.config.LayeredOutput, &stepPrepareLayer{
+			Parent:      b.config.LayerParent,
+			ISOChecksum: b.config.ISOChecksum,
+			Verify:      b.config.LayerVerifyParent,
+		}),
 		&stepCreatevTPM{
 			enableVTPM: b.config.VTPM,
 			vtpmType:   b.config.TPMType,
 			isTPM1:     b.config.VTPMUseTPM1,
+
+			stateSource: b.config.VTPMStateSource,
+			manufacture: b.config.VTPMManufacture,
+			pcrBanks:    b.config.VTPMPCRBanks,
+			logLevel:    b.config.VTPMLogLevel,
+			abort:       abort,
 		},
+		multistep.If(len(b.config.SharedFolders) > 0, &stepShareFolders{
+			Folders: b.config.SharedFolders,
+			Backend: b.config.SharedFoldersBackend,
+			abort:   abort,
+		}),
 		&commonsteps.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Content:     b.config.FloppyConfig.FloppyContent,
@@ -89,15 +166,20 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			UseBackingFile:     b.config.UseBackingFile,
 			VMName:             b.config.VMName,
 			QemuImgArgs:        b.config.QemuImgArgs,
+			Resume:             b.config.ResumeFrom != "",
+			Reproducible:       b.config.Reproducible,
 		},
-		&stepCopyDisk{
+		multistep.If(b.config.ResumeFrom == "", &stepCopyDisk{
 			DiskImage:      b.config.DiskImage,
 			Format:         b.config.Format,
 			OutputDir:      b.config.OutputDir,
 			UseBackingFile: b.config.UseBackingFile,
 			VMName:         b.config.VMName,
-		},
-		&stepResizeDisk{
+
+			BaseImageCacheDir: b.config.BaseImageCacheDir,
+			ISOChecksum:       b.config.ISOChecksum,
+		}),
+		multistep.If(b.config.ResumeFrom == "", &stepResizeDisk{
 			DiskCompression: b.config.DiskCompression,
 			DiskImage:       b.config.DiskImage,
 			Format:          b.config.Format,
@@ -106,12 +188,23 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			VMName:          b.config.VMName,
 			DiskSize:        b.config.DiskSize,
 			QemuImgArgs:     b.config.QemuImgArgs,
-		},
+		}),
 		new(stepHTTPIPDiscover),
-		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
+		&stepHTTPServer{
+			StepHTTPServer: commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
+		},
 		&stepPortForward{
 			CommunicatorType: b.config.CommConfig.Comm.Type,
-			NetBridge:        b.config.NetBridge,
+			Transport:        b.config.CommConfig.CommunicatorTransport,
+			NetBackend:       commNIC.Backend,
+		},
+		multistep.If(b.config.CommConfig.usesVsock(),
+			&stepAllocateVsockCID{
+				CIDMin: b.config.CommConfig.VsockCIDMin,
+				CIDMax: b.config.CommConfig.VsockCIDMax,
+			}),
+		&stepExtraPortForwards{
+			PortForwards: b.config.PortForwards,
 		},
 		multistep.If(b.config.CommConfig.Comm.Type == "ssh",
 			&communicator.StepSSHKeyGen{
@@ -123,6 +216,8 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			EFIEnabled: b.config.QemuEFIBootConfig.EnableEFI,
 			OutputDir:  b.config.OutputDir,
 			SourcePath: b.config.QemuEFIBootConfig.OVMFVars,
+			SecureBoot: &b.config.QemuEFIBootConfig.EFISecureBoot,
+			SourceDate: sourceDate,
 		},
 		&stepRun{
 			DiskImage: b.config.DiskImage,
@@ -130,28 +225,67 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		&stepConfigureQMP{
 			QMPSocketPath: b.config.QMPSocketPath,
 		},
-		&stepTypeBootCommand{},
+		multistep.If(b.config.VCPUPinning != "", &stepPinVCPUs{
+			HostCPUs: b.config.vcpuHostCPUs(),
+		}),
+		&stepConfigureGuestAgent{
+			GuestAgentSocketPath: b.config.GuestAgentSocketPath,
+		},
+		multistep.If(!b.config.resumes("boot_command"), &stepTypeBootCommand{}),
+		multistep.If(b.config.savesCheckpoint("boot_command"), &stepCheckpoint{
+			Name:       "boot_command",
+			SSHKeyPath: checkpointKeyPath,
+			Comm:       &b.config.CommConfig.Comm,
+		}),
 		&stepWaitGuestAddress{
 			CommunicatorType: b.config.CommConfig.Comm.Type,
-			NetBridge:        b.config.NetBridge,
+			Transport:        b.config.CommConfig.CommunicatorTransport,
+			AddressSource:    b.config.GuestAddressSource,
+			DHCPLeasesFile:   b.config.DHCPLeasesFile,
+			GuestAgent:       b.config.GuestAgentEnable,
+			NetBackend:       commNIC.Backend,
+			NetBridge:        commNIC.Bridge,
+			TapDevice:        commNIC.TapDevice,
+			NetDevice:        commNIC.netdevID,
 			timeout:          b.config.CommConfig.Comm.SSHTimeout,
 		},
 		&communicator.StepConnect{
-			Config:    &b.config.CommConfig.Comm,
-			Host:      commHost(b.config.CommConfig.Comm.Host()),
-			SSHConfig: b.config.CommConfig.Comm.SSHConfigFunc(),
-			SSHPort:   commPort,
-			WinRMPort: commPort,
+			Config:        &b.config.CommConfig.Comm,
+			Host:          commHost(b.config.CommConfig.Comm.Host()),
+			SSHConfig:     b.config.CommConfig.Comm.SSHConfigFunc(),
+			SSHPort:       commPort,
+			WinRMPort:     commPort,
+			CustomConnect: customConnect,
 		},
-		new(commonsteps.StepProvision),
+		new(stepPutHTTPFetchedPaths),
+		multistep.If(!b.config.resumes("provision"), new(commonsteps.StepProvision)),
+		multistep.If(b.config.savesCheckpoint("provision"), &stepCheckpoint{
+			Name:       "provision",
+			SSHKeyPath: checkpointKeyPath,
+			Comm:       &b.config.CommConfig.Comm,
+		}),
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.CommConfig.Comm,
 		},
+		multistep.If(b.config.TrimBeforeShutdown, &stepTrimDisk{
+			Command: b.config.TrimCommand,
+		}),
 		&stepShutdown{
 			ShutdownTimeout: b.config.ShutdownTimeout,
 			ShutdownCommand: b.config.ShutdownCommand,
 			Comm:            &b.config.CommConfig.Comm,
 		},
+		multistep.If(b.config.VTPMPersistState, &stepPersistvTPMState{
+			OutputDir: b.config.OutputDir,
+		}),
+		&stepDeleteCheckpoints{
+			ResumeFrom:      b.config.ResumeFrom,
+			CheckpointAfter: b.config.CheckpointAfter,
+			SSHKeyPath:      b.config.checkpointKeyPath(),
+		},
+		multistep.If(b.config.LayeredOutput, &stepRebaseLayer{
+			Parent: b.config.LayerParent,
+		}),
 		&stepConvertDisk{
 			DiskCompression: b.config.DiskCompression,
 			Format:          b.config.Format,
@@ -159,7 +293,13 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SkipCompaction:  b.config.SkipCompaction,
 			VMName:          b.config.VMName,
 			QemuImgArgs:     b.config.QemuImgArgs,
+			Flatten:         b.config.BaseImageCache,
+			Reproducible:    b.config.Reproducible,
 		},
+		multistep.If(b.config.Reproducible, &stepNormalizeOutput{
+			OutputDir:  b.config.OutputDir,
+			SourceDate: sourceDate,
+		}),
 	)
 
 	// Setup the state bag
@@ -174,18 +314,8 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
 	b.runner.Run(ctx, state)
 
-	// If there was an error, return that
-	if rawErr, ok := state.GetOk("error"); ok {
-		return nil, rawErr.(error)
-	}
-
-	// If we were interrupted or cancelled, then just exit.
-	if _, ok := state.GetOk(multistep.StateCancelled); ok {
-		return nil, errors.New("Build was cancelled.")
-	}
-
-	if _, ok := state.GetOk(multistep.StateHalted); ok {
-		return nil, errors.New("Build was halted.")
+	if err := buildError(ctx, state); err != nil {
+		return nil, err
 	}
 
 	// Compile the artifact list
@@ -198,6 +328,11 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		if b.config.QemuEFIBootConfig.DropEFIVars && filepath.Base(path) == "efivars.fd" {
 			return nil
 		}
+		// Don't keep the network capture unless explicitely enabled.
+		if b.config.NetworkCaptureFile != "" && !b.config.NetworkCaptureInArtifact &&
+			filepath.Clean(path) == filepath.Clean(b.config.NetworkCaptureFile) {
+			return nil
+		}
 
 		if !info.IsDir() {
 			files = append(files, path)
@@ -227,6 +362,19 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	artifact.state["diskType"] = b.config.Format
 	artifact.state["diskSize"] = b.config.DiskSize
 	artifact.state["domainType"] = b.config.Accelerator
+	if b.config.NetworkCaptureFile != "" {
+		artifact.state["networkCaptureFile"] = b.config.NetworkCaptureFile
+	}
+	if vtpmStateDir, ok := state.GetOk("vtpm_state_dir"); ok {
+		artifact.state["vtpmStateDir"] = vtpmStateDir
+	}
+	if b.config.LayeredOutput {
+		artifact.state["layerParent"] = b.config.LayerParent
+		artifact.state["layerParentChecksum"] = state.Get("layer_parent_checksum")
+	}
+	if vcpuPinning, ok := state.GetOk("vcpu_pinning"); ok {
+		artifact.state["vcpuPinning"] = vcpuPinning
+	}
 
 	return artifact, nil
 }
@@ -254,3 +402,28 @@ func (b *Builder) newDriver(qemuBinary string) (Driver, error) {
 
 	return driver, nil
 }
+
+// buildError returns the error that stopped the build, if any.
+func buildError(ctx context.Context, state multistep.StateBag) error {
+	// If there was an error, return that. The sidecars stopping with the VM
+	// in the cleanup of a failed build must not hide it.
+	if rawErr, ok := state.GetOk("error"); ok {
+		return rawErr.(error)
+	}
+
+	// A failure aborting the build is the root cause of its cancellation
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return errors.New("Build was halted.")
+	}
+
+	return nil
+}
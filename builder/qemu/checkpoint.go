@@ -0,0 +1,113 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkpointNames are the points of the build a checkpoint can be saved
+// after, in build order.
+var checkpointNames = []string{"boot_command", "provision"}
+
+// checkpointTag is the name of the internal snapshot of a checkpoint.
+func checkpointTag(name string) string {
+	return "packer-" + name
+}
+
+// checkpointKeyPath is where the temporary SSH private key is saved with
+// the checkpoints, as the restored guest only knows the key of the build
+// which saved them.
+func (c *Config) checkpointKeyPath() string {
+	return filepath.Join(c.OutputDir, c.VMName+".checkpoint-key")
+}
+
+// usesTemporarySSHKey tells whether the SSH communicator authenticates with
+// a key pair generated for the build.
+func (c *Config) usesTemporarySSHKey() bool {
+	return c.CommConfig.Comm.Type == "ssh" && c.CommConfig.Comm.SSHPrivateKeyFile == ""
+}
+
+func checkpointIndex(name string) int {
+	for i, n := range checkpointNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// resumes tells whether the build resumes from a checkpoint taken after
+// name, so the steps up to name are skipped.
+func (c *Config) resumes(name string) bool {
+	return c.ResumeFrom != "" && checkpointIndex(name) <= checkpointIndex(c.ResumeFrom)
+}
+
+// savesCheckpoint tells whether a checkpoint is saved after name.
+func (c *Config) savesCheckpoint(name string) bool {
+	return containsString(c.CheckpointAfter, name) && !c.resumes(name)
+}
+
+func (c *Config) prepareCheckpoints() []error {
+	if len(c.CheckpointAfter) == 0 && c.ResumeFrom == "" {
+		return nil
+	}
+
+	var errs []error
+
+	for _, name := range c.CheckpointAfter {
+		if checkpointIndex(name) < 0 {
+			errs = append(errs, fmt.Errorf("unknown checkpoint %s in checkpoint_after, must be one of %s, "+
+				"checkpoints can't be saved after a single provisioner", name, strings.Join(checkpointNames, ", ")))
+		}
+	}
+	if c.ResumeFrom != "" && checkpointIndex(c.ResumeFrom) < 0 {
+		errs = append(errs, fmt.Errorf("unknown checkpoint %s in resume_from, must be one of %s",
+			c.ResumeFrom, strings.Join(checkpointNames, ", ")))
+	}
+
+	// savevm needs all the writable drives to support internal snapshots.
+	if c.Format != "qcow2" {
+		errs = append(errs, errors.New("checkpoints require the qcow2 format"))
+	}
+	if c.QemuEFIBootConfig.EnableEFI {
+		errs = append(errs, errors.New("checkpoints are not supported with efi_boot, the raw EFI variables can't be snapshotted"))
+	}
+	// The TPM state is not part of the snapshot.
+	if c.VTPM {
+		errs = append(errs, errors.New("checkpoints are not supported with vtpm"))
+	}
+	// QEMU blocks the migrations, and so savevm, of shared folders.
+	if len(c.SharedFolders) > 0 {
+		errs = append(errs, errors.New("checkpoints are not supported with shared_folders"))
+	}
+
+	if c.ResumeFrom != "" {
+		if c.PackerForce {
+			errs = append(errs, errors.New("resume_from can't be used with -force, which deletes the output directory"))
+		}
+		disk := filepath.Join(c.OutputDir, c.VMName)
+		if _, err := os.Stat(disk); err != nil {
+			errs = append(errs, fmt.Errorf("resume_from requires the disk of the failed build: %s", err))
+		}
+		// The guest authorizes the temporary key of the failed build.
+		if c.usesTemporarySSHKey() {
+			keyPath := c.checkpointKeyPath()
+			if _, err := os.Stat(keyPath); err != nil {
+				errs = append(errs, fmt.Errorf("resume_from requires the temporary SSH key saved with the checkpoint: %s", err))
+			}
+			c.CommConfig.Comm.SSHPrivateKeyFile = keyPath
+		}
+	}
+
+	if len(c.CheckpointAfter) > 0 {
+		c.QMPEnable = true
+	}
+
+	return errs
+}
@@ -6,7 +6,11 @@ package qemu
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"regexp"
+	"runtime"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -32,6 +36,63 @@ type CommConfig struct {
 	SSHHostPortMin int `mapstructure:"ssh_host_port_min" required:"false"`
 	// TODO: remove later
 	SSHHostPortMax int `mapstructure:"ssh_host_port_max"`
+	// The amount of time to wait for the QEMU guest agent to answer when
+	// `communicator` is `qga`. Defaults to 5m.
+	//
+	// The `qga` communicator runs the provisioners through the guest agent,
+	// for guests with neither SSH nor WinRM. It enables `guest_agent_enable`.
+	// The guest agent only returns the output of a command when it exits,
+	// and directories can't be downloaded with it.
+	QGATimeout time.Duration `mapstructure:"qga_timeout" required:"false"`
+	// The path of the unix socket the serial console of the VM is exposed
+	// on when `communicator` is `serial`. Defaults to
+	// `output_directory`/`vm_name`.console.
+	//
+	// The `serial` communicator logs in on the serial console of the guest
+	// and runs the provisioners in its shell, for images with no network
+	// stack at all. The guest must run a getty on its first serial port
+	// and provide a POSIX shell with `base64`. The standard error of the
+	// commands is merged with their standard output, and directories can't
+	// be downloaded with it.
+	SerialSocketPath string `mapstructure:"serial_socket_path" required:"false"`
+	// The username to log in with on the serial console. Required when
+	// `communicator` is `serial`.
+	SerialUsername string `mapstructure:"serial_username" required:"false"`
+	// The password to log in with on the serial console.
+	SerialPassword string `mapstructure:"serial_password" required:"false"`
+	// A regular expression matching the login prompt of the serial console,
+	// at the end of its output. Defaults to `login:\s*$`.
+	SerialLoginPrompt string `mapstructure:"serial_login_prompt" required:"false"`
+	// A regular expression matching the password prompt of the serial
+	// console, at the end of its output. Defaults to `[Pp]assword:\s*$`.
+	SerialPasswordPrompt string `mapstructure:"serial_password_prompt" required:"false"`
+	// A regular expression matching the shell prompt of the serial console
+	// once logged in, at the end of its output. Defaults to `[$#]\s*$`.
+	SerialShellPrompt string `mapstructure:"serial_shell_prompt" required:"false"`
+	// The amount of time to wait for the login on the serial console to
+	// succeed. Defaults to 5m.
+	SerialTimeout time.Duration `mapstructure:"serial_timeout" required:"false"`
+	// How the SSH communicator reaches the guest. Either `tcp` or `vsock`.
+	// Defaults to `tcp`, which connects through the guest network, with a
+	// forwarded host port when using user-mode networking.
+	//
+	// With `vsock`, a `vhost-vsock-pci` device is added to the VM with a
+	// unique guest CID, and Packer connects to `ssh_port` over AF_VSOCK from
+	// the host, so the guest network doesn't need to be reachable. The SSH
+	// server of the guest must listen on vsock, which systemd-based guests
+	// do with systemd-ssh-generator. This requires Linux hosts with the
+	// vhost_vsock module loaded, and can't be used with an SSH bastion or
+	// proxy.
+	CommunicatorTransport string `mapstructure:"communicator_transport" required:"false"`
+	// The minimum guest CID to allocate when `communicator_transport` is
+	// `vsock`. By default this is 3, the first CID available to guests.
+	VsockCIDMin int `mapstructure:"vsock_cid_min" required:"false"`
+	// The maximum guest CID to allocate when `communicator_transport` is
+	// `vsock`. Because Packer often runs in parallel, Packer will choose a
+	// randomly available CID in this range, which is locked for the duration
+	// of the build like the communicator host ports. By default this is
+	// 65535.
+	VsockCIDMax int `mapstructure:"vsock_cid_max" required:"false"`
 }
 
 func (c *CommConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs []error) {
@@ -58,7 +119,25 @@ func (c *CommConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs
 		c.HostPortMax = 4444
 	}
 
-	errs = c.Comm.Prepare(ctx)
+	// The communicator package doesn't know about the guest agent and
+	// serial communicators, they're configured like a communicator without
+	// a connection.
+	switch c.Comm.Type {
+	case "qga":
+		c.Comm.Type = "none"
+		errs = c.Comm.Prepare(ctx)
+		c.Comm.Type = "qga"
+		if c.QGATimeout == 0 {
+			c.QGATimeout = 5 * time.Minute
+		}
+	case "serial":
+		c.Comm.Type = "none"
+		errs = c.Comm.Prepare(ctx)
+		c.Comm.Type = "serial"
+		errs = append(errs, c.prepareSerial()...)
+	default:
+		errs = c.Comm.Prepare(ctx)
+	}
 
 	if c.Comm.Host() == "" && c.SkipNatMapping {
 		log.Printf("Resetting ssh host to 127.0.0.1")
@@ -75,5 +154,114 @@ func (c *CommConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs
 		errs = append(errs, errors.New("host_port_min must be positive"))
 	}
 
+	errs = append(errs, c.prepareTransport()...)
+
 	return
 }
+
+func (c *CommConfig) prepareSerial() (errs []error) {
+	if c.SerialUsername == "" {
+		errs = append(errs, errors.New("serial_username must be specified with the serial communicator"))
+	}
+
+	if c.SerialLoginPrompt == "" {
+		c.SerialLoginPrompt = `login:\s*$`
+	}
+
+	if c.SerialPasswordPrompt == "" {
+		c.SerialPasswordPrompt = `[Pp]assword:\s*$`
+	}
+
+	if c.SerialShellPrompt == "" {
+		c.SerialShellPrompt = `[$#]\s*$`
+	}
+
+	if c.SerialTimeout == 0 {
+		c.SerialTimeout = 5 * time.Minute
+	}
+
+	for name, prompt := range map[string]string{
+		"serial_login_prompt":    c.SerialLoginPrompt,
+		"serial_password_prompt": c.SerialPasswordPrompt,
+		"serial_shell_prompt":    c.SerialShellPrompt,
+	} {
+		if _, err := regexp.Compile(prompt); err != nil {
+			errs = append(errs, fmt.Errorf("%s is not a valid regular expression: %s", name, err))
+		}
+	}
+
+	return errs
+}
+
+// serialCredentials returns the credentials and prompts of the serial
+// console, once validated by Prepare.
+func (c *CommConfig) serialCredentials() serialCredentials {
+	return serialCredentials{
+		Username:       c.SerialUsername,
+		Password:       c.SerialPassword,
+		LoginPrompt:    regexp.MustCompile(c.SerialLoginPrompt),
+		PasswordPrompt: regexp.MustCompile(c.SerialPasswordPrompt),
+		ShellPrompt:    regexp.MustCompile(c.SerialShellPrompt),
+	}
+}
+
+func (c *CommConfig) prepareTransport() (errs []error) {
+	if c.CommunicatorTransport == "" {
+		c.CommunicatorTransport = "tcp"
+	}
+
+	switch c.CommunicatorTransport {
+	case "tcp":
+		return nil
+	case "vsock":
+	default:
+		return append(errs, errors.New("communicator_transport must be one of tcp or vsock"))
+	}
+
+	if c.VsockCIDMin == 0 {
+		c.VsockCIDMin = 3
+	}
+
+	if c.VsockCIDMax == 0 {
+		c.VsockCIDMax = 65535
+	}
+
+	if runtime.GOOS != "linux" {
+		errs = append(errs, errors.New("communicator_transport vsock is only supported on Linux hosts"))
+	}
+
+	if c.Comm.Type != "ssh" {
+		errs = append(errs, errors.New("communicator_transport vsock requires the ssh communicator"))
+	}
+
+	if c.Comm.SSHBastionHost != "" || c.Comm.SSHProxyHost != "" {
+		errs = append(errs, errors.New("communicator_transport vsock can't be used with ssh_bastion_host or ssh_proxy_host"))
+	}
+
+	if c.SkipNatMapping {
+		errs = append(errs, errors.New("communicator_transport vsock can't be used with skip_nat_mapping"))
+	}
+
+	if c.VsockCIDMin < 3 {
+		errs = append(errs, errors.New("vsock_cid_min must be at least 3"))
+	}
+
+	if c.VsockCIDMin > c.VsockCIDMax {
+		errs = append(errs, errors.New("vsock_cid_min must be less than vsock_cid_max"))
+	}
+
+	return errs
+}
+
+// usesNetwork tells whether the communicator connects to the guest through
+// its network, which the guest agent and serial communicators and the vsock
+// transport don't.
+func (c *CommConfig) usesNetwork() bool {
+	return c.Comm.Type != "none" && c.Comm.Type != "qga" && c.Comm.Type != "serial" && !c.usesVsock()
+}
+
+// usesVsock tells whether the communicator connects to the guest over
+// AF_VSOCK.
+func (c *CommConfig) usesVsock() bool {
+	return c.CommunicatorTransport == "vsock"
+}
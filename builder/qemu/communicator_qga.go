@@ -0,0 +1,339 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// qgaFileChunkSize is the size of the chunks files are transferred in,
+	// well below the guest agent message size limit once base64 encoded.
+	qgaFileChunkSize = 64 * 1024
+	// qgaExecPollInterval is how often the status of a running command is
+	// checked.
+	qgaExecPollInterval = 500 * time.Millisecond
+)
+
+type guestExecArguments struct {
+	Path          string   `json:"path"`
+	Arg           []string `json:"arg,omitempty"`
+	InputData     string   `json:"input-data,omitempty"`
+	CaptureOutput bool     `json:"capture-output"`
+}
+
+type guestExecResult struct {
+	PID int `json:"pid"`
+}
+
+type guestExecStatusArguments struct {
+	PID int `json:"pid"`
+}
+
+type guestExecStatus struct {
+	Exited       bool   `json:"exited"`
+	ExitCode     int    `json:"exitcode"`
+	Signal       int    `json:"signal"`
+	OutData      string `json:"out-data"`
+	ErrData      string `json:"err-data"`
+	OutTruncated bool   `json:"out-truncated"`
+	ErrTruncated bool   `json:"err-truncated"`
+}
+
+type guestFileOpenArguments struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+type guestFileHandleArguments struct {
+	Handle int `json:"handle"`
+}
+
+type guestFileReadArguments struct {
+	Handle int `json:"handle"`
+	Count  int `json:"count"`
+}
+
+type guestFileReadResult struct {
+	Count  int    `json:"count"`
+	BufB64 string `json:"buf-b64"`
+	EOF    bool   `json:"eof"`
+}
+
+type guestFileWriteArguments struct {
+	Handle int    `json:"handle"`
+	BufB64 string `json:"buf-b64"`
+}
+
+type guestOSInfo struct {
+	ID string `json:"id"`
+}
+
+// qgaCommunicator is a packersdk.Communicator running commands and
+// transferring files through the QEMU guest agent, for guests with neither
+// SSH nor WinRM.
+//
+// The guest agent only returns the output of a command once it exited, so
+// the output of long running commands shows up at once.
+type qgaCommunicator struct {
+	client  *guestAgentClient
+	windows bool
+}
+
+func newQGACommunicator(client *guestAgentClient) *qgaCommunicator {
+	comm := &qgaCommunicator{client: client}
+
+	var osInfo guestOSInfo
+	if err := client.Run("guest-get-osinfo", nil, &osInfo); err != nil {
+		log.Printf("Could not get the guest OS info, assuming a POSIX shell is available: %s", err)
+	}
+	comm.windows = osInfo.ID == "mswindows"
+
+	return comm
+}
+
+func (c *qgaCommunicator) shellCommand(command string) (string, []string) {
+	if c.windows {
+		return "cmd.exe", []string{"/c", command}
+	}
+	return "/bin/sh", []string{"-c", command}
+}
+
+func (c *qgaCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	shell, args := c.shellCommand(cmd.Command)
+	execArgs := guestExecArguments{
+		Path:          shell,
+		Arg:           args,
+		CaptureOutput: true,
+	}
+	if cmd.Stdin != nil {
+		input, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read the command input: %w", err)
+		}
+		execArgs.InputData = base64.StdEncoding.EncodeToString(input)
+	}
+
+	var result guestExecResult
+	if err := c.client.Run("guest-exec", execArgs, &result); err != nil {
+		return fmt.Errorf("failed to start command %q: %w", cmd.Command, err)
+	}
+	log.Printf("[DEBUG] started command %q as guest pid %d", cmd.Command, result.PID)
+
+	go func() {
+		status, err := c.waitExec(ctx, result.PID)
+		if err != nil {
+			log.Printf("[ERROR] command %q failed: %s", cmd.Command, err)
+			cmd.SetExited(packersdk.CmdDisconnect)
+			return
+		}
+
+		c.writeOutput(cmd.Stdout, status.OutData, status.OutTruncated)
+		c.writeOutput(cmd.Stderr, status.ErrData, status.ErrTruncated)
+
+		exitCode := status.ExitCode
+		if status.Signal != 0 {
+			exitCode = 128 + status.Signal
+		}
+		cmd.SetExited(exitCode)
+	}()
+
+	return nil
+}
+
+func (c *qgaCommunicator) waitExec(ctx context.Context, pid int) (*guestExecStatus, error) {
+	for {
+		var status guestExecStatus
+		if err := c.client.Run("guest-exec-status", guestExecStatusArguments{PID: pid}, &status); err != nil {
+			return nil, err
+		}
+		if status.Exited {
+			return &status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(qgaExecPollInterval):
+		}
+	}
+}
+
+func (c *qgaCommunicator) writeOutput(w io.Writer, data string, truncated bool) {
+	if w == nil || data == "" {
+		return
+	}
+	output, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		log.Printf("[ERROR] failed to decode command output: %s", err)
+		return
+	}
+	if _, err := w.Write(output); err != nil {
+		log.Printf("[ERROR] failed to write command output: %s", err)
+	}
+	if truncated {
+		log.Printf("[WARN] the command output was truncated by the guest agent")
+	}
+}
+
+// run runs a command in the guest and waits for it to exit successfully.
+func (c *qgaCommunicator) run(command string) error {
+	var stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stderr:  &stderr,
+	}
+	if err := c.Start(context.Background(), cmd); err != nil {
+		return err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return fmt.Errorf("command %q exited with status %d: %s", command, status, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (c *qgaCommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	var handle int
+	if err := c.client.Run("guest-file-open", guestFileOpenArguments{Path: dst, Mode: "wb"}, &handle); err != nil {
+		return fmt.Errorf("failed to open %s in the guest: %w", dst, err)
+	}
+
+	buf := make([]byte, qgaFileChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			writeArgs := guestFileWriteArguments{
+				Handle: handle,
+				BufB64: base64.StdEncoding.EncodeToString(buf[:n]),
+			}
+			if err := c.client.Run("guest-file-write", writeArgs, nil); err != nil {
+				c.closeFile(handle)
+				return fmt.Errorf("failed to write %s in the guest: %w", dst, err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			c.closeFile(handle)
+			return err
+		}
+	}
+
+	if err := c.client.Run("guest-file-close", guestFileHandleArguments{Handle: handle}, nil); err != nil {
+		return fmt.Errorf("failed to close %s in the guest: %w", dst, err)
+	}
+
+	if fi != nil && !c.windows {
+		return c.run(fmt.Sprintf("chmod %o %s", (*fi).Mode().Perm(), shellQuote(dst)))
+	}
+	return nil
+}
+
+func (c *qgaCommunicator) closeFile(handle int) {
+	if err := c.client.Run("guest-file-close", guestFileHandleArguments{Handle: handle}, nil); err != nil {
+		log.Printf("[WARN] failed to close guest file handle %d: %s", handle, err)
+	}
+}
+
+func (c *qgaCommunicator) mkdir(dir string) error {
+	if c.windows {
+		// Unlike the guest-file-* commands, cmd.exe doesn't take forward
+		// slashes as path separators. Windows paths can't contain quotes.
+		dir = strings.ReplaceAll(dir, "/", `\`)
+		return c.run(fmt.Sprintf(`if not exist "%s" mkdir "%s"`, dir, dir))
+	}
+	return c.run("mkdir -p " + shellQuote(dir))
+}
+
+// shellQuote quotes s as a single word for /bin/sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (c *qgaCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	return uploadDir(dst, src, exclude, c.mkdir, c.Upload)
+}
+
+// uploadDir uploads a directory file by file, for the communicators with no
+// way to transfer a directory at once.
+func uploadDir(dst string, src string, exclude []string, mkdir func(string) error, upload func(string, io.Reader, *os.FileInfo) error) error {
+	// Like the other communicators, "src/" uploads the content of src in
+	// dst, and "src" uploads src itself in dst.
+	if !strings.HasSuffix(src, "/") {
+		dst = path.Join(dst, filepath.Base(src))
+	}
+
+	return filepath.Walk(src, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, localPath)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range exclude {
+			if matched, _ := filepath.Match(pattern, rel); matched {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		remotePath := path.Join(dst, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return mkdir(remotePath)
+		}
+
+		f, err := os.Open(localPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return upload(remotePath, f, &info)
+	})
+}
+
+func (c *qgaCommunicator) Download(src string, w io.Writer) error {
+	var handle int
+	if err := c.client.Run("guest-file-open", guestFileOpenArguments{Path: src, Mode: "rb"}, &handle); err != nil {
+		return fmt.Errorf("failed to open %s in the guest: %w", src, err)
+	}
+	defer c.closeFile(handle)
+
+	for {
+		var result guestFileReadResult
+		if err := c.client.Run("guest-file-read", guestFileReadArguments{Handle: handle, Count: qgaFileChunkSize}, &result); err != nil {
+			return fmt.Errorf("failed to read %s in the guest: %w", src, err)
+		}
+		data, err := base64.StdEncoding.DecodeString(result.BufB64)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s content: %w", src, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if result.EOF || result.Count == 0 {
+			return nil
+		}
+	}
+}
+
+func (c *qgaCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	return fmt.Errorf("downloading directories is not supported by the qga communicator")
+}
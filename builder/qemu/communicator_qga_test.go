@@ -0,0 +1,266 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeGuestFS is an in-memory guest filesystem and process table, served
+// through the guest-exec and guest-file-* commands.
+type fakeGuestFS struct {
+	lock     sync.Mutex
+	files    map[string][]byte
+	handles  map[int]string
+	offsets  map[int]int
+	commands []string
+	polls    map[int]int
+}
+
+func newFakeGuestFS() *fakeGuestFS {
+	return &fakeGuestFS{
+		files:   map[string][]byte{},
+		handles: map[int]string{},
+		offsets: map[int]int{},
+		polls:   map[int]int{},
+	}
+}
+
+func (fs *fakeGuestFS) handlers() map[string]guestAgentHandler {
+	return map[string]guestAgentHandler{
+		"guest-get-osinfo": func(json.RawMessage) (interface{}, error) {
+			return guestOSInfo{ID: "debian"}, nil
+		},
+		"guest-exec": func(raw json.RawMessage) (interface{}, error) {
+			var args guestExecArguments
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			fs.lock.Lock()
+			defer fs.lock.Unlock()
+			fs.commands = append(fs.commands, strings.Join(append([]string{args.Path}, args.Arg...), " "))
+			return guestExecResult{PID: len(fs.commands)}, nil
+		},
+		"guest-exec-status": func(raw json.RawMessage) (interface{}, error) {
+			var args guestExecStatusArguments
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			fs.lock.Lock()
+			defer fs.lock.Unlock()
+			// Report the command as running on the first poll.
+			fs.polls[args.PID]++
+			if fs.polls[args.PID] == 1 {
+				return guestExecStatus{}, nil
+			}
+			command := fs.commands[args.PID-1]
+			if strings.HasSuffix(command, "exit 3") {
+				return guestExecStatus{
+					Exited:   true,
+					ExitCode: 3,
+					ErrData:  base64.StdEncoding.EncodeToString([]byte("failed\n")),
+				}, nil
+			}
+			return guestExecStatus{
+				Exited:  true,
+				OutData: base64.StdEncoding.EncodeToString([]byte("ran " + command + "\n")),
+			}, nil
+		},
+		"guest-file-open": func(raw json.RawMessage) (interface{}, error) {
+			var args guestFileOpenArguments
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			fs.lock.Lock()
+			defer fs.lock.Unlock()
+			if _, ok := fs.files[args.Path]; !ok {
+				if args.Mode == "rb" {
+					return nil, fmt.Errorf("%s: no such file", args.Path)
+				}
+			}
+			if args.Mode == "wb" {
+				fs.files[args.Path] = nil
+			}
+			handle := len(fs.handles) + 1000
+			fs.handles[handle] = args.Path
+			return handle, nil
+		},
+		"guest-file-write": func(raw json.RawMessage) (interface{}, error) {
+			var args guestFileWriteArguments
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			data, err := base64.StdEncoding.DecodeString(args.BufB64)
+			if err != nil {
+				return nil, err
+			}
+			fs.lock.Lock()
+			defer fs.lock.Unlock()
+			p := fs.handles[args.Handle]
+			fs.files[p] = append(fs.files[p], data...)
+			return map[string]interface{}{"count": len(data), "eof": false}, nil
+		},
+		"guest-file-read": func(raw json.RawMessage) (interface{}, error) {
+			var args guestFileReadArguments
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			fs.lock.Lock()
+			defer fs.lock.Unlock()
+			content := fs.files[fs.handles[args.Handle]]
+			offset := fs.offsets[args.Handle]
+			end := offset + args.Count
+			if end > len(content) {
+				end = len(content)
+			}
+			fs.offsets[args.Handle] = end
+			return guestFileReadResult{
+				Count:  end - offset,
+				BufB64: base64.StdEncoding.EncodeToString(content[offset:end]),
+				EOF:    end == len(content),
+			}, nil
+		},
+		"guest-file-close": func(json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	}
+}
+
+func newTestQGACommunicator(t *testing.T) (*qgaCommunicator, *fakeGuestFS) {
+	host, guest := net.Pipe()
+	t.Cleanup(func() { guest.Close() })
+
+	fs := newFakeGuestFS()
+	go fakeGuestAgent(t, guest, fs.handlers())
+
+	client := newGuestAgentClientFromConn(host, 5*time.Second)
+	t.Cleanup(func() { client.Close() })
+
+	return newQGACommunicator(client), fs
+}
+
+func TestQGACommunicator_Start(t *testing.T) {
+	comm, _ := newTestQGACommunicator(t)
+
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: "echo hello",
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if status := cmd.Wait(); status != 0 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+	if stdout.String() != "ran /bin/sh -c echo hello\n" {
+		t.Fatalf("bad stdout: %q", stdout.String())
+	}
+
+	stdout.Reset()
+	cmd = &packersdk.RemoteCmd{
+		Command: "exit 3",
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if status := cmd.Wait(); status != 3 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+	if stderr.String() != "failed\n" {
+		t.Fatalf("bad stderr: %q", stderr.String())
+	}
+}
+
+func TestQGACommunicator_Files(t *testing.T) {
+	comm, fs := newTestQGACommunicator(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), qgaFileChunkSize/8)
+	if err := comm.Upload("/tmp/script.sh", bytes.NewReader(content), nil); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !bytes.Equal(fs.files["/tmp/script.sh"], content) {
+		t.Fatalf("bad uploaded content, got %d bytes", len(fs.files["/tmp/script.sh"]))
+	}
+
+	var downloaded bytes.Buffer
+	if err := comm.Download("/tmp/script.sh", &downloaded); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), content) {
+		t.Fatalf("bad downloaded content, got %d bytes", downloaded.Len())
+	}
+
+	if err := comm.Download("/tmp/missing", &downloaded); err == nil {
+		t.Fatal("should have error downloading a missing file")
+	}
+
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := comm.UploadDir("/opt/files", src+"/", nil); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if string(fs.files["/opt/files/sub/file.txt"]) != "hello" {
+		t.Fatalf("bad uploaded files: %#v", fs.files)
+	}
+	if !strings.Contains(strings.Join(fs.commands, "\n"), "mkdir -p '/opt/files/sub'") {
+		t.Fatalf("directories should be created: %#v", fs.commands)
+	}
+}
+
+func TestQGACommunicator_Mkdir(t *testing.T) {
+	comm, fs := newTestQGACommunicator(t)
+
+	if err := comm.mkdir("/opt/it's here"); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	comm.windows = true
+	if err := comm.mkdir("C:/Program Files/app"); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	expected := []string{
+		`/bin/sh -c mkdir -p '/opt/it'\''s here'`,
+		`cmd.exe /c if not exist "C:\Program Files\app" mkdir "C:\Program Files\app"`,
+	}
+	if !reflect.DeepEqual(fs.commands, expected) {
+		t.Fatalf("bad commands: %#v", fs.commands)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tc := map[string]string{
+		"/tmp/script.sh":   `'/tmp/script.sh'`,
+		"/tmp/with space":  `'/tmp/with space'`,
+		"/tmp/it's":        `'/tmp/it'\''s'`,
+		"/tmp/'; rm -rf /": `'/tmp/'\''; rm -rf /'`,
+	}
+	for s, expected := range tc {
+		if got := shellQuote(s); got != expected {
+			t.Errorf("shellQuote(%q) = %s, expected %s", s, got, expected)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// serialFileLineSize is the length of the base64 lines files are
+	// transferred in, well below the line length limit of the terminal.
+	serialFileLineSize = 76
+	// serialFileChunkLines is the number of base64 lines sent per command.
+	serialFileChunkLines = 64
+	// serialReadInterval is how often reads from the console check whether
+	// they are cancelled.
+	serialReadInterval = 500 * time.Millisecond
+	// serialMaxLoginAttempts is the number of login prompts answered before
+	// giving up on bad credentials.
+	serialMaxLoginAttempts = 3
+)
+
+// serialCredentials are the credentials and prompts used to log in on the
+// serial console.
+type serialCredentials struct {
+	Username       string
+	Password       string
+	LoginPrompt    *regexp.Regexp
+	PasswordPrompt *regexp.Regexp
+	ShellPrompt    *regexp.Regexp
+}
+
+// serialCommunicator is a packersdk.Communicator driving a POSIX login shell
+// over the serial console of the guest, for guests with no network stack.
+//
+// The console is a single stream, so commands run one at a time, and their
+// standard error is merged with their standard output. The output of a
+// command is found between sentinel markers printed by the shell around it,
+// the end marker carrying its exit status. The markers are printed with
+// printf so that their echo doesn't match them.
+type serialCommunicator struct {
+	conn net.Conn
+
+	// mu is held while a command runs.
+	mu  sync.Mutex
+	buf []byte
+}
+
+// newSerialCommunicator logs in on the console with the credentials and
+// sets up the shell for running commands.
+func newSerialCommunicator(ctx context.Context, conn net.Conn, creds serialCredentials) (*serialCommunicator, error) {
+	c := &serialCommunicator{conn: conn}
+
+	if err := c.login(ctx, creds); err != nil {
+		return nil, err
+	}
+
+	// Disable the echo and the prompts to keep the output of the commands
+	// clean, then flush whatever was printed until now.
+	if err := c.write("stty -echo 2>/dev/null; PS1=''; PS2=''; export TERM=dumb\n"); err != nil {
+		return nil, err
+	}
+	if _, err := c.runMarked(ctx, ":", io.Discard); err != nil {
+		return nil, fmt.Errorf("failed to set up the console shell: %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *serialCommunicator) login(ctx context.Context, creds serialCredentials) error {
+	// Wake the getty up, it only prints its prompt once.
+	if err := c.write("\n"); err != nil {
+		return err
+	}
+
+	loginAttempts := 0
+	for {
+		i, err := c.expect(ctx, creds.LoginPrompt, creds.PasswordPrompt, creds.ShellPrompt)
+		if err != nil {
+			return fmt.Errorf("failed to log in on the serial console: %w", err)
+		}
+
+		switch i {
+		case 0:
+			if loginAttempts == serialMaxLoginAttempts {
+				return fmt.Errorf("failed to log in on the serial console as %s after %d attempts", creds.Username, loginAttempts)
+			}
+			loginAttempts++
+			log.Printf("[DEBUG] answering the serial console login prompt as %s", creds.Username)
+			err = c.write(creds.Username + "\n")
+		case 1:
+			log.Printf("[DEBUG] answering the serial console password prompt")
+			err = c.write(creds.Password + "\n")
+		case 2:
+			log.Printf("[DEBUG] found the serial console shell prompt")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *serialCommunicator) write(s string) error {
+	if _, err := io.WriteString(c.conn, s); err != nil {
+		return fmt.Errorf("failed to write to the serial console: %w", err)
+	}
+	return nil
+}
+
+// fill reads more output of the console into the buffer.
+func (c *serialCommunicator) fill(ctx context.Context) error {
+	chunk := make([]byte, 4096)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.conn.SetReadDeadline(time.Now().Add(serialReadInterval)); err != nil {
+			return err
+		}
+		n, err := c.conn.Read(chunk)
+		if n > 0 {
+			c.buf = append(c.buf, bytes.ReplaceAll(chunk[:n], []byte("\r"), nil)...)
+			return nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read from the serial console: %w", err)
+		}
+	}
+}
+
+// expect reads the console until one of the patterns matches its output,
+// and returns the index of that pattern. The output up to the match is
+// consumed.
+func (c *serialCommunicator) expect(ctx context.Context, patterns ...*regexp.Regexp) (int, error) {
+	for {
+		for i, re := range patterns {
+			if loc := re.FindIndex(c.buf); loc != nil {
+				c.buf = c.buf[loc[1]:]
+				return i, nil
+			}
+		}
+		if err := c.fill(ctx); err != nil {
+			return -1, err
+		}
+	}
+}
+
+// readLine returns the next full line of output of the console, without
+// its line ending.
+func (c *serialCommunicator) readLine(ctx context.Context) (string, error) {
+	for {
+		if i := bytes.IndexByte(c.buf, '\n'); i >= 0 {
+			line := string(c.buf[:i])
+			c.buf = c.buf[i+1:]
+			return line, nil
+		}
+		if err := c.fill(ctx); err != nil {
+			return "", err
+		}
+	}
+}
+
+func newSerialMarker() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// runMarked runs a command in the console shell, writes its output to w,
+// and returns its exit status. The caller must hold the lock when the
+// console is shared.
+func (c *serialCommunicator) runMarked(ctx context.Context, command string, w io.Writer) (int, error) {
+	marker, err := newSerialMarker()
+	if err != nil {
+		return 0, err
+	}
+	begin := "__PACKER_BEGIN_" + marker
+	end := "__PACKER_END_" + marker + ":"
+
+	// The command runs in a subshell, so that exit or cd don't affect the
+	// console shell, with its input closed not to read the console.
+	line := fmt.Sprintf("printf '%%s_%%s\\n' __PACKER_BEGIN %s; (%s\n) </dev/null 2>&1; printf '%%s_%%s:%%d\\n' __PACKER_END %s $?\n",
+		marker, command, marker)
+	if err := c.write(line); err != nil {
+		return 0, err
+	}
+
+	for {
+		output, err := c.readLine(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if strings.HasSuffix(output, begin) {
+			break
+		}
+	}
+
+	for {
+		output, err := c.readLine(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if i := strings.Index(output, end); i >= 0 {
+			if i > 0 {
+				// The last line of output had no line ending.
+				fmt.Fprint(w, output[:i])
+			}
+			status, err := strconv.Atoi(output[i+len(end):])
+			if err != nil {
+				return 0, fmt.Errorf("bad exit status in %q: %w", output, err)
+			}
+			return status, nil
+		}
+		fmt.Fprintln(w, output)
+	}
+}
+
+func (c *serialCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	c.mu.Lock()
+
+	command := cmd.Command
+	if cmd.Stdin != nil {
+		input, err := newSerialMarker()
+		if err != nil {
+			c.mu.Unlock()
+			return err
+		}
+		inputPath := "/tmp/packer-input-" + input
+		if err := c.upload(ctx, inputPath, cmd.Stdin); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("failed to upload the command input: %w", err)
+		}
+		command = fmt.Sprintf("(%s\n) < %s; status=$?; rm -f %s; exit $status", command, inputPath, inputPath)
+	}
+
+	stdout := cmd.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	log.Printf("[DEBUG] starting command %q on the serial console", cmd.Command)
+	go func() {
+		defer c.mu.Unlock()
+
+		status, err := c.runMarked(ctx, command, stdout)
+		if err != nil {
+			log.Printf("[ERROR] command %q failed: %s", cmd.Command, err)
+			cmd.SetExited(packersdk.CmdDisconnect)
+			return
+		}
+		cmd.SetExited(status)
+	}()
+
+	return nil
+}
+
+// run runs a command in the guest and waits for it to exit successfully.
+// The caller must hold the lock.
+func (c *serialCommunicator) run(ctx context.Context, command string) error {
+	var output bytes.Buffer
+	status, err := c.runMarked(ctx, command, &output)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("command %q exited with status %d: %s", command, status, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// upload writes the content of r to dst in base64 chunks decoded by the
+// guest. The caller must hold the lock.
+func (c *serialCommunicator) upload(ctx context.Context, dst string, r io.Reader) error {
+	if err := c.run(ctx, ": > "+shellQuote(dst)); err != nil {
+		return err
+	}
+
+	raw := make([]byte, serialFileLineSize/4*3*serialFileChunkLines)
+	for {
+		n, err := io.ReadFull(r, raw)
+		if n > 0 {
+			encoded := base64.StdEncoding.EncodeToString(raw[:n])
+			var chunk strings.Builder
+			fmt.Fprintf(&chunk, "base64 -d >> %s <<'__PACKER_EOF'\n", shellQuote(dst))
+			for len(encoded) > 0 {
+				lineSize := min(serialFileLineSize, len(encoded))
+				chunk.WriteString(encoded[:lineSize])
+				chunk.WriteString("\n")
+				encoded = encoded[lineSize:]
+			}
+			chunk.WriteString("__PACKER_EOF")
+			if err := c.run(ctx, chunk.String()); err != nil {
+				return fmt.Errorf("failed to write %s in the guest: %w", dst, err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *serialCommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx := context.Background()
+	if err := c.upload(ctx, dst, r); err != nil {
+		return err
+	}
+	if fi != nil {
+		return c.run(ctx, fmt.Sprintf("chmod %o %s", (*fi).Mode().Perm(), shellQuote(dst)))
+	}
+	return nil
+}
+
+func (c *serialCommunicator) mkdir(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.run(context.Background(), "mkdir -p "+shellQuote(dir))
+}
+
+func (c *serialCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	return uploadDir(dst, src, exclude, c.mkdir, c.Upload)
+}
+
+func (c *serialCommunicator) Download(src string, w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var encoded bytes.Buffer
+	status, err := c.runMarked(context.Background(), "base64 "+shellQuote(src), &encoded)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("failed to read %s in the guest: %s", src, strings.TrimSpace(encoded.String()))
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded.String(), "\n", ""))
+	if err != nil {
+		return fmt.Errorf("failed to decode %s content: %w", src, err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (c *serialCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	return fmt.Errorf("downloading directories is not supported by the serial communicator")
+}
+
+func (c *serialCommunicator) Close() error {
+	return c.conn.Close()
+}
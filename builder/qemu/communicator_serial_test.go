@@ -0,0 +1,217 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeSerialConsole answers the login prompts on the guest side of a pipe
+// like a getty, then hands the console over to a local shell.
+func fakeSerialConsole(t *testing.T, conn net.Conn, username, password string) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "[    1.234567] random: crng init done\r\n")
+	if _, err := r.ReadString('\n'); err != nil {
+		return
+	}
+	for {
+		fmt.Fprint(conn, "\r\nDebian GNU/Linux ttyS0\r\n\r\nguest login: ")
+		user, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fmt.Fprint(conn, "Password: ")
+		pass, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if strings.TrimSpace(user) == username && strings.TrimSpace(pass) == password {
+			break
+		}
+		fmt.Fprint(conn, "\r\nLogin incorrect\r\n")
+	}
+	fmt.Fprint(conn, "Last login: never\r\nroot@guest:~# ")
+
+	shell := exec.Command("/bin/sh")
+	shell.Stdin = r
+	shell.Stdout = conn
+	shell.Stderr = conn
+	if err := shell.Run(); err != nil {
+		t.Logf("fake console shell exited: %s", err)
+	}
+}
+
+// serialConsolePipe returns both sides of a unix socket, which unlike
+// net.Pipe buffers the writes like the chardev of a serial console.
+func serialConsolePipe(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "console"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	host, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, guest
+}
+
+func testSerialCredentials(username, password string) serialCredentials {
+	var c CommConfig
+	c.SerialUsername = username
+	c.SerialPassword = password
+	c.prepareSerial()
+	return c.serialCredentials()
+}
+
+func TestSerialCommunicator(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 is required to run this test")
+	}
+
+	host, guest := serialConsolePipe(t)
+	defer host.Close()
+	go fakeSerialConsole(t, guest, "root", "packer")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	comm, err := newSerialCommunicator(ctx, host, testSerialCredentials("root", "packer"))
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Commands
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: "echo hello; echo oops >&2; printf partial; exit 3",
+		Stdout:  &stdout,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if status := cmd.Wait(); status != 3 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+	if stdout.String() != "hello\noops\npartial" {
+		t.Fatalf("bad output: %q", stdout.String())
+	}
+
+	// Command input
+	stdout.Reset()
+	cmd = &packersdk.RemoteCmd{
+		Command: "tr a-z A-Z",
+		Stdin:   strings.NewReader("shout\n"),
+		Stdout:  &stdout,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if status := cmd.Wait(); status != 0 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+	if stdout.String() != "SHOUT\n" {
+		t.Fatalf("bad output: %q", stdout.String())
+	}
+
+	// Files
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("packer\x00\xff\n"), 2000)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "uploaded")
+	if err := comm.Upload(dst, bytes.NewReader(content), nil); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	uploaded, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(uploaded, content) {
+		t.Fatalf("bad uploaded content: %d bytes", len(uploaded))
+	}
+
+	var downloaded bytes.Buffer
+	if err := comm.Download(dst, &downloaded); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), content) {
+		t.Fatalf("bad downloaded content: %d bytes", downloaded.Len())
+	}
+
+	if err := comm.Upload(filepath.Join(dir, "script"), strings.NewReader("#!/bin/sh\n"), &fi); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "script"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != fi.Mode().Perm() {
+		t.Fatalf("bad uploaded mode: %s", info.Mode())
+	}
+
+	if err := comm.Download(filepath.Join(dir, "missing"), &downloaded); err == nil {
+		t.Fatal("should have error downloading a missing file")
+	}
+
+	// Paths with quotes
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "it's"), []byte("quoted"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	quotedDir := filepath.Join(dir, "packer's; touch injected")
+	if err := comm.UploadDir(quotedDir, src+"/", nil); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	downloaded.Reset()
+	if err := comm.Download(filepath.Join(quotedDir, "it's"), &downloaded); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if downloaded.String() != "quoted" {
+		t.Fatalf("bad downloaded content: %q", downloaded.String())
+	}
+	if _, err := os.Stat("injected"); err == nil {
+		os.Remove("injected")
+		t.Fatal("paths should be quoted")
+	}
+}
+
+func TestSerialCommunicator_BadCredentials(t *testing.T) {
+	host, guest := serialConsolePipe(t)
+	defer host.Close()
+	go fakeSerialConsole(t, guest, "root", "packer")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := newSerialCommunicator(ctx, host, testSerialCredentials("root", "wrong"))
+	if err == nil {
+		t.Fatal("should have error with bad credentials")
+	}
+	if !regexp.MustCompile(`after \d+ attempts`).MatchString(err.Error()) {
+		t.Fatalf("bad error: %s", err)
+	}
+}
@@ -11,10 +11,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/common"
@@ -63,6 +66,8 @@ var diskDZeroes = map[string]bool{
 	"off":   true,
 }
 
+var vtpmPCRBanks = []string{"sha1", "sha256", "sha384", "sha512", "sm3-256"}
+
 type QemuImgArgs struct {
 	Convert []string `mapstructure:"convert" required:"false"`
 	Create  []string `mapstructure:"create" required:"false"`
@@ -182,12 +187,38 @@ type QemuEFIBootConfig struct {
 	// mode, and requires a separate VARS.fd file to be able to persist data
 	// between boot cycles.
 	//
-	// Default: `/usr/share/OVMF/OVMF_CODE.fd`
+	// By default, the firmware is picked from the firmware descriptors
+	// QEMU and libvirt use, in `/etc/qemu/firmware`,
+	// `$XDG_CONFIG_HOME/qemu/firmware`, `/usr/share/qemu/firmware` and the
+	// `share/qemu/firmware` directory of the QEMU installation. The first
+	// descriptor, by file name, of a UEFI firmware supporting the
+	// `architecture` and `machine_type` with the `efi_firmware_features` is
+	// used, along with its VARS template.
+	//
+	// When the host has no firmware descriptor, this defaults to
+	// `/usr/share/OVMF/OVMF_CODE.fd`, or the firmware of the
+	// `architecture`: `/usr/share/AAVMF/AAVMF_CODE.fd` for `aarch64` and
+	// `/usr/share/qemu-efi-riscv64/RISCV_VIRT_CODE.fd` for `riscv64`.
 	OVMFCode string `mapstructure:"efi_firmware_code" required:"false"`
 	// Path to the VARS corresponding to the OVMF code file.
 	//
-	// Default: `/usr/share/OVMF/OVMF_VARS.fd`
+	// Default: the VARS template of the firmware descriptor, or
+	// `/usr/share/OVMF/OVMF_VARS.fd`, or the `VARS` file next to the default
+	// code file of the `architecture`.
 	OVMFVars string `mapstructure:"efi_firmware_vars" required:"false"`
+	// The features the firmware picked from the firmware descriptors must
+	// have, e.g. `secure-boot`, `enrolled-keys` or `amd-sev`. Setting this
+	// implicitely sets `efi_boot` to `true`.
+	//
+	// Firmwares with the `amd-sev`, `amd-sev-es`, `amd-sev-snp` or
+	// `intel-tdx` features are only picked when the feature is required,
+	// and firmwares requiring SMM are skipped. When no descriptor matches,
+	// the error lists the descriptors considered and why they were
+	// skipped.
+	FirmwareFeatures []string `mapstructure:"efi_firmware_features" required:"false"`
+	// Enable UEFI Secure Boot, and enroll custom keys. See the
+	// [Secure Boot Configuration](#secure-boot-configuration) below.
+	EFISecureBoot EFISecureBootConfig `mapstructure:"efi_secure_boot" required:"false"`
 	// Drop the efivars.fd file in the exported artifact.
 	//
 	// In addition to the disks created by the builder, we also expose the
@@ -202,23 +233,47 @@ type QemuEFIBootConfig struct {
 	DropEFIVars bool `mapstructure:"efi_drop_efivars" required:"false"`
 }
 
-func (efiCfg *QemuEFIBootConfig) loadDefaults() {
+func (efiCfg *QemuEFIBootConfig) loadDefaults(arch guestArchitecture, req firmwareRequirements, descriptorDirs []string) error {
 	// Auto enable EFI if either of the Code/Vars path is set
-	if efiCfg.OVMFCode != "" || efiCfg.OVMFVars != "" {
+	if efiCfg.OVMFCode != "" || efiCfg.OVMFVars != "" || len(efiCfg.FirmwareFeatures) > 0 || efiCfg.EFISecureBoot.enabled() {
 		efiCfg.EnableEFI = true
 	}
 
 	if !efiCfg.EnableEFI {
-		return
+		return nil
+	}
+
+	if efiCfg.OVMFCode == "" {
+		descriptors, err := loadFirmwareDescriptors(descriptorDirs)
+		if err != nil {
+			return err
+		}
+		for _, feature := range efiCfg.FirmwareFeatures {
+			if !containsString(req.Features, feature) {
+				req.Features = append(req.Features, feature)
+			}
+		}
+		if len(descriptors) > 0 || len(req.Features) > 0 {
+			firmware, err := selectFirmware(descriptors, req)
+			if err != nil {
+				return err
+			}
+			efiCfg.OVMFCode = firmware.Mapping.Executable.Filename
+			if efiCfg.OVMFVars == "" {
+				efiCfg.OVMFVars = firmware.Mapping.NVRAMTemplate.Filename
+			}
+		}
 	}
 
 	if efiCfg.OVMFCode == "" {
-		efiCfg.OVMFCode = "/usr/share/OVMF/OVMF_CODE.fd"
+		efiCfg.OVMFCode = arch.EFIFirmwareCode
 	}
 
 	if efiCfg.OVMFVars == "" {
-		efiCfg.OVMFVars = "/usr/share/OVMF/OVMF_VARS.fd"
+		efiCfg.OVMFVars = arch.EFIFirmwareVars
 	}
+
+	return nil
 }
 
 type Config struct {
@@ -231,10 +286,34 @@ type Config struct {
 	commonsteps.FloppyConfig       `mapstructure:",squash"`
 	commonsteps.CDConfig           `mapstructure:",squash"`
 	QemuSMPConfig                  `mapstructure:",squash"`
+	QemuMemoryConfig               `mapstructure:",squash"`
+	QemuTuningConfig               `mapstructure:",squash"`
 	QemuEFIBootConfig              `mapstructure:",squash"`
 	// Use iso from provided url. Qemu must support
 	// curl block device. This defaults to `false`.
 	ISOSkipCache bool `mapstructure:"iso_skip_cache" required:"false"`
+	// The architecture of the guest. Allowed values are `x86_64`,
+	// `aarch64`, `riscv64`, `ppc64le` and `s390x`. Defaults to `x86_64`.
+	//
+	// The architecture sets the defaults of `qemu_binary`, `machine_type`
+	// (`pc`, `virt`, `pseries` or `s390-ccw-virtio`), `cpu_model` and the
+	// EFI firmware paths, and picks devices the machine type supports:
+	//
+	//   - `aarch64` boots in EFI mode with AAVMF, with the CD-ROM on
+	//     `virtio-scsi`, and a virtio GPU and USB keyboard and tablet to
+	//     type the boot command with.
+	//   - `riscv64` boots with the OpenSBI firmware bundled with QEMU, or
+	//     with EDK2 when `efi_boot` is true, with the same devices as
+	//     `aarch64`.
+	//   - `s390x` attaches the virtio devices to the channel subsystem. It
+	//     has no display, the `serial` communicator and a `disk_image` are
+	//     the usual way to build it.
+	//
+	// When the architecture is not the one of the host, `accelerator`
+	// defaults to `tcg`, and the CPU model defaults to the most capable
+	// model QEMU emulates for the architecture. Explicitly set options are
+	// never overridden.
+	Architecture string `mapstructure:"architecture" required:"false"`
 	// The accelerator type to use when running the VM.
 	// This may be `none`, `kvm`, `tcg`, `hax`, `hvf`, `whpx`, or `xen`. The appropriate
 	// software must have already been installed on your build machine to use the
@@ -330,6 +409,21 @@ type Config struct {
 	// Apply compression to the QCOW2 disk file
 	// using qemu-img convert. Defaults to false.
 	DiskCompression bool `mapstructure:"disk_compression" required:"false"`
+	// Discard the free space of the guest file systems before shutting the
+	// VM down, so it is reclaimed from the disks. Compaction alone only
+	// reclaims what the guest discarded. The space reclaimed on the disk
+	// files is reported, except on Windows hosts.
+	//
+	// This sets `disk_discard` and `disk_detect_zeroes` to `unmap`, the
+	// discarded blocks are otherwise not released by QEMU, and requires a
+	// communicator.
+	TrimBeforeShutdown bool `mapstructure:"trim_before_shutdown" required:"false"`
+	// The command discarding the free space of the guest, run with the
+	// communicator. Defaults to `fstrim -av`, which requires a privileged
+	// user, e.g. `echo 'packer' | sudo -S fstrim -av` when it's not, or to
+	// `Optimize-Volume -ReTrim` on all the fixed volumes with PowerShell with
+	// the WinRM communicator.
+	TrimCommand string `mapstructure:"trim_command" required:"false"`
 	// Either `qcow2` or `raw`, this specifies the output format of the virtual
 	// machine image. This defaults to `qcow2`. Due to a long-standing bug with
 	// `qemu-img convert` on OSX, sometimes the qemu-img convert call will
@@ -359,9 +453,73 @@ type Config struct {
 	// will force the `skip_compaction` also to be true as well to skip disk
 	// conversion which would render the backing file feature useless.
 	UseBackingFile bool `mapstructure:"use_backing_file" required:"false"`
+	// Only applicable when disk_image is true and format is qcow2, set this
+	// option to true to convert the source image once into a local cache,
+	// and start the builds from a QCOW2 overlay on the cached image instead
+	// of a full copy. The overlay is flattened with `qemu-img convert` at
+	// the end of the build, even when `skip_compaction` is set, so the
+	// output doesn't depend on the cache.
+	//
+	// Cached images are keyed by the `iso_checksum` of the source, or the
+	// SHA-256 of its content when `iso_checksum` is not a value, and by
+	// `format`. They are never evicted, delete the cache directory to
+	// reclaim their space.
+	BaseImageCache bool `mapstructure:"base_image_cache" required:"false"`
+	// The directory of the cached base images. Defaults to
+	// `qemu-base-images` in the Packer cache directory, see
+	// `PACKER_CACHE_DIR`.
+	BaseImageCacheDir string `mapstructure:"base_image_cache_dir" required:"false"`
+	// Output only the changes of the build, as a QCOW2 layer over the source
+	// image. This implies `use_backing_file`, but once the VM is shut down
+	// the backing file of the layer is changed from the downloaded source
+	// image, usually in the Packer cache, to `layer_parent` with `qemu-img
+	// rebase -u`.
+	//
+	// The parent and its checksum, the `iso_checksum` or the SHA-256 of the
+	// source image, are recorded in the artifact and exposed as the
+	// `LayerParent` and `LayerParentChecksum` build variables, e.g. for the
+	// `custom_data` of the manifest post-processor.
+	LayeredOutput bool `mapstructure:"layered_output" required:"false"`
+	// The backing file of the layer, where the parent image will be when
+	// the layer is used. A relative path is relative to the layer. Defaults
+	// to the file name of the first `iso_urls`.
+	LayerParent string `mapstructure:"layer_parent" required:"false"`
+	// Verify at the start of the build that `layer_parent` has the content
+	// of the source image. `layer_parent` must then be an absolute path, as
+	// the directory of the layer doesn't exist yet.
+	LayerVerifyParent bool `mapstructure:"layer_verify_parent" required:"false"`
+	// Remove the sources of variation of the host from the build, so two
+	// builds of the same template doing the same in the guest output the same
+	// bytes:
+	//
+	//   - the network interfaces without `mac_address` get fixed MAC
+	//     addresses, `52:54:00:12:34:56` for the first one, and the disks get
+	//     fixed serial numbers, `packer-disk0` for the first one.
+	//   - the real time clock of the VM starts at `source_date_epoch`.
+	//   - all the disks are converted with pinned QCOW2 options once the VM
+	//     is shut down, even with `skip_compaction`, so their headers and
+	//     layout don't depend on the qemu-img defaults or on the order the
+	//     guest wrote in. Unlike the EFI variables, the QCOW2 headers have no
+	//     timestamps or host identifiers, and the conversion drops the
+	//     internal snapshots which do, so the headers need no post-processing.
+	//     They still depend on the version of qemu-img.
+	//   - the timestamps of the authenticated EFI variables of `efivars.fd`,
+	//     and the modification times of the output files are set to
+	//     `source_date_epoch`.
+	//
+	// What the guest does is left to the provisioners: the guest can still
+	// sync its clock over the network or generate random identifiers. The
+	// vTPM state persisted with `vtpm_persist_state` isn't reproducible.
+	// This requires `disk_interface` to be `virtio`, `virtio-scsi` or `ide`,
+	// and can't be used with `use_backing_file`.
+	Reproducible bool `mapstructure:"reproducible" required:"false"`
+	// The date reproducible builds pretend to run at, in seconds since the
+	// Unix epoch, `0` included. Defaults to the `SOURCE_DATE_EPOCH`
+	// environment variable, one of them is required with `reproducible`.
+	SourceDateEpoch *int64 `mapstructure:"source_date_epoch" required:"false"`
 	// The type of machine emulation to use. Run your qemu binary with the
 	// flags `-machine help` to list available types for your system. This
-	// defaults to `pc`.
+	// defaults to `pc`, or the machine type of the `architecture`.
 	//
 	// NOTE: when booting a UEFI machine with Secure Boot enabled, this has
 	// to be a q35 derivative.
@@ -388,6 +546,153 @@ type Config struct {
 	//
 	// **NB** This only works in Linux based OSes.
 	NetBridge string `mapstructure:"net_bridge" required:"false"`
+	// The network backend of the VM network interface when no
+	// `network_interface` is configured. Allowed values are `user`,
+	// `bridge`, `tap` and `socket`. Defaults to `bridge` if `net_bridge` is
+	// set, `user` otherwise.
+	//
+	// The `bridge` and `tap` interfaces are checked for existence and
+	// permissions when the template is validated.
+	//
+	// **NB** Any backend other than `user` will automatically enable the QMP
+	// socket (see QMPEnable).
+	NetBackend string `mapstructure:"net_backend" required:"false"`
+	// The name of a pre-created tap device on the host, required when
+	// `net_backend` is `tap`. The tap device must be owned by the user
+	// running Packer, e.g. created with
+	// `ip tuntap add dev tap0 mode tap user $USER`.
+	NetTapDevice string `mapstructure:"net_tap_device" required:"false"`
+	// The socket mode when `net_backend` is `socket`, either `mcast` to join
+	// a multicast group shared by several VMs, or `udp` for a point to
+	// point link. Defaults to `mcast`.
+	NetSocketMode string `mapstructure:"net_socket_mode" required:"false"`
+	// The `host:port` address of the socket backend, required when
+	// `net_backend` is `socket`. For `mcast` this is the multicast group,
+	// e.g. `230.0.0.1:1234`, for `udp` this is the remote endpoint.
+	NetSocketAddress string `mapstructure:"net_socket_address" required:"false"`
+	// The local `host:port` to bind to, required when `net_socket_mode` is
+	// `udp`.
+	NetSocketLocalAddress string `mapstructure:"net_socket_local_address" required:"false"`
+	// The network interfaces to attach to the VM. This block can be
+	// repeated to attach several NICs, see
+	// [Network Interface Configuration](#network-interface-configuration).
+	//
+	// When set, `net_bridge` and the other `net_*` backend options cannot
+	// be used, and `net_device` is only used as the default `model` for the
+	// interfaces.
+	//
+	// In HCL2:
+	// ```hcl
+	//   network_interface {
+	//     model        = "virtio-net"
+	//     backend      = "user"
+	//     hostfwd      = ["tcp::8080-:80"]
+	//     communicator = true
+	//   }
+	//
+	//   network_interface {
+	//     model       = "e1000"
+	//     mac_address = "52:54:00:12:34:57"
+	//     backend     = "bridge"
+	//     bridge      = "virbr0"
+	//   }
+	// ```
+	//
+	// In JSON:
+	// ```json
+	//   "network_interface": [
+	//     {
+	//       "model": "virtio-net",
+	//       "backend": "user",
+	//       "hostfwd": ["tcp::8080-:80"],
+	//       "communicator": true
+	//     },
+	//     {
+	//       "model": "e1000",
+	//       "mac_address": "52:54:00:12:34:57",
+	//       "backend": "bridge",
+	//       "bridge": "virbr0"
+	//     }
+	//   ]
+	// ```
+	NetworkInterfaces []QemuNetworkInterface `mapstructure:"network_interface" required:"false"`
+	// The type of the bridge address to use as `http_ip` when the
+	// communicator interface is attached to a bridge. Allowed values are
+	// `ipv4`, `ipv6_global` and `ipv6_link_local`. By default, an IPv4
+	// address is used if the bridge has one, then a global IPv6 address, then
+	// a link-local IPv6 address.
+	//
+	// IPv6 addresses are enclosed in brackets in the `{{ .HTTPIP }}` template
+	// variable, so that `http://{{ .HTTPIP }}:{{ .HTTPPort }}/` is a valid
+	// URL. Link-local addresses have no zone, as the name of the guest
+	// interface is not known by the builder, it may have to be appended in
+	// the boot command, e.g. `http://[fe80::1%25eth0]:{{ .HTTPPort }}/`.
+	HTTPIPAddressType string `mapstructure:"http_ip_address_type" required:"false"`
+	// Additional ports to forward from the host to the guest, on top of the
+	// communicator port. This is only supported with user-mode networking.
+	// See [Port Forward Configuration](#port-forward-configuration).
+	//
+	// In HCL2:
+	// ```hcl
+	//   port_forwards {
+	//     guest_port = 8080
+	//   }
+	//
+	//   port_forwards {
+	//     guest_port    = 5432
+	//     host_port_min = 15432
+	//     host_port_max = 15532
+	//   }
+	// ```
+	//
+	// In JSON:
+	// ```json
+	//   "port_forwards": [
+	//     {
+	//       "guest_port": 8080
+	//     },
+	//     {
+	//       "guest_port": 5432,
+	//       "host_port_min": 15432,
+	//       "host_port_max": 15532
+	//     }
+	//   ]
+	// ```
+	PortForwards []QemuPortForward `mapstructure:"port_forwards" required:"false"`
+	// Isolate the VM from the host and the outside world, so that the build
+	// can't download anything that isn't served by Packer. This sets
+	// `restrict=on` on the user-mode network backends: the communicator and
+	// `port_forwards` keep working, but the guest can't open connections to
+	// the outside.
+	//
+	// The Packer HTTP server stays reachable from the guest at `10.0.2.100`,
+	// which `{{ .HTTPIP }}` is set to. The connections are forwarded to the
+	// host with `nc`, which must be installed on the host. Defaults to
+	// false.
+	IsolatedNetwork bool `mapstructure:"isolated_network" required:"false"`
+	// A list of `host:port` endpoints the guest is allowed to connect to
+	// when `isolated_network` is true, e.g. a package mirror or a proxy.
+	// They are reachable from the guest at `10.0.2.101`, `10.0.2.102`, and
+	// so on, in the order they are listed, on the same port.
+	//
+	// ```hcl
+	//   isolated_network_allowed_endpoints = ["mirror.example.com:80", "10.1.0.5:3128"]
+	// ```
+	//
+	// makes the mirror reachable at `10.0.2.101:80` and the proxy at
+	// `10.0.2.102:3128`.
+	IsolatedNetworkAllowedEndpoints []string `mapstructure:"isolated_network_allowed_endpoints" required:"false"`
+	// Capture the traffic of the communicator network interface in a pcap
+	// file, e.g. to debug an installer failing to fetch its preseed or
+	// kickstart file. Relative paths are relative to `output_directory`.
+	//
+	// The capture file is not part of the artifact, unless
+	// `network_capture_in_artifact` is set. Note that the output directory
+	// is deleted when the build fails, use `-on-error=abort` to keep it.
+	NetworkCaptureFile string `mapstructure:"network_capture_file" required:"false"`
+	// Keep the `network_capture_file` in the files of the artifact, so that
+	// post-processors get it. Defaults to false.
+	NetworkCaptureInArtifact bool `mapstructure:"network_capture_in_artifact" required:"false"`
 	// This is the path to the directory where the
 	// resulting virtual machine will be created. This may be relative or absolute.
 	// If relative, the path is relative to the working directory when packer
@@ -401,6 +706,14 @@ type Config struct {
 	// as an empty string is ignored. All values after the switch are
 	// concatenated with no separator.
 	//
+	// The `-object`, `-numa` and `-global` arguments of the builder options,
+	// like `memory_hugepages`, `numa`, `iothreads`, `network_capture_file` or
+	// `efi_secure_boot`, are added to the `qemuargs` ones instead of being
+	// replaced. When `qemuargs`
+	// sets `-device`, the devices of the network interfaces are added back,
+	// and so are the devices of the disks, unless `qemuargs` sets `-drive`
+	// too.
+	//
 	// ~> **Warning:** The qemu command line allows extreme flexibility, so
 	// beware of conflicting arguments causing failures of your run.
 	// For instance adding a "--drive" or "--device" override will mean that
@@ -537,9 +850,9 @@ type Config struct {
 	// 	`qemu-img resize -f $format -foo bar $sourcepath $size`
 	QemuImgArgs QemuImgArgs `mapstructure:"qemu_img_args" required:"false"`
 	// The name of the Qemu binary to look for. This
-	// defaults to qemu-system-x86_64, but may need to be changed for
-	// some platforms. For example qemu-kvm, or qemu-system-i386 may be a
-	// better choice for some systems.
+	// defaults to qemu-system-x86_64, or the binary of the `architecture`,
+	// but may need to be changed for some platforms. For example qemu-kvm,
+	// or qemu-system-i386 may be a better choice for some systems.
 	QemuBinary string `mapstructure:"qemu_binary" required:"false"`
 	// Enable QMP socket. Location is specified by `qmp_socket_path`. Defaults
 	// to false.
@@ -547,6 +860,66 @@ type Config struct {
 	// QMP Socket Path when `qmp_enable` is true. Defaults to
 	// `output_directory`/`vm_name`.monitor.
 	QMPSocketPath string `mapstructure:"qmp_socket_path" required:"false"`
+	// Save a checkpoint of the VM, its memory included, after these points
+	// of the build, so a failed build can be resumed from it with
+	// `resume_from`: `boot_command`, once the boot command is typed, and
+	// `provision`, once all the provisioners ran.
+	//
+	// Checkpoints can't be saved after a single provisioner, like a Windows
+	// update one: Packer runs all the provisioners in a single step of the
+	// builder, and would run them all again in a resumed build. There is no
+	// `-on-error=checkpoint` mode either, the checkpoints are only saved
+	// with `checkpoint_after`, whatever the `-on-error` option.
+	//
+	// Checkpoints are internal snapshots saved with the `savevm` monitor
+	// command, which automatically enables the QMP socket. They require the
+	// `qcow2` format, and are not supported with `efi_boot` or `vtpm`. When
+	// a checkpoint was saved, the output directory is kept if the build
+	// fails. The checkpoints are deleted from the disks once the build
+	// succeeds.
+	//
+	// With a temporary SSH key pair, the private key is saved with the
+	// checkpoints as `vm_name`.checkpoint-key in the output directory, and
+	// the resumed build connects with it, as it is the key the restored
+	// guest authorizes. It is deleted with the checkpoints.
+	CheckpointAfter []string `mapstructure:"checkpoint_after" required:"false"`
+	// Resume a failed build from its checkpoint saved after this point, see
+	// `checkpoint_after`. The VM is started from the checkpoint in the disks
+	// of `output_directory`, and the steps up to the checkpoint are skipped.
+	// The rest of the configuration must be left unchanged.
+	ResumeFrom string `mapstructure:"resume_from" required:"false"`
+	// Add a virtio-serial channel for the QEMU guest agent
+	// (`org.qemu.guest_agent.0`) to the VM. Defaults to false.
+	//
+	// When the guest agent is running in the guest, the builder uses it to
+	// discover the address of the guest on non user-mode networks, which
+	// also works when the host has no ARP entry for the guest, or when the
+	// guest is on a routed network.
+	GuestAgentEnable bool `mapstructure:"guest_agent_enable" required:"false"`
+	// Guest agent socket path when `guest_agent_enable` is true. Defaults to
+	// `output_directory`/`vm_name`.qga.
+	GuestAgentSocketPath string `mapstructure:"guest_agent_socket_path" required:"false"`
+	// How to discover the address of the guest when the communicator
+	// interface is not using user-mode networking. Allowed values are:
+	//
+	//   - `arp`: look up the MAC address of the communicator interface in the
+	//     ARP table of the host, then in its IPv6 neighbor table. Only works
+	//     with the `bridge` and `tap` backends.
+	//   - `dhcp_leases`: look up the MAC address of the communicator
+	//     interface in a DHCP lease file, see `dhcp_leases_file`.
+	//   - `guest_agent`: ask the QEMU guest agent. This enables
+	//     `guest_agent_enable`.
+	//   - `static`: use `ssh_host` or `winrm_host`, which must be set.
+	//
+	// By default, the guest agent is asked if `guest_agent_enable` is true,
+	// then the ARP table of the host is used.
+	GuestAddressSource string `mapstructure:"guest_address_source" required:"false"`
+	// The DHCP lease file to read when `guest_address_source` is
+	// `dhcp_leases`. Both the dnsmasq lease file format and the libvirt
+	// JSON status file format are supported. Defaults to
+	// `/var/lib/libvirt/dnsmasq/<bridge>.status`, which is the status file
+	// of the libvirt network using the communicator interface bridge.
+	DHCPLeasesFile string `mapstructure:"dhcp_leases_file" required:"false"`
 	// If true, do not pass a -display option
 	// to qemu, allowing it to choose the default. This may be needed when running
 	// under macOS, and getting errors about sdl not being available.
@@ -586,7 +959,8 @@ type Config struct {
 	// The interface to use for the CDROM device which contains the ISO image.
 	// Allowed values include any of `ide`, `scsi`, `virtio` or
 	// `virtio-scsi`. The Qemu builder uses `virtio` by default.
-	// Some ARM64 images require `virtio-scsi`.
+	// Some ARM64 images require `virtio-scsi`, which is the default for the
+	// `aarch64`, `riscv64` and `s390x` architectures.
 	CDROMInterface string `mapstructure:"cdrom_interface" required:"false"`
 	// Use a virtual (emulated) TPM device to expose to the VM.
 	VTPM bool `mapstructure:"vtpm" required:"false"`
@@ -595,6 +969,31 @@ type Config struct {
 	// By default, we use version 2.0 of the TPM specs for the emulated TPM,
 	// if you want to force version 1.2, set this option to true.
 	VTPMUseTPM1 bool `mapstructure:"use_tpm1" required:"false"`
+	// Copy the state of the emulated TPM to the `vtpm` directory of
+	// `output_directory` once the VM is shut down, and list it in the
+	// artifact. This keeps the secrets sealed to the TPM during the build,
+	// e.g. by BitLocker or `systemd-cryptenroll`, usable at deploy time.
+	//
+	// By default the TPM state is discarded at the end of the build.
+	VTPMPersistState bool `mapstructure:"vtpm_persist_state" required:"false"`
+	// A TPM state directory to start the emulated TPM from instead of a
+	// blank TPM, e.g. the `vtpm` directory persisted by a previous build with
+	// `vtpm_persist_state`. The directory is copied, and left untouched.
+	VTPMStateSource string `mapstructure:"vtpm_state_source" required:"false"`
+	// Manufacture the emulated TPM with `swtpm_setup` before the VM starts,
+	// creating its EK and platform certificates, like a physical TPM. This
+	// requires `swtpm_setup` and a configured certificate authority, usually
+	// `swtpm_localca`. A state from `vtpm_state_source` that is already
+	// manufactured is left untouched.
+	VTPMManufacture bool `mapstructure:"vtpm_manufacture" required:"false"`
+	// The PCR banks to activate when manufacturing a TPM 2.0, among `sha1`,
+	// `sha256`, `sha384`, `sha512` and `sm3-256`. Defaults to the banks of
+	// `swtpm_setup`.
+	VTPMPCRBanks []string `mapstructure:"vtpm_pcr_banks" required:"false"`
+	// The log level of swtpm. Its logs are written to the Packer log when
+	// `PACKER_LOG` is set, and shown when it fails. Defaults to the swtpm
+	// log level.
+	VTPMLogLevel int `mapstructure:"vtpm_log_level" required:"false"`
 	// The TPM device type to inject in the qemu command-line
 	//
 	// This is required to be specified for some platforms, as the device has to
@@ -606,6 +1005,17 @@ type Config struct {
 	//  * ARM: tpm-tis-device
 	//  * PPC (p-series): tpm-spapr
 	TPMType string `mapstructure:"tpm_device_type" required:"false"`
+	// Host directories to share with the guest, which is faster than
+	// uploading them with the file provisioner. See the [Shared Folder
+	// Configuration](#shared-folder-configuration) section.
+	//
+	// With virtiofs, the memory of the VM is allocated from shared memory,
+	// which requires QEMU 5.0 or later.
+	SharedFolders []SharedFolder `mapstructure:"shared_folders" required:"false"`
+	// How the folders are shared, `virtiofs` or `9p`. Defaults to
+	// `virtiofs` when `virtiofsd` is found in the `PATH` or in the
+	// directories distributions install it in, and to `9p` otherwise.
+	SharedFoldersBackend string `mapstructure:"shared_folders_backend" required:"false"`
 	// This is an array of tuples of boot commands, to type when the virtual
 	// machine is booted. The first element of the tuple is the actual boot
 	// command. The second element of the tuple, which is optional, is a
@@ -645,6 +1055,22 @@ type Config struct {
 	// }
 	// ```
 	BootSteps [][]string `mapstructure:"boot_steps" required:"false"`
+	// Wait for the guest to fetch this path from the Packer HTTP server
+	// before typing the rest of the boot steps, e.g. `/ks.cfg`. This makes
+	// sure the installer got its preseed or kickstart file before the next
+	// key presses, instead of relying on `<wait>` delays.
+	//
+	// The HTTP server shows every request it serves in the output of the
+	// build, and the paths the guest fetched before the provisioners run are available
+	// as the `HTTPFetchedPaths` build variable, a comma separated list.
+	HTTPWaitForPath string `mapstructure:"http_wait_for_path" required:"false"`
+	// The position, starting at 1, of the boot step after which to wait for
+	// `http_wait_for_path` to be fetched. When using `boot_command`, the
+	// whole command is the first step. Defaults to 1.
+	HTTPWaitForPathAfterStep int `mapstructure:"http_wait_for_path_after_step" required:"false"`
+	// The amount of time to wait for `http_wait_for_path` to be fetched.
+	// Defaults to 10m.
+	HTTPWaitForPathTimeout time.Duration `mapstructure:"http_wait_for_path_timeout" required:"false"`
 	// The CPU model is what will be used by qemu for booting the virtual machine
 	// and determine which features of a specific model/family of processors
 	// is supported.
@@ -654,7 +1080,9 @@ type Config struct {
 	//
 	// The default value here is that no cpu option will be passed through to qemu,
 	// therefore it will default to whichever CPU model is the default for the
-	// targetted system (on x86_64 for example, it will be qemu64)
+	// targetted system (on x86_64 for example, it will be qemu64). For the
+	// other architectures, it defaults to `host` with KVM, and to a model
+	// able to run 64-bit distributions with TCG, e.g. `max` for `aarch64`.
 	//
 	// NOTE: RHEL9 removed support for `qemu64` in their distributed qemu package,
 	// forcing users of RHEL9 on x86_64 systems to define this. "host" is a
@@ -717,15 +1145,34 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	if c.DiskDiscard == "" {
 		c.DiskDiscard = "ignore"
+		if c.TrimBeforeShutdown {
+			c.DiskDiscard = "unmap"
+		}
 	}
 
 	if c.DetectZeroes == "" {
 		c.DetectZeroes = "off"
+		if c.TrimBeforeShutdown {
+			c.DetectZeroes = "unmap"
+		}
+	}
+
+	if c.Architecture == "" {
+		c.Architecture = "x86_64"
+	}
+	arch, ok := guestArchitectures[c.Architecture]
+	if !ok {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("unknown architecture %q, allowed values are %s", c.Architecture, guestArchitectureNames()))
+		arch = guestArchitectures["x86_64"]
 	}
 
 	if c.Accelerator == "" {
 		if runtime.GOOS == "windows" {
 			c.Accelerator = "tcg"
+		} else if !arch.native() {
+			// KVM can only run guests of the host architecture.
+			c.Accelerator = "tcg"
 		} else {
 			// /dev/kvm is a kernel module that may be loaded if kvm is
 			// installed and the host supports VT-x extensions. To make sure
@@ -744,7 +1191,15 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	if c.MachineType == "" {
-		c.MachineType = "pc"
+		c.MachineType = arch.MachineType
+	}
+
+	if c.CPUModel == "" {
+		c.CPUModel = arch.cpuModel(c.Accelerator)
+	}
+
+	if c.CDROMInterface == "" {
+		c.CDROMInterface = arch.CDROMInterface
 	}
 
 	if c.OutputDir == "" {
@@ -752,7 +1207,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	if c.QemuBinary == "" {
-		c.QemuBinary = "qemu-system-x86_64"
+		c.QemuBinary = arch.QemuBinary
 	}
 
 	if c.MemorySize < 10 {
@@ -784,7 +1239,49 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.TPMType = "tpm-tis"
 	}
 
-	c.QemuEFIBootConfig.loadDefaults()
+	if (c.VTPMPersistState || c.VTPMStateSource != "" || c.VTPMManufacture) && !c.VTPM {
+		errs = packersdk.MultiErrorAppend(errs,
+			errors.New("vtpm_persist_state, vtpm_state_source and vtpm_manufacture require vtpm to be enabled"))
+	}
+	if len(c.VTPMPCRBanks) > 0 {
+		if !c.VTPMManufacture || c.VTPMUseTPM1 {
+			errs = packersdk.MultiErrorAppend(errs,
+				errors.New("vtpm_pcr_banks requires vtpm_manufacture with a TPM 2.0"))
+		}
+		for _, bank := range c.VTPMPCRBanks {
+			if !containsString(vtpmPCRBanks, bank) {
+				errs = packersdk.MultiErrorAppend(errs,
+					fmt.Errorf("unknown PCR bank %s in vtpm_pcr_banks, must be one of %s",
+						bank, strings.Join(vtpmPCRBanks, ", ")))
+			}
+		}
+	}
+	if c.VTPMLogLevel < 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("vtpm_log_level must be positive"))
+	}
+	if c.VTPMStateSource != "" {
+		if info, err := os.Stat(c.VTPMStateSource); err != nil {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("vtpm_state_source is not accessible: %s", err))
+		} else if !info.IsDir() {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("vtpm_state_source %s is not a directory", c.VTPMStateSource))
+		}
+	}
+
+	if arch.RequireEFI && c.Firmware == "" {
+		c.QemuEFIBootConfig.EnableEFI = true
+	}
+	errs = packersdk.MultiErrorAppend(errs, c.QemuEFIBootConfig.EFISecureBoot.Prepare(c.Architecture, c.MachineType)...)
+	firmwareReq := firmwareRequirements{
+		Architecture: c.Architecture,
+		MachineType:  c.MachineType,
+		Features:     c.QemuEFIBootConfig.EFISecureBoot.firmwareFeatures(),
+		SMM:          c.secureBootSMM(),
+	}
+	if err := c.QemuEFIBootConfig.loadDefaults(arch, firmwareReq, firmwareDescriptorDirs(c.QemuBinary)); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
 
 	errs = packersdk.MultiErrorAppend(errs, c.FloppyConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CDConfig.Prepare(&c.ctx)...)
@@ -806,6 +1303,27 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packersdk.MultiErrorAppend(errs, isoErrs...)
 
 	errs = packersdk.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
+
+	if c.HTTPWaitForPath != "" {
+		if c.HTTPDir == "" && len(c.HTTPContent) == 0 {
+			errs = packersdk.MultiErrorAppend(errs,
+				errors.New("http_wait_for_path requires http_directory or http_content to be set"))
+		}
+		if c.HTTPWaitForPathAfterStep == 0 {
+			c.HTTPWaitForPathAfterStep = 1
+		}
+		if c.HTTPWaitForPathTimeout == 0 {
+			c.HTTPWaitForPathTimeout = 10 * time.Minute
+		}
+		bootSteps := len(c.BootSteps)
+		if len(c.BootCommand) > 0 {
+			bootSteps = 1
+		}
+		if c.HTTPWaitForPathAfterStep < 1 || c.HTTPWaitForPathAfterStep > bootSteps {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("http_wait_for_path_after_step must be between 1 and the number of boot steps (%d)", bootSteps))
+		}
+	}
 	commConfigWarnings, es := c.CommConfig.Prepare(&c.ctx)
 	if len(es) > 0 {
 		errs = packersdk.MultiErrorAppend(errs, es...)
@@ -822,6 +1340,20 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.DiskCompression = false
 	}
 
+	if c.LayeredOutput {
+		c.UseBackingFile = true
+		if c.LayerParent == "" && len(c.ISOUrls) > 0 {
+			c.LayerParent = path.Base(strings.SplitN(c.ISOUrls[0], "?", 2)[0])
+		}
+		if c.LayerVerifyParent && !filepath.IsAbs(c.LayerParent) {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("layer_verify_parent requires an absolute layer_parent, got %q", c.LayerParent))
+		}
+	} else if c.LayerParent != "" || c.LayerVerifyParent {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("layer_parent and layer_verify_parent require layered_output"))
+	}
+
 	if c.UseBackingFile {
 		c.SkipCompaction = true
 		if !(c.DiskImage && c.Format == "qcow2") {
@@ -830,6 +1362,23 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
+	if c.BaseImageCache {
+		if !(c.DiskImage && c.Format == "qcow2") || c.UseBackingFile {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("base_image_cache can only be enabled for QCOW2 images when disk_image is true, and not with use_backing_file"))
+		}
+		if c.BaseImageCacheDir == "" {
+			cacheDir, err := packersdk.CachePath("qemu-base-images")
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, err)
+			}
+			c.BaseImageCacheDir = cacheDir
+		}
+	} else if c.BaseImageCacheDir != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("base_image_cache_dir requires base_image_cache"))
+	}
+
 	if c.SkipResizeDisk && !(c.DiskImage) {
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("skip_resize_disk can only be used when disk_image is true"))
@@ -874,7 +1423,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		errs = packersdk.MultiErrorAppend(errs, errors.New("threads must be a positive number"))
 	}
 
-	if !c.PackerForce {
+	if !c.PackerForce && c.ResumeFrom == "" {
 		if _, err := os.Stat(c.OutputDir); err == nil {
 			errs = packersdk.MultiErrorAppend(
 				errs,
@@ -902,12 +1451,40 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			errs, fmt.Errorf("net_bridge is only supported in Linux based OSes"))
 	}
 
+	errs = packersdk.MultiErrorAppend(errs, c.prepareNetworkInterfaces()...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareGuestAddressSource()...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareIsolatedNetwork()...)
+	if c.IsolatedNetwork {
+		if _, err := exec.LookPath("nc"); err != nil {
+			warnings = append(warnings, "isolated_network forwards the guest connections with nc, "+
+				"which was not found in PATH. The guest won't be able to reach the HTTP server.")
+		}
+	}
+
+	switch c.HTTPIPAddressType {
+	case "", "ipv4", "ipv6_global", "ipv6_link_local":
+	default:
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("http_ip_address_type must be one of ipv4, ipv6_global or ipv6_link_local"))
+	}
+
 	if len(c.BootCommand) > 0 && len(c.BootSteps) > 0 {
 		errs = packersdk.MultiErrorAppend(errs,
 			fmt.Errorf("boot_command and boot_steps cannot be used together"))
 	}
 
-	if c.NetBridge != "" || c.VNCUsePassword {
+	errs = packersdk.MultiErrorAppend(errs, c.prepareCheckpoints()...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareReproducible()...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareTrim()...)
+	sharedFoldersWarnings, sharedFoldersErrs := c.prepareSharedFolders()
+	warnings = append(warnings, sharedFoldersWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, sharedFoldersErrs...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareMemory()...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareTuning()...)
+
+	// The guest address lookup for non user-mode networking needs QMP to
+	// get the communicator interface MAC address.
+	if commNIC := c.communicatorInterface(); commNIC.Backend != "user" || c.VNCUsePassword {
 		c.QMPEnable = true
 	}
 
@@ -916,6 +1493,30 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.QMPSocketPath = filepath.Join(c.OutputDir, socketName)
 	}
 
+	if c.NetworkCaptureFile != "" {
+		if !filepath.IsAbs(c.NetworkCaptureFile) {
+			c.NetworkCaptureFile = filepath.Join(c.OutputDir, c.NetworkCaptureFile)
+		}
+		if c.communicatorInterface().Backend == "none" {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("network_capture_file requires the communicator network interface to have a backend"))
+		}
+	}
+
+	if c.CommConfig.Comm.Type == "qga" {
+		c.GuestAgentEnable = true
+	}
+
+	if c.GuestAgentEnable && c.GuestAgentSocketPath == "" {
+		socketName := fmt.Sprintf("%s.qga", c.VMName)
+		c.GuestAgentSocketPath = filepath.Join(c.OutputDir, socketName)
+	}
+
+	if c.CommConfig.Comm.Type == "serial" && c.CommConfig.SerialSocketPath == "" {
+		socketName := fmt.Sprintf("%s.console", c.VMName)
+		c.CommConfig.SerialSocketPath = filepath.Join(c.OutputDir, socketName)
+	}
+
 	if c.QemuArgs == nil {
 		c.QemuArgs = make([][]string, 0)
 	}
@@ -10,145 +10,205 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName           *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType         *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion         *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug               *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce               *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError             *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars            map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars       []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	HTTPDir                   *string           `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
-	HTTPContent               map[string]string `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
-	HTTPPortMin               *int              `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
-	HTTPPortMax               *int              `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
-	HTTPAddress               *string           `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
-	HTTPInterface             *string           `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
-	HTTPNetworkProtocol       *string           `mapstructure:"http_network_protocol" cty:"http_network_protocol" hcl:"http_network_protocol"`
-	ISOChecksum               *string           `mapstructure:"iso_checksum" required:"true" cty:"iso_checksum" hcl:"iso_checksum"`
-	RawSingleISOUrl           *string           `mapstructure:"iso_url" required:"true" cty:"iso_url" hcl:"iso_url"`
-	ISOUrls                   []string          `mapstructure:"iso_urls" cty:"iso_urls" hcl:"iso_urls"`
-	TargetPath                *string           `mapstructure:"iso_target_path" cty:"iso_target_path" hcl:"iso_target_path"`
-	TargetExtension           *string           `mapstructure:"iso_target_extension" cty:"iso_target_extension" hcl:"iso_target_extension"`
-	BootGroupInterval         *string           `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
-	BootWait                  *string           `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	BootCommand               []string          `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
-	DisableVNC                *bool             `mapstructure:"disable_vnc" cty:"disable_vnc" hcl:"disable_vnc"`
-	BootKeyInterval           *string           `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
-	ShutdownCommand           *string           `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
-	ShutdownTimeout           *string           `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
-	Type                      *string           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int              `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string           `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string           `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string           `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string           `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string           `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int              `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string          `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool             `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string          `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string           `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string           `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool             `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string           `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string           `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool             `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool             `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int              `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string           `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int              `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool             `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string           `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string           `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool             `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string           `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string           `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string           `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string           `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int              `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string           `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string           `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string           `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string           `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string          `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string          `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte            `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte            `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string           `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string           `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string           `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool             `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int              `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string           `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool             `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	HostPortMin               *int              `mapstructure:"host_port_min" required:"false" cty:"host_port_min" hcl:"host_port_min"`
-	HostPortMax               *int              `mapstructure:"host_port_max" required:"false" cty:"host_port_max" hcl:"host_port_max"`
-	SkipNatMapping            *bool             `mapstructure:"skip_nat_mapping" required:"false" cty:"skip_nat_mapping" hcl:"skip_nat_mapping"`
-	SSHHostPortMin            *int              `mapstructure:"ssh_host_port_min" required:"false" cty:"ssh_host_port_min" hcl:"ssh_host_port_min"`
-	SSHHostPortMax            *int              `mapstructure:"ssh_host_port_max" cty:"ssh_host_port_max" hcl:"ssh_host_port_max"`
-	FloppyFiles               []string          `mapstructure:"floppy_files" cty:"floppy_files" hcl:"floppy_files"`
-	FloppyDirectories         []string          `mapstructure:"floppy_dirs" cty:"floppy_dirs" hcl:"floppy_dirs"`
-	FloppyContent             map[string]string `mapstructure:"floppy_content" cty:"floppy_content" hcl:"floppy_content"`
-	FloppyLabel               *string           `mapstructure:"floppy_label" cty:"floppy_label" hcl:"floppy_label"`
-	CDFiles                   []string          `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
-	CDContent                 map[string]string `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
-	CDLabel                   *string           `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
-	CpuCount                  *int              `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
-	SocketCount               *int              `mapstructure:"sockets" required:"false" cty:"sockets" hcl:"sockets"`
-	CoreCount                 *int              `mapstructure:"cores" required:"false" cty:"cores" hcl:"cores"`
-	ThreadCount               *int              `mapstructure:"threads" required:"false" cty:"threads" hcl:"threads"`
-	EnableEFI                 *bool             `mapstructure:"efi_boot" required:"false" cty:"efi_boot" hcl:"efi_boot"`
-	OVMFCode                  *string           `mapstructure:"efi_firmware_code" required:"false" cty:"efi_firmware_code" hcl:"efi_firmware_code"`
-	OVMFVars                  *string           `mapstructure:"efi_firmware_vars" required:"false" cty:"efi_firmware_vars" hcl:"efi_firmware_vars"`
-	DropEFIVars               *bool             `mapstructure:"efi_drop_efivars" required:"false" cty:"efi_drop_efivars" hcl:"efi_drop_efivars"`
-	ISOSkipCache              *bool             `mapstructure:"iso_skip_cache" required:"false" cty:"iso_skip_cache" hcl:"iso_skip_cache"`
-	Accelerator               *string           `mapstructure:"accelerator" required:"false" cty:"accelerator" hcl:"accelerator"`
-	AdditionalDiskSize        []string          `mapstructure:"disk_additional_size" required:"false" cty:"disk_additional_size" hcl:"disk_additional_size"`
-	Firmware                  *string           `mapstructure:"firmware" required:"false" cty:"firmware" hcl:"firmware"`
-	PFlash                    *bool             `mapstructure:"use_pflash" required:"false" cty:"use_pflash" hcl:"use_pflash"`
-	DiskInterface             *string           `mapstructure:"disk_interface" required:"false" cty:"disk_interface" hcl:"disk_interface"`
-	DiskSize                  *string           `mapstructure:"disk_size" required:"false" cty:"disk_size" hcl:"disk_size"`
-	SkipResizeDisk            *bool             `mapstructure:"skip_resize_disk" required:"false" cty:"skip_resize_disk" hcl:"skip_resize_disk"`
-	DiskCache                 *string           `mapstructure:"disk_cache" required:"false" cty:"disk_cache" hcl:"disk_cache"`
-	DiskDiscard               *string           `mapstructure:"disk_discard" required:"false" cty:"disk_discard" hcl:"disk_discard"`
-	DetectZeroes              *string           `mapstructure:"disk_detect_zeroes" required:"false" cty:"disk_detect_zeroes" hcl:"disk_detect_zeroes"`
-	SkipCompaction            *bool             `mapstructure:"skip_compaction" required:"false" cty:"skip_compaction" hcl:"skip_compaction"`
-	DiskCompression           *bool             `mapstructure:"disk_compression" required:"false" cty:"disk_compression" hcl:"disk_compression"`
-	Format                    *string           `mapstructure:"format" required:"false" cty:"format" hcl:"format"`
-	Headless                  *bool             `mapstructure:"headless" required:"false" cty:"headless" hcl:"headless"`
-	DiskImage                 *bool             `mapstructure:"disk_image" required:"false" cty:"disk_image" hcl:"disk_image"`
-	UseBackingFile            *bool             `mapstructure:"use_backing_file" required:"false" cty:"use_backing_file" hcl:"use_backing_file"`
-	MachineType               *string           `mapstructure:"machine_type" required:"false" cty:"machine_type" hcl:"machine_type"`
-	MemorySize                *int              `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
-	NetDevice                 *string           `mapstructure:"net_device" required:"false" cty:"net_device" hcl:"net_device"`
-	NetBridge                 *string           `mapstructure:"net_bridge" required:"false" cty:"net_bridge" hcl:"net_bridge"`
-	OutputDir                 *string           `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
-	QemuArgs                  [][]string        `mapstructure:"qemuargs" required:"false" cty:"qemuargs" hcl:"qemuargs"`
-	QemuImgArgs               *FlatQemuImgArgs  `mapstructure:"qemu_img_args" required:"false" cty:"qemu_img_args" hcl:"qemu_img_args"`
-	QemuBinary                *string           `mapstructure:"qemu_binary" required:"false" cty:"qemu_binary" hcl:"qemu_binary"`
-	QMPEnable                 *bool             `mapstructure:"qmp_enable" required:"false" cty:"qmp_enable" hcl:"qmp_enable"`
-	QMPSocketPath             *string           `mapstructure:"qmp_socket_path" required:"false" cty:"qmp_socket_path" hcl:"qmp_socket_path"`
-	UseDefaultDisplay         *bool             `mapstructure:"use_default_display" required:"false" cty:"use_default_display" hcl:"use_default_display"`
-	VGA                       *string           `mapstructure:"vga" required:"false" cty:"vga" hcl:"vga"`
-	Display                   *string           `mapstructure:"display" required:"false" cty:"display" hcl:"display"`
-	VNCBindAddress            *string           `mapstructure:"vnc_bind_address" required:"false" cty:"vnc_bind_address" hcl:"vnc_bind_address"`
-	VNCUsePassword            *bool             `mapstructure:"vnc_use_password" required:"false" cty:"vnc_use_password" hcl:"vnc_use_password"`
-	VNCPassword               *string           `mapstructure:"vnc_password" required:"false" cty:"vnc_password" hcl:"vnc_password"`
-	VNCPortMin                *int              `mapstructure:"vnc_port_min" required:"false" cty:"vnc_port_min" hcl:"vnc_port_min"`
-	VNCPortMax                *int              `mapstructure:"vnc_port_max" cty:"vnc_port_max" hcl:"vnc_port_max"`
-	VMName                    *string           `mapstructure:"vm_name" required:"false" cty:"vm_name" hcl:"vm_name"`
-	CDROMInterface            *string           `mapstructure:"cdrom_interface" required:"false" cty:"cdrom_interface" hcl:"cdrom_interface"`
-	VTPM                      *bool             `mapstructure:"vtpm" required:"false" cty:"vtpm" hcl:"vtpm"`
-	VTPMUseTPM1               *bool             `mapstructure:"use_tpm1" required:"false" cty:"use_tpm1" hcl:"use_tpm1"`
-	TPMType                   *string           `mapstructure:"tpm_device_type" required:"false" cty:"tpm_device_type" hcl:"tpm_device_type"`
-	BootSteps                 [][]string        `mapstructure:"boot_steps" required:"false" cty:"boot_steps" hcl:"boot_steps"`
-	CPUModel                  *string           `mapstructure:"cpu_model" required:"false" cty:"cpu_model" hcl:"cpu_model"`
-	RunOnce                   *bool             `mapstructure:"run_once" cty:"run_once" hcl:"run_once"`
+	PackerBuildName                 *string                    `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType               *string                    `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion               *string                    `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug                     *bool                      `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce                     *bool                      `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError                   *string                    `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars                  map[string]string          `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars             []string                   `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HTTPDir                         *string                    `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
+	HTTPContent                     map[string]string          `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
+	HTTPPortMin                     *int                       `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
+	HTTPPortMax                     *int                       `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
+	HTTPAddress                     *string                    `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
+	HTTPInterface                   *string                    `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	HTTPNetworkProtocol             *string                    `mapstructure:"http_network_protocol" cty:"http_network_protocol" hcl:"http_network_protocol"`
+	ISOChecksum                     *string                    `mapstructure:"iso_checksum" required:"true" cty:"iso_checksum" hcl:"iso_checksum"`
+	RawSingleISOUrl                 *string                    `mapstructure:"iso_url" required:"true" cty:"iso_url" hcl:"iso_url"`
+	ISOUrls                         []string                   `mapstructure:"iso_urls" cty:"iso_urls" hcl:"iso_urls"`
+	TargetPath                      *string                    `mapstructure:"iso_target_path" cty:"iso_target_path" hcl:"iso_target_path"`
+	TargetExtension                 *string                    `mapstructure:"iso_target_extension" cty:"iso_target_extension" hcl:"iso_target_extension"`
+	BootGroupInterval               *string                    `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait                        *string                    `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand                     []string                   `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	DisableVNC                      *bool                      `mapstructure:"disable_vnc" cty:"disable_vnc" hcl:"disable_vnc"`
+	BootKeyInterval                 *string                    `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	ShutdownCommand                 *string                    `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
+	ShutdownTimeout                 *string                    `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	Type                            *string                    `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect              *string                    `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                         *string                    `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                         *int                       `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername                     *string                    `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword                     *string                    `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName                  *string                    `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName         *string                    `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType         *string                    `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits         *int                       `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                      []string                   `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys          *bool                      `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos                     []string                   `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile               *string                    `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile              *string                    `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                          *bool                      `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                      *string                    `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout                  *string                    `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth                    *bool                      `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding       *bool                      `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts            *int                       `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost                  *string                    `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort                  *int                       `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth             *bool                      `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername              *string                    `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword              *string                    `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive           *bool                      `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile        *string                    `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile       *string                    `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod           *string                    `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost                    *string                    `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort                    *int                       `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername                *string                    `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword                *string                    `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval            *string                    `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout             *string                    `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels                []string                   `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels                 []string                   `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey                    []byte                     `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey                   []byte                     `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                       *string                    `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword                   *string                    `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                       *string                    `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy                    *bool                      `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                       *int                       `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout                    *string                    `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL                     *bool                      `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure                   *bool                      `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM                    *bool                      `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	HostPortMin                     *int                       `mapstructure:"host_port_min" required:"false" cty:"host_port_min" hcl:"host_port_min"`
+	HostPortMax                     *int                       `mapstructure:"host_port_max" required:"false" cty:"host_port_max" hcl:"host_port_max"`
+	SkipNatMapping                  *bool                      `mapstructure:"skip_nat_mapping" required:"false" cty:"skip_nat_mapping" hcl:"skip_nat_mapping"`
+	SSHHostPortMin                  *int                       `mapstructure:"ssh_host_port_min" required:"false" cty:"ssh_host_port_min" hcl:"ssh_host_port_min"`
+	SSHHostPortMax                  *int                       `mapstructure:"ssh_host_port_max" cty:"ssh_host_port_max" hcl:"ssh_host_port_max"`
+	QGATimeout                      *string                    `mapstructure:"qga_timeout" required:"false" cty:"qga_timeout" hcl:"qga_timeout"`
+	SerialSocketPath                *string                    `mapstructure:"serial_socket_path" required:"false" cty:"serial_socket_path" hcl:"serial_socket_path"`
+	SerialUsername                  *string                    `mapstructure:"serial_username" required:"false" cty:"serial_username" hcl:"serial_username"`
+	SerialPassword                  *string                    `mapstructure:"serial_password" required:"false" cty:"serial_password" hcl:"serial_password"`
+	SerialLoginPrompt               *string                    `mapstructure:"serial_login_prompt" required:"false" cty:"serial_login_prompt" hcl:"serial_login_prompt"`
+	SerialPasswordPrompt            *string                    `mapstructure:"serial_password_prompt" required:"false" cty:"serial_password_prompt" hcl:"serial_password_prompt"`
+	SerialShellPrompt               *string                    `mapstructure:"serial_shell_prompt" required:"false" cty:"serial_shell_prompt" hcl:"serial_shell_prompt"`
+	SerialTimeout                   *string                    `mapstructure:"serial_timeout" required:"false" cty:"serial_timeout" hcl:"serial_timeout"`
+	CommunicatorTransport           *string                    `mapstructure:"communicator_transport" required:"false" cty:"communicator_transport" hcl:"communicator_transport"`
+	VsockCIDMin                     *int                       `mapstructure:"vsock_cid_min" required:"false" cty:"vsock_cid_min" hcl:"vsock_cid_min"`
+	VsockCIDMax                     *int                       `mapstructure:"vsock_cid_max" required:"false" cty:"vsock_cid_max" hcl:"vsock_cid_max"`
+	FloppyFiles                     []string                   `mapstructure:"floppy_files" cty:"floppy_files" hcl:"floppy_files"`
+	FloppyDirectories               []string                   `mapstructure:"floppy_dirs" cty:"floppy_dirs" hcl:"floppy_dirs"`
+	FloppyContent                   map[string]string          `mapstructure:"floppy_content" cty:"floppy_content" hcl:"floppy_content"`
+	FloppyLabel                     *string                    `mapstructure:"floppy_label" cty:"floppy_label" hcl:"floppy_label"`
+	CDFiles                         []string                   `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
+	CDContent                       map[string]string          `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
+	CDLabel                         *string                    `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
+	CpuCount                        *int                       `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
+	SocketCount                     *int                       `mapstructure:"sockets" required:"false" cty:"sockets" hcl:"sockets"`
+	CoreCount                       *int                       `mapstructure:"cores" required:"false" cty:"cores" hcl:"cores"`
+	ThreadCount                     *int                       `mapstructure:"threads" required:"false" cty:"threads" hcl:"threads"`
+	MemoryHugepages                 *bool                      `mapstructure:"memory_hugepages" required:"false" cty:"memory_hugepages" hcl:"memory_hugepages"`
+	MemoryHugepagesPath             *string                    `mapstructure:"memory_hugepages_path" required:"false" cty:"memory_hugepages_path" hcl:"memory_hugepages_path"`
+	MemoryPrealloc                  *bool                      `mapstructure:"memory_prealloc" required:"false" cty:"memory_prealloc" hcl:"memory_prealloc"`
+	NUMA                            *bool                      `mapstructure:"numa" required:"false" cty:"numa" hcl:"numa"`
+	MemoryBalloon                   *bool                      `mapstructure:"memory_balloon" required:"false" cty:"memory_balloon" hcl:"memory_balloon"`
+	MemoryMaxSize                   *int                       `mapstructure:"memory_max" required:"false" cty:"memory_max" hcl:"memory_max"`
+	MemorySlots                     *int                       `mapstructure:"memory_slots" required:"false" cty:"memory_slots" hcl:"memory_slots"`
+	VCPUPinning                     *string                    `mapstructure:"vcpu_pinning" required:"false" cty:"vcpu_pinning" hcl:"vcpu_pinning"`
+	IOThreads                       *int                       `mapstructure:"iothreads" required:"false" cty:"iothreads" hcl:"iothreads"`
+	EnableEFI                       *bool                      `mapstructure:"efi_boot" required:"false" cty:"efi_boot" hcl:"efi_boot"`
+	OVMFCode                        *string                    `mapstructure:"efi_firmware_code" required:"false" cty:"efi_firmware_code" hcl:"efi_firmware_code"`
+	OVMFVars                        *string                    `mapstructure:"efi_firmware_vars" required:"false" cty:"efi_firmware_vars" hcl:"efi_firmware_vars"`
+	FirmwareFeatures                []string                   `mapstructure:"efi_firmware_features" required:"false" cty:"efi_firmware_features" hcl:"efi_firmware_features"`
+	EFISecureBoot                   *FlatEFISecureBootConfig   `mapstructure:"efi_secure_boot" required:"false" cty:"efi_secure_boot" hcl:"efi_secure_boot"`
+	DropEFIVars                     *bool                      `mapstructure:"efi_drop_efivars" required:"false" cty:"efi_drop_efivars" hcl:"efi_drop_efivars"`
+	ISOSkipCache                    *bool                      `mapstructure:"iso_skip_cache" required:"false" cty:"iso_skip_cache" hcl:"iso_skip_cache"`
+	Architecture                    *string                    `mapstructure:"architecture" required:"false" cty:"architecture" hcl:"architecture"`
+	Accelerator                     *string                    `mapstructure:"accelerator" required:"false" cty:"accelerator" hcl:"accelerator"`
+	AdditionalDiskSize              []string                   `mapstructure:"disk_additional_size" required:"false" cty:"disk_additional_size" hcl:"disk_additional_size"`
+	Firmware                        *string                    `mapstructure:"firmware" required:"false" cty:"firmware" hcl:"firmware"`
+	PFlash                          *bool                      `mapstructure:"use_pflash" required:"false" cty:"use_pflash" hcl:"use_pflash"`
+	DiskInterface                   *string                    `mapstructure:"disk_interface" required:"false" cty:"disk_interface" hcl:"disk_interface"`
+	DiskSize                        *string                    `mapstructure:"disk_size" required:"false" cty:"disk_size" hcl:"disk_size"`
+	SkipResizeDisk                  *bool                      `mapstructure:"skip_resize_disk" required:"false" cty:"skip_resize_disk" hcl:"skip_resize_disk"`
+	DiskCache                       *string                    `mapstructure:"disk_cache" required:"false" cty:"disk_cache" hcl:"disk_cache"`
+	DiskDiscard                     *string                    `mapstructure:"disk_discard" required:"false" cty:"disk_discard" hcl:"disk_discard"`
+	DetectZeroes                    *string                    `mapstructure:"disk_detect_zeroes" required:"false" cty:"disk_detect_zeroes" hcl:"disk_detect_zeroes"`
+	SkipCompaction                  *bool                      `mapstructure:"skip_compaction" required:"false" cty:"skip_compaction" hcl:"skip_compaction"`
+	DiskCompression                 *bool                      `mapstructure:"disk_compression" required:"false" cty:"disk_compression" hcl:"disk_compression"`
+	TrimBeforeShutdown              *bool                      `mapstructure:"trim_before_shutdown" required:"false" cty:"trim_before_shutdown" hcl:"trim_before_shutdown"`
+	TrimCommand                     *string                    `mapstructure:"trim_command" required:"false" cty:"trim_command" hcl:"trim_command"`
+	Format                          *string                    `mapstructure:"format" required:"false" cty:"format" hcl:"format"`
+	Headless                        *bool                      `mapstructure:"headless" required:"false" cty:"headless" hcl:"headless"`
+	DiskImage                       *bool                      `mapstructure:"disk_image" required:"false" cty:"disk_image" hcl:"disk_image"`
+	UseBackingFile                  *bool                      `mapstructure:"use_backing_file" required:"false" cty:"use_backing_file" hcl:"use_backing_file"`
+	BaseImageCache                  *bool                      `mapstructure:"base_image_cache" required:"false" cty:"base_image_cache" hcl:"base_image_cache"`
+	BaseImageCacheDir               *string                    `mapstructure:"base_image_cache_dir" required:"false" cty:"base_image_cache_dir" hcl:"base_image_cache_dir"`
+	LayeredOutput                   *bool                      `mapstructure:"layered_output" required:"false" cty:"layered_output" hcl:"layered_output"`
+	LayerParent                     *string                    `mapstructure:"layer_parent" required:"false" cty:"layer_parent" hcl:"layer_parent"`
+	LayerVerifyParent               *bool                      `mapstructure:"layer_verify_parent" required:"false" cty:"layer_verify_parent" hcl:"layer_verify_parent"`
+	Reproducible                    *bool                      `mapstructure:"reproducible" required:"false" cty:"reproducible" hcl:"reproducible"`
+	SourceDateEpoch                 *int64                     `mapstructure:"source_date_epoch" required:"false" cty:"source_date_epoch" hcl:"source_date_epoch"`
+	MachineType                     *string                    `mapstructure:"machine_type" required:"false" cty:"machine_type" hcl:"machine_type"`
+	MemorySize                      *int                       `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
+	NetDevice                       *string                    `mapstructure:"net_device" required:"false" cty:"net_device" hcl:"net_device"`
+	NetBridge                       *string                    `mapstructure:"net_bridge" required:"false" cty:"net_bridge" hcl:"net_bridge"`
+	NetBackend                      *string                    `mapstructure:"net_backend" required:"false" cty:"net_backend" hcl:"net_backend"`
+	NetTapDevice                    *string                    `mapstructure:"net_tap_device" required:"false" cty:"net_tap_device" hcl:"net_tap_device"`
+	NetSocketMode                   *string                    `mapstructure:"net_socket_mode" required:"false" cty:"net_socket_mode" hcl:"net_socket_mode"`
+	NetSocketAddress                *string                    `mapstructure:"net_socket_address" required:"false" cty:"net_socket_address" hcl:"net_socket_address"`
+	NetSocketLocalAddress           *string                    `mapstructure:"net_socket_local_address" required:"false" cty:"net_socket_local_address" hcl:"net_socket_local_address"`
+	NetworkInterfaces               []FlatQemuNetworkInterface `mapstructure:"network_interface" required:"false" cty:"network_interface" hcl:"network_interface"`
+	HTTPIPAddressType               *string                    `mapstructure:"http_ip_address_type" required:"false" cty:"http_ip_address_type" hcl:"http_ip_address_type"`
+	PortForwards                    []FlatQemuPortForward      `mapstructure:"port_forwards" required:"false" cty:"port_forwards" hcl:"port_forwards"`
+	IsolatedNetwork                 *bool                      `mapstructure:"isolated_network" required:"false" cty:"isolated_network" hcl:"isolated_network"`
+	IsolatedNetworkAllowedEndpoints []string                   `mapstructure:"isolated_network_allowed_endpoints" required:"false" cty:"isolated_network_allowed_endpoints" hcl:"isolated_network_allowed_endpoints"`
+	NetworkCaptureFile              *string                    `mapstructure:"network_capture_file" required:"false" cty:"network_capture_file" hcl:"network_capture_file"`
+	NetworkCaptureInArtifact        *bool                      `mapstructure:"network_capture_in_artifact" required:"false" cty:"network_capture_in_artifact" hcl:"network_capture_in_artifact"`
+	OutputDir                       *string                    `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	QemuArgs                        [][]string                 `mapstructure:"qemuargs" required:"false" cty:"qemuargs" hcl:"qemuargs"`
+	QemuImgArgs                     *FlatQemuImgArgs           `mapstructure:"qemu_img_args" required:"false" cty:"qemu_img_args" hcl:"qemu_img_args"`
+	QemuBinary                      *string                    `mapstructure:"qemu_binary" required:"false" cty:"qemu_binary" hcl:"qemu_binary"`
+	QMPEnable                       *bool                      `mapstructure:"qmp_enable" required:"false" cty:"qmp_enable" hcl:"qmp_enable"`
+	QMPSocketPath                   *string                    `mapstructure:"qmp_socket_path" required:"false" cty:"qmp_socket_path" hcl:"qmp_socket_path"`
+	CheckpointAfter                 []string                   `mapstructure:"checkpoint_after" required:"false" cty:"checkpoint_after" hcl:"checkpoint_after"`
+	ResumeFrom                      *string                    `mapstructure:"resume_from" required:"false" cty:"resume_from" hcl:"resume_from"`
+	GuestAgentEnable                *bool                      `mapstructure:"guest_agent_enable" required:"false" cty:"guest_agent_enable" hcl:"guest_agent_enable"`
+	GuestAgentSocketPath            *string                    `mapstructure:"guest_agent_socket_path" required:"false" cty:"guest_agent_socket_path" hcl:"guest_agent_socket_path"`
+	GuestAddressSource              *string                    `mapstructure:"guest_address_source" required:"false" cty:"guest_address_source" hcl:"guest_address_source"`
+	DHCPLeasesFile                  *string                    `mapstructure:"dhcp_leases_file" required:"false" cty:"dhcp_leases_file" hcl:"dhcp_leases_file"`
+	UseDefaultDisplay               *bool                      `mapstructure:"use_default_display" required:"false" cty:"use_default_display" hcl:"use_default_display"`
+	VGA                             *string                    `mapstructure:"vga" required:"false" cty:"vga" hcl:"vga"`
+	Display                         *string                    `mapstructure:"display" required:"false" cty:"display" hcl:"display"`
+	VNCBindAddress                  *string                    `mapstructure:"vnc_bind_address" required:"false" cty:"vnc_bind_address" hcl:"vnc_bind_address"`
+	VNCUsePassword                  *bool                      `mapstructure:"vnc_use_password" required:"false" cty:"vnc_use_password" hcl:"vnc_use_password"`
+	VNCPassword                     *string                    `mapstructure:"vnc_password" required:"false" cty:"vnc_password" hcl:"vnc_password"`
+	VNCPortMin                      *int                       `mapstructure:"vnc_port_min" required:"false" cty:"vnc_port_min" hcl:"vnc_port_min"`
+	VNCPortMax                      *int                       `mapstructure:"vnc_port_max" cty:"vnc_port_max" hcl:"vnc_port_max"`
+	VMName                          *string                    `mapstructure:"vm_name" required:"false" cty:"vm_name" hcl:"vm_name"`
+	CDROMInterface                  *string                    `mapstructure:"cdrom_interface" required:"false" cty:"cdrom_interface" hcl:"cdrom_interface"`
+	VTPM                            *bool                      `mapstructure:"vtpm" required:"false" cty:"vtpm" hcl:"vtpm"`
+	VTPMUseTPM1                     *bool                      `mapstructure:"use_tpm1" required:"false" cty:"use_tpm1" hcl:"use_tpm1"`
+	VTPMPersistState                *bool                      `mapstructure:"vtpm_persist_state" required:"false" cty:"vtpm_persist_state" hcl:"vtpm_persist_state"`
+	VTPMStateSource                 *string                    `mapstructure:"vtpm_state_source" required:"false" cty:"vtpm_state_source" hcl:"vtpm_state_source"`
+	VTPMManufacture                 *bool                      `mapstructure:"vtpm_manufacture" required:"false" cty:"vtpm_manufacture" hcl:"vtpm_manufacture"`
+	VTPMPCRBanks                    []string                   `mapstructure:"vtpm_pcr_banks" required:"false" cty:"vtpm_pcr_banks" hcl:"vtpm_pcr_banks"`
+	VTPMLogLevel                    *int                       `mapstructure:"vtpm_log_level" required:"false" cty:"vtpm_log_level" hcl:"vtpm_log_level"`
+	TPMType                         *string                    `mapstructure:"tpm_device_type" required:"false" cty:"tpm_device_type" hcl:"tpm_device_type"`
+	SharedFolders                   []FlatSharedFolder         `mapstructure:"shared_folders" required:"false" cty:"shared_folders" hcl:"shared_folders"`
+	SharedFoldersBackend            *string                    `mapstructure:"shared_folders_backend" required:"false" cty:"shared_folders_backend" hcl:"shared_folders_backend"`
+	BootSteps                       [][]string                 `mapstructure:"boot_steps" required:"false" cty:"boot_steps" hcl:"boot_steps"`
+	HTTPWaitForPath                 *string                    `mapstructure:"http_wait_for_path" required:"false" cty:"http_wait_for_path" hcl:"http_wait_for_path"`
+	HTTPWaitForPathAfterStep        *int                       `mapstructure:"http_wait_for_path_after_step" required:"false" cty:"http_wait_for_path_after_step" hcl:"http_wait_for_path_after_step"`
+	HTTPWaitForPathTimeout          *string                    `mapstructure:"http_wait_for_path_timeout" required:"false" cty:"http_wait_for_path_timeout" hcl:"http_wait_for_path_timeout"`
+	CPUModel                        *string                    `mapstructure:"cpu_model" required:"false" cty:"cpu_model" hcl:"cpu_model"`
+	RunOnce                         *bool                      `mapstructure:"run_once" cty:"run_once" hcl:"run_once"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -163,145 +223,205 @@ func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec }
 // The decoded values from this spec will then be applied to a FlatConfig.
 func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"packer_build_name":            &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
-		"packer_builder_type":          &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
-		"packer_core_version":          &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
-		"packer_debug":                 &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
-		"packer_force":                 &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
-		"packer_on_error":              &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
-		"packer_user_variables":        &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
-		"packer_sensitive_variables":   &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
-		"http_directory":               &hcldec.AttrSpec{Name: "http_directory", Type: cty.String, Required: false},
-		"http_content":                 &hcldec.AttrSpec{Name: "http_content", Type: cty.Map(cty.String), Required: false},
-		"http_port_min":                &hcldec.AttrSpec{Name: "http_port_min", Type: cty.Number, Required: false},
-		"http_port_max":                &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
-		"http_bind_address":            &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
-		"http_interface":               &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
-		"http_network_protocol":        &hcldec.AttrSpec{Name: "http_network_protocol", Type: cty.String, Required: false},
-		"iso_checksum":                 &hcldec.AttrSpec{Name: "iso_checksum", Type: cty.String, Required: false},
-		"iso_url":                      &hcldec.AttrSpec{Name: "iso_url", Type: cty.String, Required: false},
-		"iso_urls":                     &hcldec.AttrSpec{Name: "iso_urls", Type: cty.List(cty.String), Required: false},
-		"iso_target_path":              &hcldec.AttrSpec{Name: "iso_target_path", Type: cty.String, Required: false},
-		"iso_target_extension":         &hcldec.AttrSpec{Name: "iso_target_extension", Type: cty.String, Required: false},
-		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
-		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
-		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
-		"disable_vnc":                  &hcldec.AttrSpec{Name: "disable_vnc", Type: cty.Bool, Required: false},
-		"boot_key_interval":            &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
-		"shutdown_command":             &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
-		"shutdown_timeout":             &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
-		"communicator":                 &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
-		"pause_before_connecting":      &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
-		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
-		"ssh_port":                     &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
-		"ssh_username":                 &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
-		"ssh_password":                 &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
-		"ssh_keypair_name":             &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_name":      &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_type":      &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
-		"temporary_key_pair_bits":      &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
-		"ssh_ciphers":                  &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
-		"ssh_clear_authorized_keys":    &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
-		"ssh_key_exchange_algorithms":  &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
-		"ssh_private_key_file":         &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
-		"ssh_certificate_file":         &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
-		"ssh_pty":                      &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
-		"ssh_timeout":                  &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
-		"ssh_wait_timeout":             &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
-		"ssh_agent_auth":               &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_disable_agent_forwarding": &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
-		"ssh_handshake_attempts":       &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
-		"ssh_bastion_host":             &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
-		"ssh_bastion_port":             &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
-		"ssh_bastion_agent_auth":       &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_bastion_username":         &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
-		"ssh_bastion_password":         &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
-		"ssh_bastion_interactive":      &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
-		"ssh_bastion_private_key_file": &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
-		"ssh_bastion_certificate_file": &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
-		"ssh_file_transfer_method":     &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
-		"ssh_proxy_host":               &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
-		"ssh_proxy_port":               &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
-		"ssh_proxy_username":           &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
-		"ssh_proxy_password":           &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
-		"ssh_keep_alive_interval":      &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
-		"ssh_read_write_timeout":       &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
-		"ssh_remote_tunnels":           &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_local_tunnels":            &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_public_key":               &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
-		"ssh_private_key":              &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
-		"winrm_username":               &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
-		"winrm_password":               &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
-		"winrm_host":                   &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
-		"winrm_no_proxy":               &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
-		"winrm_port":                   &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
-		"winrm_timeout":                &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
-		"winrm_use_ssl":                &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
-		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
-		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
-		"host_port_min":                &hcldec.AttrSpec{Name: "host_port_min", Type: cty.Number, Required: false},
-		"host_port_max":                &hcldec.AttrSpec{Name: "host_port_max", Type: cty.Number, Required: false},
-		"skip_nat_mapping":             &hcldec.AttrSpec{Name: "skip_nat_mapping", Type: cty.Bool, Required: false},
-		"ssh_host_port_min":            &hcldec.AttrSpec{Name: "ssh_host_port_min", Type: cty.Number, Required: false},
-		"ssh_host_port_max":            &hcldec.AttrSpec{Name: "ssh_host_port_max", Type: cty.Number, Required: false},
-		"floppy_files":                 &hcldec.AttrSpec{Name: "floppy_files", Type: cty.List(cty.String), Required: false},
-		"floppy_dirs":                  &hcldec.AttrSpec{Name: "floppy_dirs", Type: cty.List(cty.String), Required: false},
-		"floppy_content":               &hcldec.AttrSpec{Name: "floppy_content", Type: cty.Map(cty.String), Required: false},
-		"floppy_label":                 &hcldec.AttrSpec{Name: "floppy_label", Type: cty.String, Required: false},
-		"cd_files":                     &hcldec.AttrSpec{Name: "cd_files", Type: cty.List(cty.String), Required: false},
-		"cd_content":                   &hcldec.AttrSpec{Name: "cd_content", Type: cty.Map(cty.String), Required: false},
-		"cd_label":                     &hcldec.AttrSpec{Name: "cd_label", Type: cty.String, Required: false},
-		"cpus":                         &hcldec.AttrSpec{Name: "cpus", Type: cty.Number, Required: false},
-		"sockets":                      &hcldec.AttrSpec{Name: "sockets", Type: cty.Number, Required: false},
-		"cores":                        &hcldec.AttrSpec{Name: "cores", Type: cty.Number, Required: false},
-		"threads":                      &hcldec.AttrSpec{Name: "threads", Type: cty.Number, Required: false},
-		"efi_boot":                     &hcldec.AttrSpec{Name: "efi_boot", Type: cty.Bool, Required: false},
-		"efi_firmware_code":            &hcldec.AttrSpec{Name: "efi_firmware_code", Type: cty.String, Required: false},
-		"efi_firmware_vars":            &hcldec.AttrSpec{Name: "efi_firmware_vars", Type: cty.String, Required: false},
-		"efi_drop_efivars":             &hcldec.AttrSpec{Name: "efi_drop_efivars", Type: cty.Bool, Required: false},
-		"iso_skip_cache":               &hcldec.AttrSpec{Name: "iso_skip_cache", Type: cty.Bool, Required: false},
-		"accelerator":                  &hcldec.AttrSpec{Name: "accelerator", Type: cty.String, Required: false},
-		"disk_additional_size":         &hcldec.AttrSpec{Name: "disk_additional_size", Type: cty.List(cty.String), Required: false},
-		"firmware":                     &hcldec.AttrSpec{Name: "firmware", Type: cty.String, Required: false},
-		"use_pflash":                   &hcldec.AttrSpec{Name: "use_pflash", Type: cty.Bool, Required: false},
-		"disk_interface":               &hcldec.AttrSpec{Name: "disk_interface", Type: cty.String, Required: false},
-		"disk_size":                    &hcldec.AttrSpec{Name: "disk_size", Type: cty.String, Required: false},
-		"skip_resize_disk":             &hcldec.AttrSpec{Name: "skip_resize_disk", Type: cty.Bool, Required: false},
-		"disk_cache":                   &hcldec.AttrSpec{Name: "disk_cache", Type: cty.String, Required: false},
-		"disk_discard":                 &hcldec.AttrSpec{Name: "disk_discard", Type: cty.String, Required: false},
-		"disk_detect_zeroes":           &hcldec.AttrSpec{Name: "disk_detect_zeroes", Type: cty.String, Required: false},
-		"skip_compaction":              &hcldec.AttrSpec{Name: "skip_compaction", Type: cty.Bool, Required: false},
-		"disk_compression":             &hcldec.AttrSpec{Name: "disk_compression", Type: cty.Bool, Required: false},
-		"format":                       &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
-		"headless":                     &hcldec.AttrSpec{Name: "headless", Type: cty.Bool, Required: false},
-		"disk_image":                   &hcldec.AttrSpec{Name: "disk_image", Type: cty.Bool, Required: false},
-		"use_backing_file":             &hcldec.AttrSpec{Name: "use_backing_file", Type: cty.Bool, Required: false},
-		"machine_type":                 &hcldec.AttrSpec{Name: "machine_type", Type: cty.String, Required: false},
-		"memory":                       &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
-		"net_device":                   &hcldec.AttrSpec{Name: "net_device", Type: cty.String, Required: false},
-		"net_bridge":                   &hcldec.AttrSpec{Name: "net_bridge", Type: cty.String, Required: false},
-		"output_directory":             &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
-		"qemuargs":                     &hcldec.AttrSpec{Name: "qemuargs", Type: cty.List(cty.List(cty.String)), Required: false},
-		"qemu_img_args":                &hcldec.BlockSpec{TypeName: "qemu_img_args", Nested: hcldec.ObjectSpec((*FlatQemuImgArgs)(nil).HCL2Spec())},
-		"qemu_binary":                  &hcldec.AttrSpec{Name: "qemu_binary", Type: cty.String, Required: false},
-		"qmp_enable":                   &hcldec.AttrSpec{Name: "qmp_enable", Type: cty.Bool, Required: false},
-		"qmp_socket_path":              &hcldec.AttrSpec{Name: "qmp_socket_path", Type: cty.String, Required: false},
-		"use_default_display":          &hcldec.AttrSpec{Name: "use_default_display", Type: cty.Bool, Required: false},
-		"vga":                          &hcldec.AttrSpec{Name: "vga", Type: cty.String, Required: false},
-		"display":                      &hcldec.AttrSpec{Name: "display", Type: cty.String, Required: false},
-		"vnc_bind_address":             &hcldec.AttrSpec{Name: "vnc_bind_address", Type: cty.String, Required: false},
-		"vnc_use_password":             &hcldec.AttrSpec{Name: "vnc_use_password", Type: cty.Bool, Required: false},
-		"vnc_password":                 &hcldec.AttrSpec{Name: "vnc_password", Type: cty.String, Required: false},
-		"vnc_port_min":                 &hcldec.AttrSpec{Name: "vnc_port_min", Type: cty.Number, Required: false},
-		"vnc_port_max":                 &hcldec.AttrSpec{Name: "vnc_port_max", Type: cty.Number, Required: false},
-		"vm_name":                      &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
-		"cdrom_interface":              &hcldec.AttrSpec{Name: "cdrom_interface", Type: cty.String, Required: false},
-		"vtpm":                         &hcldec.AttrSpec{Name: "vtpm", Type: cty.Bool, Required: false},
-		"use_tpm1":                     &hcldec.AttrSpec{Name: "use_tpm1", Type: cty.Bool, Required: false},
-		"tpm_device_type":              &hcldec.AttrSpec{Name: "tpm_device_type", Type: cty.String, Required: false},
-		"boot_steps":                   &hcldec.AttrSpec{Name: "boot_steps", Type: cty.List(cty.List(cty.String)), Required: false},
-		"cpu_model":                    &hcldec.AttrSpec{Name: "cpu_model", Type: cty.String, Required: false},
-		"run_once":                     &hcldec.AttrSpec{Name: "run_once", Type: cty.Bool, Required: false},
+		"packer_build_name":                  &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":                &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":                &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":                       &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":                       &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":                    &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":              &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables":         &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"http_directory":                     &hcldec.AttrSpec{Name: "http_directory", Type: cty.String, Required: false},
+		"http_content":                       &hcldec.AttrSpec{Name: "http_content", Type: cty.Map(cty.String), Required: false},
+		"http_port_min":                      &hcldec.AttrSpec{Name: "http_port_min", Type: cty.Number, Required: false},
+		"http_port_max":                      &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
+		"http_bind_address":                  &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
+		"http_interface":                     &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
+		"http_network_protocol":              &hcldec.AttrSpec{Name: "http_network_protocol", Type: cty.String, Required: false},
+		"iso_checksum":                       &hcldec.AttrSpec{Name: "iso_checksum", Type: cty.String, Required: false},
+		"iso_url":                            &hcldec.AttrSpec{Name: "iso_url", Type: cty.String, Required: false},
+		"iso_urls":                           &hcldec.AttrSpec{Name: "iso_urls", Type: cty.List(cty.String), Required: false},
+		"iso_target_path":                    &hcldec.AttrSpec{Name: "iso_target_path", Type: cty.String, Required: false},
+		"iso_target_extension":               &hcldec.AttrSpec{Name: "iso_target_extension", Type: cty.String, Required: false},
+		"boot_keygroup_interval":             &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
+		"boot_wait":                          &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
+		"boot_command":                       &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"disable_vnc":                        &hcldec.AttrSpec{Name: "disable_vnc", Type: cty.Bool, Required: false},
+		"boot_key_interval":                  &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"shutdown_command":                   &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
+		"shutdown_timeout":                   &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
+		"communicator":                       &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
+		"pause_before_connecting":            &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
+		"ssh_host":                           &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                           &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                       &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                       &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keypair_name":                   &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":            &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":            &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":            &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                        &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":          &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":        &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_private_key_file":               &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":               &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_pty":                            &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_timeout":                        &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":                   &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":                     &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding":       &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":             &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":                   &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":                   &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":             &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":               &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":               &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":            &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file":       &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file":       &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_file_transfer_method":           &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_proxy_host":                     &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_port":                     &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":                 &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":                 &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":            &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_read_write_timeout":             &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":                 &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":                  &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_public_key":                     &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":                    &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"winrm_username":                     &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":                     &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                         &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":                     &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_port":                         &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":                      &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":                      &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":                     &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":                     &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"host_port_min":                      &hcldec.AttrSpec{Name: "host_port_min", Type: cty.Number, Required: false},
+		"host_port_max":                      &hcldec.AttrSpec{Name: "host_port_max", Type: cty.Number, Required: false},
+		"skip_nat_mapping":                   &hcldec.AttrSpec{Name: "skip_nat_mapping", Type: cty.Bool, Required: false},
+		"ssh_host_port_min":                  &hcldec.AttrSpec{Name: "ssh_host_port_min", Type: cty.Number, Required: false},
+		"ssh_host_port_max":                  &hcldec.AttrSpec{Name: "ssh_host_port_max", Type: cty.Number, Required: false},
+		"qga_timeout":                        &hcldec.AttrSpec{Name: "qga_timeout", Type: cty.String, Required: false},
+		"serial_socket_path":                 &hcldec.AttrSpec{Name: "serial_socket_path", Type: cty.String, Required: false},
+		"serial_username":                    &hcldec.AttrSpec{Name: "serial_username", Type: cty.String, Required: false},
+		"serial_password":                    &hcldec.AttrSpec{Name: "serial_password", Type: cty.String, Required: false},
+		"serial_login_prompt":                &hcldec.AttrSpec{Name: "serial_login_prompt", Type: cty.String, Required: false},
+		"serial_password_prompt":             &hcldec.AttrSpec{Name: "serial_password_prompt", Type: cty.String, Required: false},
+		"serial_shell_prompt":                &hcldec.AttrSpec{Name: "serial_shell_prompt", Type: cty.String, Required: false},
+		"serial_timeout":                     &hcldec.AttrSpec{Name: "serial_timeout", Type: cty.String, Required: false},
+		"communicator_transport":             &hcldec.AttrSpec{Name: "communicator_transport", Type: cty.String, Required: false},
+		"vsock_cid_min":                      &hcldec.AttrSpec{Name: "vsock_cid_min", Type: cty.Number, Required: false},
+		"vsock_cid_max":                      &hcldec.AttrSpec{Name: "vsock_cid_max", Type: cty.Number, Required: false},
+		"floppy_files":                       &hcldec.AttrSpec{Name: "floppy_files", Type: cty.List(cty.String), Required: false},
+		"floppy_dirs":                        &hcldec.AttrSpec{Name: "floppy_dirs", Type: cty.List(cty.String), Required: false},
+		"floppy_content":                     &hcldec.AttrSpec{Name: "floppy_content", Type: cty.Map(cty.String), Required: false},
+		"floppy_label":                       &hcldec.AttrSpec{Name: "floppy_label", Type: cty.String, Required: false},
+		"cd_files":                           &hcldec.AttrSpec{Name: "cd_files", Type: cty.List(cty.String), Required: false},
+		"cd_content":                         &hcldec.AttrSpec{Name: "cd_content", Type: cty.Map(cty.String), Required: false},
+		"cd_label":                           &hcldec.AttrSpec{Name: "cd_label", Type: cty.String, Required: false},
+		"cpus":                               &hcldec.AttrSpec{Name: "cpus", Type: cty.Number, Required: false},
+		"sockets":                            &hcldec.AttrSpec{Name: "sockets", Type: cty.Number, Required: false},
+		"cores":                              &hcldec.AttrSpec{Name: "cores", Type: cty.Number, Required: false},
+		"threads":                            &hcldec.AttrSpec{Name: "threads", Type: cty.Number, Required: false},
+		"memory_hugepages":                   &hcldec.AttrSpec{Name: "memory_hugepages", Type: cty.Bool, Required: false},
+		"memory_hugepages_path":              &hcldec.AttrSpec{Name: "memory_hugepages_path", Type: cty.String, Required: false},
+		"memory_prealloc":                    &hcldec.AttrSpec{Name: "memory_prealloc", Type: cty.Bool, Required: false},
+		"numa":                               &hcldec.AttrSpec{Name: "numa", Type: cty.Bool, Required: false},
+		"memory_balloon":                     &hcldec.AttrSpec{Name: "memory_balloon", Type: cty.Bool, Required: false},
+		"memory_max":                         &hcldec.AttrSpec{Name: "memory_max", Type: cty.Number, Required: false},
+		"memory_slots":                       &hcldec.AttrSpec{Name: "memory_slots", Type: cty.Number, Required: false},
+		"vcpu_pinning":                       &hcldec.AttrSpec{Name: "vcpu_pinning", Type: cty.String, Required: false},
+		"iothreads":                          &hcldec.AttrSpec{Name: "iothreads", Type: cty.Number, Required: false},
+		"efi_boot":                           &hcldec.AttrSpec{Name: "efi_boot", Type: cty.Bool, Required: false},
+		"efi_firmware_code":                  &hcldec.AttrSpec{Name: "efi_firmware_code", Type: cty.String, Required: false},
+		"efi_firmware_vars":                  &hcldec.AttrSpec{Name: "efi_firmware_vars", Type: cty.String, Required: false},
+		"efi_firmware_features":              &hcldec.AttrSpec{Name: "efi_firmware_features", Type: cty.List(cty.String), Required: false},
+		"efi_secure_boot":                    &hcldec.BlockSpec{TypeName: "efi_secure_boot", Nested: hcldec.ObjectSpec((*FlatEFISecureBootConfig)(nil).HCL2Spec())},
+		"efi_drop_efivars":                   &hcldec.AttrSpec{Name: "efi_drop_efivars", Type: cty.Bool, Required: false},
+		"iso_skip_cache":                     &hcldec.AttrSpec{Name: "iso_skip_cache", Type: cty.Bool, Required: false},
+		"architecture":                       &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+		"accelerator":                        &hcldec.AttrSpec{Name: "accelerator", Type: cty.String, Required: false},
+		"disk_additional_size":               &hcldec.AttrSpec{Name: "disk_additional_size", Type: cty.List(cty.String), Required: false},
+		"firmware":                           &hcldec.AttrSpec{Name: "firmware", Type: cty.String, Required: false},
+		"use_pflash":                         &hcldec.AttrSpec{Name: "use_pflash", Type: cty.Bool, Required: false},
+		"disk_interface":                     &hcldec.AttrSpec{Name: "disk_interface", Type: cty.String, Required: false},
+		"disk_size":                          &hcldec.AttrSpec{Name: "disk_size", Type: cty.String, Required: false},
+		"skip_resize_disk":                   &hcldec.AttrSpec{Name: "skip_resize_disk", Type: cty.Bool, Required: false},
+		"disk_cache":                         &hcldec.AttrSpec{Name: "disk_cache", Type: cty.String, Required: false},
+		"disk_discard":                       &hcldec.AttrSpec{Name: "disk_discard", Type: cty.String, Required: false},
+		"disk_detect_zeroes":                 &hcldec.AttrSpec{Name: "disk_detect_zeroes", Type: cty.String, Required: false},
+		"skip_compaction":                    &hcldec.AttrSpec{Name: "skip_compaction", Type: cty.Bool, Required: false},
+		"disk_compression":                   &hcldec.AttrSpec{Name: "disk_compression", Type: cty.Bool, Required: false},
+		"trim_before_shutdown":               &hcldec.AttrSpec{Name: "trim_before_shutdown", Type: cty.Bool, Required: false},
+		"trim_command":                       &hcldec.AttrSpec{Name: "trim_command", Type: cty.String, Required: false},
+		"format":                             &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
+		"headless":                           &hcldec.AttrSpec{Name: "headless", Type: cty.Bool, Required: false},
+		"disk_image":                         &hcldec.AttrSpec{Name: "disk_image", Type: cty.Bool, Required: false},
+		"use_backing_file":                   &hcldec.AttrSpec{Name: "use_backing_file", Type: cty.Bool, Required: false},
+		"base_image_cache":                   &hcldec.AttrSpec{Name: "base_image_cache", Type: cty.Bool, Required: false},
+		"base_image_cache_dir":               &hcldec.AttrSpec{Name: "base_image_cache_dir", Type: cty.String, Required: false},
+		"layered_output":                     &hcldec.AttrSpec{Name: "layered_output", Type: cty.Bool, Required: false},
+		"layer_parent":                       &hcldec.AttrSpec{Name: "layer_parent", Type: cty.String, Required: false},
+		"layer_verify_parent":                &hcldec.AttrSpec{Name: "layer_verify_parent", Type: cty.Bool, Required: false},
+		"reproducible":                       &hcldec.AttrSpec{Name: "reproducible", Type: cty.Bool, Required: false},
+		"source_date_epoch":                  &hcldec.AttrSpec{Name: "source_date_epoch", Type: cty.Number, Required: false},
+		"machine_type":                       &hcldec.AttrSpec{Name: "machine_type", Type: cty.String, Required: false},
+		"memory":                             &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
+		"net_device":                         &hcldec.AttrSpec{Name: "net_device", Type: cty.String, Required: false},
+		"net_bridge":                         &hcldec.AttrSpec{Name: "net_bridge", Type: cty.String, Required: false},
+		"net_backend":                        &hcldec.AttrSpec{Name: "net_backend", Type: cty.String, Required: false},
+		"net_tap_device":                     &hcldec.AttrSpec{Name: "net_tap_device", Type: cty.String, Required: false},
+		"net_socket_mode":                    &hcldec.AttrSpec{Name: "net_socket_mode", Type: cty.String, Required: false},
+		"net_socket_address":                 &hcldec.AttrSpec{Name: "net_socket_address", Type: cty.String, Required: false},
+		"net_socket_local_address":           &hcldec.AttrSpec{Name: "net_socket_local_address", Type: cty.String, Required: false},
+		"network_interface":                  &hcldec.BlockListSpec{TypeName: "network_interface", Nested: hcldec.ObjectSpec((*FlatQemuNetworkInterface)(nil).HCL2Spec())},
+		"http_ip_address_type":               &hcldec.AttrSpec{Name: "http_ip_address_type", Type: cty.String, Required: false},
+		"port_forwards":                      &hcldec.BlockListSpec{TypeName: "port_forwards", Nested: hcldec.ObjectSpec((*FlatQemuPortForward)(nil).HCL2Spec())},
+		"isolated_network":                   &hcldec.AttrSpec{Name: "isolated_network", Type: cty.Bool, Required: false},
+		"isolated_network_allowed_endpoints": &hcldec.AttrSpec{Name: "isolated_network_allowed_endpoints", Type: cty.List(cty.String), Required: false},
+		"network_capture_file":               &hcldec.AttrSpec{Name: "network_capture_file", Type: cty.String, Required: false},
+		"network_capture_in_artifact":        &hcldec.AttrSpec{Name: "network_capture_in_artifact", Type: cty.Bool, Required: false},
+		"output_directory":                   &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
+		"qemuargs":                           &hcldec.AttrSpec{Name: "qemuargs", Type: cty.List(cty.List(cty.String)), Required: false},
+		"qemu_img_args":                      &hcldec.BlockSpec{TypeName: "qemu_img_args", Nested: hcldec.ObjectSpec((*FlatQemuImgArgs)(nil).HCL2Spec())},
+		"qemu_binary":                        &hcldec.AttrSpec{Name: "qemu_binary", Type: cty.String, Required: false},
+		"qmp_enable":                         &hcldec.AttrSpec{Name: "qmp_enable", Type: cty.Bool, Required: false},
+		"qmp_socket_path":                    &hcldec.AttrSpec{Name: "qmp_socket_path", Type: cty.String, Required: false},
+		"checkpoint_after":                   &hcldec.AttrSpec{Name: "checkpoint_after", Type: cty.List(cty.String), Required: false},
+		"resume_from":                        &hcldec.AttrSpec{Name: "resume_from", Type: cty.String, Required: false},
+		"guest_agent_enable":                 &hcldec.AttrSpec{Name: "guest_agent_enable", Type: cty.Bool, Required: false},
+		"guest_agent_socket_path":            &hcldec.AttrSpec{Name: "guest_agent_socket_path", Type: cty.String, Required: false},
+		"guest_address_source":               &hcldec.AttrSpec{Name: "guest_address_source", Type: cty.String, Required: false},
+		"dhcp_leases_file":                   &hcldec.AttrSpec{Name: "dhcp_leases_file", Type: cty.String, Required: false},
+		"use_default_display":                &hcldec.AttrSpec{Name: "use_default_display", Type: cty.Bool, Required: false},
+		"vga":                                &hcldec.AttrSpec{Name: "vga", Type: cty.String, Required: false},
+		"display":                            &hcldec.AttrSpec{Name: "display", Type: cty.String, Required: false},
+		"vnc_bind_address":                   &hcldec.AttrSpec{Name: "vnc_bind_address", Type: cty.String, Required: false},
+		"vnc_use_password":                   &hcldec.AttrSpec{Name: "vnc_use_password", Type: cty.Bool, Required: false},
+		"vnc_password":                       &hcldec.AttrSpec{Name: "vnc_password", Type: cty.String, Required: false},
+		"vnc_port_min":                       &hcldec.AttrSpec{Name: "vnc_port_min", Type: cty.Number, Required: false},
+		"vnc_port_max":                       &hcldec.AttrSpec{Name: "vnc_port_max", Type: cty.Number, Required: false},
+		"vm_name":                            &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
+		"cdrom_interface":                    &hcldec.AttrSpec{Name: "cdrom_interface", Type: cty.String, Required: false},
+		"vtpm":                               &hcldec.AttrSpec{Name: "vtpm", Type: cty.Bool, Required: false},
+		"use_tpm1":                           &hcldec.AttrSpec{Name: "use_tpm1", Type: cty.Bool, Required: false},
+		"vtpm_persist_state":                 &hcldec.AttrSpec{Name: "vtpm_persist_state", Type: cty.Bool, Required: false},
+		"vtpm_state_source":                  &hcldec.AttrSpec{Name: "vtpm_state_source", Type: cty.String, Required: false},
+		"vtpm_manufacture":                   &hcldec.AttrSpec{Name: "vtpm_manufacture", Type: cty.Bool, Required: false},
+		"vtpm_pcr_banks":                     &hcldec.AttrSpec{Name: "vtpm_pcr_banks", Type: cty.List(cty.String), Required: false},
+		"vtpm_log_level":                     &hcldec.AttrSpec{Name: "vtpm_log_level", Type: cty.Number, Required: false},
+		"tpm_device_type":                    &hcldec.AttrSpec{Name: "tpm_device_type", Type: cty.String, Required: false},
+		"shared_folders":                     &hcldec.BlockListSpec{TypeName: "shared_folders", Nested: hcldec.ObjectSpec((*FlatSharedFolder)(nil).HCL2Spec())},
+		"shared_folders_backend":             &hcldec.AttrSpec{Name: "shared_folders_backend", Type: cty.String, Required: false},
+		"boot_steps":                         &hcldec.AttrSpec{Name: "boot_steps", Type: cty.List(cty.List(cty.String)), Required: false},
+		"http_wait_for_path":                 &hcldec.AttrSpec{Name: "http_wait_for_path", Type: cty.String, Required: false},
+		"http_wait_for_path_after_step":      &hcldec.AttrSpec{Name: "http_wait_for_path_after_step", Type: cty.Number, Required: false},
+		"http_wait_for_path_timeout":         &hcldec.AttrSpec{Name: "http_wait_for_path_timeout", Type: cty.String, Required: false},
+		"cpu_model":                          &hcldec.AttrSpec{Name: "cpu_model", Type: cty.String, Required: false},
+		"run_once":                           &hcldec.AttrSpec{Name: "run_once", Type: cty.Bool, Required: false},
 	}
 	return s
 }
@@ -9,6 +9,8 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -623,6 +625,350 @@ func TestBuilderPrepare_QemuArgs(t *testing.T) {
 	}
 }
 
+// skipHostNetworkChecks disables the host interface checks for the duration
+// of the test, the tests use bridges and tap devices that don't exist.
+func skipHostNetworkChecks(t *testing.T) {
+	bridgeCheck, tapDeviceCheck := checkHostBridge, checkHostTapDevice
+	checkHostBridge = func(string) error { return nil }
+	checkHostTapDevice = func(string) error { return nil }
+	t.Cleanup(func() {
+		checkHostBridge, checkHostTapDevice = bridgeCheck, tapDeviceCheck
+	})
+}
+
+func TestBuilderPrepare_NetBackend(t *testing.T) {
+	skipHostNetworkChecks(t)
+
+	// Good: tap backend for the default interface
+	var c Config
+	config := testConfig()
+	config["net_backend"] = "tap"
+	config["net_tap_device"] = "tap0"
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if nic := c.communicatorInterface(); nic.Backend != "tap" || nic.TapDevice != "tap0" || nic.netdevID != "user.0" {
+		t.Fatalf("bad network interface: %#v", nic)
+	}
+	if !c.QMPEnable {
+		t.Fatal("QMP should be enabled when not using user-mode networking")
+	}
+
+	// Good: socket backend defaults to mcast
+	config = testConfig()
+	config["net_backend"] = "socket"
+	config["net_socket_address"] = "230.0.0.1:1234"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.NetSocketMode != "mcast" {
+		t.Fatalf("bad socket mode: %s", c.NetSocketMode)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"unknown backend":        {"net_backend": "vde"},
+		"tap without device":     {"net_backend": "tap"},
+		"bridge without name":    {"net_backend": "bridge"},
+		"net_bridge on tap":      {"net_backend": "tap", "net_tap_device": "tap0", "net_bridge": "br0"},
+		"socket without address": {"net_backend": "socket"},
+		"udp without localaddr":  {"net_backend": "socket", "net_socket_mode": "udp", "net_socket_address": "127.0.0.1:1234"},
+		"port_forwards on tap": {
+			"net_backend":    "tap",
+			"net_tap_device": "tap0",
+			"port_forwards":  []map[string]interface{}{{"guest_port": 80}},
+		},
+		"with network_interface": {
+			"net_backend":       "tap",
+			"net_tap_device":    "tap0",
+			"network_interface": []map[string]interface{}{{}},
+		},
+	}
+	for name, extra := range badConfigs {
+		config := testConfig()
+		for k, v := range extra {
+			config[k] = v
+		}
+		c = Config{}
+		_, err := c.Prepare(config)
+		if err == nil {
+			t.Errorf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_NetworkInterfaces(t *testing.T) {
+	skipHostNetworkChecks(t)
+
+	var c Config
+	config := testConfig()
+
+	// Good: interfaces inherit net_device and default to user networking
+	config["net_device"] = "e1000"
+	config["network_interface"] = []map[string]interface{}{
+		{},
+		{"model": "virtio-net", "backend": "tap", "tap_device": "tap0", "communicator": true},
+	}
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.NetworkInterfaces[0].Model != "e1000" || c.NetworkInterfaces[0].Backend != "user" {
+		t.Fatalf("bad network interface defaults: %#v", c.NetworkInterfaces[0])
+	}
+	if commNIC := c.communicatorInterface(); commNIC.netdevID != "net1" {
+		t.Fatalf("bad communicator interface: %#v", commNIC)
+	}
+	if !c.QMPEnable {
+		t.Fatal("QMP should be enabled when the communicator interface isn't user-mode")
+	}
+
+	badInterfaces := map[string][]map[string]interface{}{
+		"unknown backend":       {{"backend": "slirp"}},
+		"bad mac address":       {{"mac_address": "not-a-mac"}},
+		"tap without device":    {{"backend": "tap"}},
+		"socket without addr":   {{"backend": "socket"}},
+		"hostfwd on tap":        {{"backend": "tap", "tap_device": "tap0", "hostfwd": []string{"tcp::80-:80"}}},
+		"two communicators":     {{"communicator": true}, {"communicator": true}},
+		"communicator on none":  {{"backend": "none"}},
+		"udp without localaddr": {{"backend": "socket", "socket_mode": "udp", "socket_address": "127.0.0.1:1234"}},
+	}
+	for name, nics := range badInterfaces {
+		config := testConfig()
+		config["network_interface"] = nics
+		c = Config{}
+		_, err := c.Prepare(config)
+		if err == nil {
+			t.Errorf("%s: should have error", name)
+		}
+	}
+
+	// Bad: net_bridge and network_interface are mutually exclusive
+	config = testConfig()
+	config["net_bridge"] = "br0"
+	config["network_interface"] = []map[string]interface{}{{}}
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_GuestAddressSource(t *testing.T) {
+	skipHostNetworkChecks(t)
+
+	tapInterface := []map[string]interface{}{
+		{"backend": "tap", "tap_device": "tap0"},
+	}
+
+	// Good: guest_agent enables the guest agent
+	var c Config
+	config := testConfig()
+	config["network_interface"] = tapInterface
+	config["guest_address_source"] = "guest_agent"
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !c.GuestAgentEnable {
+		t.Fatal("guest agent should be enabled")
+	}
+
+	// Good: dhcp_leases with an explicit lease file
+	config = testConfig()
+	config["network_interface"] = tapInterface
+	config["guest_address_source"] = "dhcp_leases"
+	config["dhcp_leases_file"] = "/var/lib/misc/dnsmasq.leases"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"unknown source":           {"network_interface": tapInterface, "guest_address_source": "mdns"},
+		"user-mode networking":     {"guest_address_source": "guest_agent"},
+		"dhcp_leases without file": {"network_interface": tapInterface, "guest_address_source": "dhcp_leases"},
+		"static without host":      {"network_interface": tapInterface, "guest_address_source": "static"},
+		"arp on socket": {
+			"network_interface":    []map[string]interface{}{{"backend": "socket", "socket_address": "230.0.0.1:1234"}},
+			"guest_address_source": "arp",
+		},
+	}
+	for name, extra := range badConfigs {
+		config := testConfig()
+		for k, v := range extra {
+			config[k] = v
+		}
+		c = Config{}
+		_, err := c.Prepare(config)
+		if err == nil {
+			t.Errorf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_PortForwards(t *testing.T) {
+	var c Config
+	config := testConfig()
+
+	config["port_forwards"] = []map[string]interface{}{
+		{"guest_port": 80},
+		{"guest_port": 53, "protocol": "udp", "host_port_min": 5353},
+		{"guest_port": 53, "protocol": "tcp", "host_port_min": 5353},
+	}
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	expected := []QemuPortForward{
+		{GuestPort: 80, Protocol: "tcp", HostPortMin: 2222, HostPortMax: 4444},
+		{GuestPort: 53, Protocol: "udp", HostPortMin: 5353, HostPortMax: 5353},
+		{GuestPort: 53, Protocol: "tcp", HostPortMin: 5353, HostPortMax: 5353},
+	}
+	if !reflect.DeepEqual(c.PortForwards, expected) {
+		t.Fatalf("bad: %#v", c.PortForwards)
+	}
+
+	badForwards := map[string][]map[string]interface{}{
+		"no guest port":    {{"protocol": "tcp"}},
+		"unknown protocol": {{"guest_port": 80, "protocol": "sctp"}},
+		"inverted range":   {{"guest_port": 80, "host_port_min": 9000, "host_port_max": 8000}},
+		"duplicate port": {
+			{"guest_port": 80},
+			{"guest_port": 80, "protocol": "tcp", "host_port_min": 9000},
+		},
+	}
+	for name, forwards := range badForwards {
+		config := testConfig()
+		config["port_forwards"] = forwards
+		c = Config{}
+		_, err := c.Prepare(config)
+		if err == nil {
+			t.Errorf("%s: should have error", name)
+		}
+	}
+
+	// Bad: no user-mode interface to forward ports on
+	config = testConfig()
+	config["port_forwards"] = []map[string]interface{}{{"guest_port": 80}}
+	config["network_interface"] = []map[string]interface{}{
+		{"backend": "tap", "tap_device": "tap0"},
+	}
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_IsolatedNetwork(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["isolated_network"] = true
+	config["isolated_network_allowed_endpoints"] = []string{"mirror.example.com:80", "[2001:db8::1]:3128"}
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"endpoints without isolation": {"isolated_network_allowed_endpoints": []string{"mirror.example.com:80"}},
+		"endpoint without port":       {"isolated_network": true, "isolated_network_allowed_endpoints": []string{"mirror.example.com"}},
+		"endpoint with bad port":      {"isolated_network": true, "isolated_network_allowed_endpoints": []string{"mirror.example.com:http"}},
+		"no user-mode networking": {
+			"isolated_network":   true,
+			"net_backend":        "socket",
+			"net_socket_address": "230.0.0.1:1234",
+			"communicator":       "none",
+		},
+	}
+	for name, extra := range badConfigs {
+		config := testConfig()
+		for k, v := range extra {
+			config[k] = v
+		}
+		c = Config{}
+		_, err := c.Prepare(config)
+		if err == nil {
+			t.Errorf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_NetworkCaptureFile(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["network_capture_file"] = "capture.pcap"
+	config["output_directory"] = "not-a-real-directory"
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	expected := filepath.Join("not-a-real-directory", "capture.pcap")
+	if c.NetworkCaptureFile != expected {
+		t.Fatalf("Bad network capture file: %s", c.NetworkCaptureFile)
+	}
+
+	// Bad: the communicator interface has no netdev to capture
+	config = testConfig()
+	config["network_capture_file"] = "capture.pcap"
+	config["communicator"] = "none"
+	config["network_interface"] = []map[string]interface{}{{"backend": "none"}}
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_HTTPWaitForPath(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["http_content"] = map[string]string{"/ks.cfg": "kickstart"}
+	config["http_wait_for_path"] = "/ks.cfg"
+	config["boot_command"] = []string{"linux inst.ks=http://{{ .HTTPIP }}:{{ .HTTPPort }}/ks.cfg<enter>"}
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.HTTPWaitForPathAfterStep != 1 || c.HTTPWaitForPathTimeout != 10*time.Minute {
+		t.Fatalf("bad defaults: %d, %s", c.HTTPWaitForPathAfterStep, c.HTTPWaitForPathTimeout)
+	}
+
+	// Bad: no HTTP server
+	config = testConfig()
+	config["http_wait_for_path"] = "/ks.cfg"
+	config["boot_command"] = []string{"<enter>"}
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad: there's no such boot step
+	config = testConfig()
+	config["http_content"] = map[string]string{"/ks.cfg": "kickstart"}
+	config["http_wait_for_path"] = "/ks.cfg"
+	config["http_wait_for_path_after_step"] = 3
+	config["boot_steps"] = [][]string{{"<enter>"}, {"<enter>"}}
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBuilderPrepare_VNCPassword(t *testing.T) {
 	var c Config
 	config := testConfig()
@@ -643,6 +989,989 @@ func TestBuilderPrepare_VNCPassword(t *testing.T) {
 	}
 }
 
+// useFirmwareDescriptorDirs replaces the firmware descriptor directories of
+// the host.
+func useFirmwareDescriptorDirs(t *testing.T, dirs ...string) {
+	descriptorDirs := firmwareDescriptorDirs
+	firmwareDescriptorDirs = func(string) []string { return dirs }
+	t.Cleanup(func() {
+		firmwareDescriptorDirs = descriptorDirs
+	})
+}
+
+func TestBuilderPrepare_Architecture(t *testing.T) {
+	useFirmwareDescriptorDirs(t)
+
+	// Good: x86_64 by default, with the historical defaults
+	var c Config
+	config := testConfig()
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.Architecture != "x86_64" || c.QemuBinary != "qemu-system-x86_64" || c.MachineType != "pc" {
+		t.Fatalf("bad x86_64 defaults: %s %s %s", c.Architecture, c.QemuBinary, c.MachineType)
+	}
+	if c.CPUModel != "" || c.CDROMInterface != "" || c.QemuEFIBootConfig.EnableEFI {
+		t.Fatalf("bad x86_64 defaults: %q %q %t", c.CPUModel, c.CDROMInterface, c.QemuEFIBootConfig.EnableEFI)
+	}
+
+	// Good: aarch64 with TCG
+	config = testConfig()
+	config["architecture"] = "aarch64"
+	config["accelerator"] = "tcg"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.QemuBinary != "qemu-system-aarch64" || c.MachineType != "virt" || c.CPUModel != "max" {
+		t.Fatalf("bad aarch64 defaults: %s %s %s", c.QemuBinary, c.MachineType, c.CPUModel)
+	}
+	if c.CDROMInterface != "virtio-scsi" {
+		t.Fatalf("bad aarch64 cdrom interface: %s", c.CDROMInterface)
+	}
+	if !c.QemuEFIBootConfig.EnableEFI || c.QemuEFIBootConfig.OVMFCode != "/usr/share/AAVMF/AAVMF_CODE.fd" {
+		t.Fatalf("aarch64 should boot with AAVMF: %#v", c.QemuEFIBootConfig)
+	}
+
+	// Good: explicit options are kept
+	config = testConfig()
+	config["architecture"] = "s390x"
+	config["accelerator"] = "kvm"
+	config["qemu_binary"] = "/opt/qemu/bin/qemu-system-s390x"
+	config["machine_type"] = "s390-ccw-virtio-9.0"
+	config["cdrom_interface"] = "scsi"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.QemuBinary != "/opt/qemu/bin/qemu-system-s390x" || c.MachineType != "s390-ccw-virtio-9.0" || c.CDROMInterface != "scsi" {
+		t.Fatalf("explicit options were overridden: %s %s %s", c.QemuBinary, c.MachineType, c.CDROMInterface)
+	}
+	if c.CPUModel != "host" {
+		t.Fatalf("bad s390x KVM cpu model: %s", c.CPUModel)
+	}
+
+	// Good: foreign architectures default to TCG
+	foreign := "riscv64"
+	if runtime.GOARCH == "riscv64" {
+		foreign = "ppc64le"
+	}
+	config = testConfig()
+	config["architecture"] = foreign
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.Accelerator != "tcg" {
+		t.Fatalf("bad accelerator for %s: %s", foreign, c.Accelerator)
+	}
+
+	// Bad: unknown architecture
+	config = testConfig()
+	config["architecture"] = "mips"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_EFIFirmwareDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	writeFirmwareDescriptors(t, dir, map[string]string{
+		"30-edk2-ovmf-x64-sb.json": testFirmwareOVMF,
+		"50-edk2-ovmf-x64.json":    strings.ReplaceAll(testFirmwareOVMFNoSB, `"amd-sev", `, ""),
+	})
+	useFirmwareDescriptorDirs(t, dir)
+
+	// Good: the descriptor is used
+	var c Config
+	config := testConfig()
+	config["efi_boot"] = true
+	config["machine_type"] = "q35"
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.OVMFCode != "/usr/share/edk2/ovmf/OVMF_CODE.fd" || c.OVMFVars != "/usr/share/edk2/ovmf/OVMF_VARS.fd" {
+		t.Fatalf("bad firmware: %s %s", c.OVMFCode, c.OVMFVars)
+	}
+
+	// Good: explicit paths are kept
+	config = testConfig()
+	config["efi_firmware_code"] = "/opt/OVMF_CODE.fd"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.OVMFCode != "/opt/OVMF_CODE.fd" || c.OVMFVars != "/usr/share/OVMF/OVMF_VARS.fd" {
+		t.Fatalf("bad firmware: %s %s", c.OVMFCode, c.OVMFVars)
+	}
+
+	// Bad: no descriptor has the required features
+	config = testConfig()
+	config["machine_type"] = "q35"
+	config["efi_firmware_features"] = []string{"secure-boot", "enrolled-keys"}
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "30-edk2-ovmf-x64-sb.json") {
+		t.Fatalf("the error should list the descriptors considered: %s", err)
+	}
+	if !c.QemuEFIBootConfig.EnableEFI {
+		t.Fatal("efi_firmware_features should enable EFI")
+	}
+}
+
+func TestBuilderPrepare_EFISecureBoot(t *testing.T) {
+	dir := t.TempDir()
+	writeFirmwareDescriptors(t, dir, map[string]string{
+		"30-edk2-ovmf-x64-sb.json": testFirmwareOVMF,
+		"50-edk2-ovmf-x64.json":    strings.ReplaceAll(testFirmwareOVMFNoSB, `"amd-sev", `, ""),
+	})
+	useFirmwareDescriptorDirs(t, dir)
+	writeTestCertificate(t, filepath.Join(dir, "pk.pem"), "PK", true)
+	writeTestCertificate(t, filepath.Join(dir, "db.der"), "db", false)
+
+	// Good: the Secure Boot firmware is picked, and EFI is enabled
+	var c Config
+	config := testConfig()
+	config["machine_type"] = "q35"
+	config["efi_secure_boot"] = map[string]interface{}{
+		"pk": filepath.Join(dir, "pk.pem"),
+		"db": []string{filepath.Join(dir, "db.der")},
+	}
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !c.QemuEFIBootConfig.EnableEFI {
+		t.Fatal("efi_secure_boot should enable EFI")
+	}
+	if c.OVMFCode != "/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd" {
+		t.Fatalf("bad firmware: %s", c.OVMFCode)
+	}
+	if c.QemuEFIBootConfig.EFISecureBoot.SignatureOwner != defaultSignatureOwner {
+		t.Fatalf("bad signature owner: %s", c.QemuEFIBootConfig.EFISecureBoot.SignatureOwner)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"pc machine type": {
+			"efi_secure_boot": map[string]interface{}{"enabled": true},
+		},
+		"no firmware with enrolled keys": {
+			"machine_type":    "q35",
+			"efi_secure_boot": map[string]interface{}{"enabled": true},
+		},
+		"missing certificate": {
+			"machine_type":    "q35",
+			"efi_secure_boot": map[string]interface{}{"pk": filepath.Join(dir, "missing.pem")},
+		},
+		"not a certificate": {
+			"machine_type":    "q35",
+			"efi_secure_boot": map[string]interface{}{"pk": filepath.Join(dir, "30-edk2-ovmf-x64-sb.json")},
+		},
+		"bad signature owner": {
+			"machine_type": "q35",
+			"efi_secure_boot": map[string]interface{}{
+				"pk":              filepath.Join(dir, "pk.pem"),
+				"signature_owner": "packer",
+			},
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_VTPMPersistState(t *testing.T) {
+	stateSource := t.TempDir()
+
+	// Good
+	var c Config
+	config := testConfig()
+	config["vtpm"] = true
+	config["vtpm_persist_state"] = true
+	config["vtpm_state_source"] = stateSource
+	config["vtpm_manufacture"] = true
+	config["vtpm_pcr_banks"] = []string{"sha256", "sha384"}
+	config["vtpm_log_level"] = 20
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	stateFile := filepath.Join(stateSource, "tpm2-00.permall")
+	if err := os.WriteFile(stateFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"persist without vtpm": {
+			"vtpm_persist_state": true,
+		},
+		"source without vtpm": {
+			"vtpm_state_source": stateSource,
+		},
+		"missing source": {
+			"vtpm":              true,
+			"vtpm_state_source": filepath.Join(stateSource, "missing"),
+		},
+		"source is a file": {
+			"vtpm":              true,
+			"vtpm_state_source": stateFile,
+		},
+		"manufacture without vtpm": {
+			"vtpm_manufacture": true,
+		},
+		"pcr banks without manufacture": {
+			"vtpm":           true,
+			"vtpm_pcr_banks": []string{"sha256"},
+		},
+		"pcr banks with tpm 1.2": {
+			"vtpm":             true,
+			"use_tpm1":         true,
+			"vtpm_manufacture": true,
+			"vtpm_pcr_banks":   []string{"sha256"},
+		},
+		"unknown pcr bank": {
+			"vtpm":             true,
+			"vtpm_manufacture": true,
+			"vtpm_pcr_banks":   []string{"md5"},
+		},
+		"negative log level": {
+			"vtpm":           true,
+			"vtpm_log_level": -1,
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_Checkpoints(t *testing.T) {
+	// Good: checkpoints enable QMP
+	var c Config
+	config := testConfig()
+	config["checkpoint_after"] = []string{"boot_command", "provision"}
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !c.QMPEnable {
+		t.Fatal("checkpoint_after should enable QMP")
+	}
+	if !c.savesCheckpoint("boot_command") || c.resumes("boot_command") {
+		t.Fatal("the boot_command checkpoint should be saved")
+	}
+
+	// Good: resuming from the output directory of the failed build
+	outputDir := t.TempDir()
+	for _, name := range []string{"packer-foo", "packer-foo.checkpoint-key"} {
+		if err := os.WriteFile(filepath.Join(outputDir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config = testConfig()
+	config["output_directory"] = outputDir
+	config["checkpoint_after"] = []string{"boot_command", "provision"}
+	config["resume_from"] = "boot_command"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !c.resumes("boot_command") || c.resumes("provision") {
+		t.Fatal("only the steps up to boot_command should be skipped")
+	}
+	if c.savesCheckpoint("boot_command") || !c.savesCheckpoint("provision") {
+		t.Fatal("only the provision checkpoint should be saved")
+	}
+	if c.CommConfig.Comm.SSHPrivateKeyFile != filepath.Join(outputDir, "packer-foo.checkpoint-key") {
+		t.Fatalf("the SSH key of the checkpoint should be used, got %q", c.CommConfig.Comm.SSHPrivateKeyFile)
+	}
+
+	noKeyDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(noKeyDir, "packer-foo"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"unknown checkpoint": {
+			"checkpoint_after": []string{"shutdown"},
+		},
+		"provisioner checkpoint": {
+			"checkpoint_after": []string{"windows-update"},
+		},
+		"unknown resume_from": {
+			"output_directory": outputDir,
+			"resume_from":      "shutdown",
+		},
+		"raw format": {
+			"format":           "raw",
+			"checkpoint_after": []string{"provision"},
+		},
+		"efi": {
+			"efi_boot":         true,
+			"checkpoint_after": []string{"provision"},
+		},
+		"vtpm": {
+			"vtpm":             true,
+			"checkpoint_after": []string{"provision"},
+		},
+		"no disk to resume": {
+			"output_directory": t.TempDir(),
+			"resume_from":      "provision",
+		},
+		"no SSH key to resume": {
+			"output_directory": noKeyDir,
+			"resume_from":      "provision",
+		},
+		"resume with force": {
+			"output_directory": outputDir,
+			"resume_from":      "provision",
+			"packer_force":     true,
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_BaseImageCache(t *testing.T) {
+	// Good: the cache defaults to the Packer cache
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+	var c Config
+	config := testConfig()
+	config["disk_image"] = true
+	config["base_image_cache"] = true
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.BaseImageCacheDir != filepath.Join(os.Getenv("PACKER_CACHE_DIR"), "qemu-base-images") {
+		t.Fatalf("bad cache directory: %s", c.BaseImageCacheDir)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"not a disk image": {
+			"base_image_cache": true,
+		},
+		"raw format": {
+			"disk_image":       true,
+			"format":           "raw",
+			"base_image_cache": true,
+		},
+		"backing file": {
+			"disk_image":       true,
+			"use_backing_file": true,
+			"base_image_cache": true,
+		},
+		"directory without cache": {
+			"disk_image":           true,
+			"base_image_cache_dir": "/tmp",
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_LayeredOutput(t *testing.T) {
+	// Good: the parent defaults to the file name of the source image
+	var c Config
+	config := testConfig()
+	config["iso_url"] = "https://example.com/images/base.qcow2?version=2"
+	config["disk_image"] = true
+	config["layered_output"] = true
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !c.UseBackingFile {
+		t.Fatal("layered_output should use a backing file")
+	}
+	if c.LayerParent != "base.qcow2" {
+		t.Fatalf("bad parent: %s", c.LayerParent)
+	}
+
+	c = Config{}
+	config["layer_parent"] = "/var/lib/images/base.qcow2"
+	config["layer_verify_parent"] = true
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.LayerParent != "/var/lib/images/base.qcow2" {
+		t.Fatalf("bad parent: %s", c.LayerParent)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"not a disk image": {
+			"layered_output": true,
+		},
+		"raw format": {
+			"disk_image":     true,
+			"format":         "raw",
+			"layered_output": true,
+		},
+		"parent without layered output": {
+			"disk_image":   true,
+			"layer_parent": "base.qcow2",
+		},
+		"verify without layered output": {
+			"disk_image":          true,
+			"layer_verify_parent": true,
+		},
+		"verify relative parent": {
+			"disk_image":          true,
+			"layered_output":      true,
+			"layer_parent":        "base.qcow2",
+			"layer_verify_parent": true,
+		},
+		"verify default parent": {
+			"disk_image":          true,
+			"layered_output":      true,
+			"layer_verify_parent": true,
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_Reproducible(t *testing.T) {
+	// Good: the source date defaults to SOURCE_DATE_EPOCH
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	var c Config
+	config := testConfig()
+	config["reproducible"] = true
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if *c.SourceDateEpoch != 1700000000 {
+		t.Fatalf("bad source date: %d", *c.SourceDateEpoch)
+	}
+	if mac := c.networkInterfaces()[0].MACAddress; mac != "52:54:00:12:34:56" {
+		t.Fatalf("bad MAC address: %s", mac)
+	}
+
+	c = Config{}
+	config["source_date_epoch"] = 1600000000
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if *c.SourceDateEpoch != 1600000000 {
+		t.Fatalf("bad source date: %d", *c.SourceDateEpoch)
+	}
+
+	// Good: the epoch itself is a valid source date
+	c = Config{}
+	config["source_date_epoch"] = 0
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if *c.SourceDateEpoch != 0 {
+		t.Fatalf("bad source date: %d", *c.SourceDateEpoch)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"source date without reproducible": {
+			"source_date_epoch": 1600000000,
+		},
+		"zero source date without reproducible": {
+			"source_date_epoch": 0,
+		},
+		"negative source date": {
+			"reproducible":      true,
+			"source_date_epoch": -1,
+		},
+		"disk interface without serial numbers": {
+			"reproducible":   true,
+			"disk_interface": "scsi",
+		},
+		"backing file": {
+			"reproducible":     true,
+			"disk_image":       true,
+			"use_backing_file": true,
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+
+	// Bad: no source date
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	config = testConfig()
+	config["reproducible"] = true
+	c = Config{}
+	if _, err := c.Prepare(config); err == nil {
+		t.Fatal("should have error without a source date")
+	}
+}
+
+func TestBuilderPrepare_TrimBeforeShutdown(t *testing.T) {
+	// Good: discarding is enabled on the disks
+	var c Config
+	config := testConfig()
+	config["trim_before_shutdown"] = true
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.DiskDiscard != "unmap" || c.DetectZeroes != "unmap" {
+		t.Fatalf("bad disk options: %s, %s", c.DiskDiscard, c.DetectZeroes)
+	}
+	if c.TrimCommand != linuxTrimCommand {
+		t.Fatalf("bad trim command: %s", c.TrimCommand)
+	}
+
+	// Good: the trim command depends on the communicator
+	c = Config{}
+	config = testConfig()
+	config["trim_before_shutdown"] = true
+	config["communicator"] = "winrm"
+	config["winrm_username"] = "packer"
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.TrimCommand != windowsTrimCommand {
+		t.Fatalf("bad trim command: %s", c.TrimCommand)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"no communicator": {
+			"trim_before_shutdown": true,
+			"communicator":         "none",
+		},
+		"discard ignored": {
+			"trim_before_shutdown": true,
+			"disk_discard":         "ignore",
+		},
+		"zeroes not detected": {
+			"trim_before_shutdown": true,
+			"disk_detect_zeroes":   "off",
+		},
+		"command without trim": {
+			"trim_command": "fstrim -v /",
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_SharedFolders(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("virtiofs is only supported on Linux")
+	}
+	hostPath := t.TempDir()
+	hostFile := filepath.Join(hostPath, "file")
+	if err := os.WriteFile(hostFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Good: virtiofs is used when virtiofsd is installed
+	useFakeVirtiofsd(t, true)
+	var c Config
+	config := testConfig()
+	config["shared_folders"] = []map[string]interface{}{
+		{"host_path": hostPath},
+	}
+	warns, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if c.SharedFoldersBackend != "virtiofs" {
+		t.Fatalf("bad backend: %s", c.SharedFoldersBackend)
+	}
+	if c.SharedFolders[0].Tag != filepath.Base(hostPath) {
+		t.Fatalf("bad tag: %s", c.SharedFolders[0].Tag)
+	}
+
+	// Good: 9p is used otherwise, with a warning
+	useFakeVirtiofsd(t, false)
+	c = Config{}
+	warns, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(warns) != 1 {
+		t.Fatalf("should have a warning: %#v", warns)
+	}
+	if c.SharedFoldersBackend != "9p" {
+		t.Fatalf("bad backend: %s", c.SharedFoldersBackend)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"missing host path": {
+			"shared_folders": []map[string]interface{}{{"tag": "tools"}},
+		},
+		"host path is a file": {
+			"shared_folders": []map[string]interface{}{{"host_path": hostFile}},
+		},
+		"duplicate tags": {
+			"shared_folders": []map[string]interface{}{
+				{"host_path": hostPath, "tag": "tools"},
+				{"host_path": hostPath, "tag": "tools"},
+			},
+		},
+		"long tag": {
+			"shared_folders": []map[string]interface{}{
+				{"host_path": hostPath, "tag": strings.Repeat("t", 37)},
+			},
+		},
+		"virtiofs without virtiofsd": {
+			"shared_folders":         []map[string]interface{}{{"host_path": hostPath}},
+			"shared_folders_backend": "virtiofs",
+		},
+		"unknown backend": {
+			"shared_folders":         []map[string]interface{}{{"host_path": hostPath}},
+			"shared_folders_backend": "nfs",
+		},
+		"backend without folders": {
+			"shared_folders_backend": "9p",
+		},
+		"checkpoints": {
+			"shared_folders":   []map[string]interface{}{{"host_path": hostPath}},
+			"checkpoint_after": []string{"provision"},
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_Memory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("hugepages are only supported on Linux")
+	}
+	hugepages := t.TempDir()
+
+	// Good
+	var c Config
+	config := testConfig()
+	config["memory"] = 4096
+	config["sockets"] = 2
+	config["numa"] = true
+	config["memory_hugepages"] = true
+	config["memory_hugepages_path"] = hugepages
+	config["memory_prealloc"] = true
+	config["memory_balloon"] = true
+	config["memory_max"] = 8192
+	config["memory_slots"] = 4
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"missing hugepages path": {
+			"memory_hugepages":      true,
+			"memory_hugepages_path": filepath.Join(hugepages, "missing"),
+		},
+		"hugepages path without hugepages": {
+			"memory_hugepages_path": hugepages,
+		},
+		"numa without sockets": {
+			"numa": true,
+		},
+		"numa with uneven memory": {
+			"numa":    true,
+			"memory":  1025,
+			"sockets": 2,
+		},
+		"max memory without slots": {
+			"memory_max": 8192,
+		},
+		"slots without max memory": {
+			"memory_slots": 4,
+		},
+		"max memory below memory": {
+			"memory":       4096,
+			"memory_max":   2048,
+			"memory_slots": 4,
+		},
+		"too many slots": {
+			"memory_max":   8192,
+			"memory_slots": 512,
+		},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_Tuning(t *testing.T) {
+	// Good
+	var c Config
+	config := testConfig()
+	config["iothreads"] = 2
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if runtime.GOOS == "linux" {
+		c = Config{}
+		config = testConfig()
+		config["vcpu_pinning"] = "0"
+		_, err = c.Prepare(config)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if !c.QMPEnable {
+			t.Fatal("vcpu_pinning should enable QMP")
+		}
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"invalid cpu":             {"vcpu_pinning": "a"},
+		"invalid range":           {"vcpu_pinning": "3-1"},
+		"unavailable cpu":         {"vcpu_pinning": "100000"},
+		"negative iothreads":      {"iothreads": -1},
+		"iothreads with ide":      {"iothreads": 1, "disk_interface": "ide"},
+		"iothreads with scsi > 1": {"iothreads": 2, "disk_interface": "virtio-scsi"},
+	}
+	for name, bad := range badConfigs {
+		config := testConfig()
+		for k, v := range bad {
+			config[k] = v
+		}
+		c = Config{}
+		if _, err := c.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("0-2, 5,7-8")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !reflect.DeepEqual(cpus, []int{0, 1, 2, 5, 7, 8}) {
+		t.Fatalf("bad cpus: %v", cpus)
+	}
+}
+
+func TestBuilderPrepare_GuestAgent(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["guest_agent_enable"] = true
+	config["output_directory"] = "not-a-real-directory"
+
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	expected := filepath.Join("not-a-real-directory", "packer-foo.qga")
+	if c.GuestAgentSocketPath != expected {
+		t.Fatalf("Bad guest agent socket path: %s", c.GuestAgentSocketPath)
+	}
+}
+
+func TestBuilderPrepare_QGACommunicator(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["communicator"] = "qga"
+
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.CommConfig.Comm.Type != "qga" {
+		t.Fatalf("bad communicator type: %s", c.CommConfig.Comm.Type)
+	}
+	if !c.GuestAgentEnable {
+		t.Fatal("the guest agent should be enabled")
+	}
+	if c.CommConfig.QGATimeout != 5*time.Minute {
+		t.Fatalf("bad qga timeout: %s", c.CommConfig.QGATimeout)
+	}
+}
+
+func TestBuilderPrepare_SerialCommunicator(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["communicator"] = "serial"
+	config["serial_username"] = "root"
+
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.CommConfig.Comm.Type != "serial" {
+		t.Fatalf("bad communicator type: %s", c.CommConfig.Comm.Type)
+	}
+	expected := filepath.Join(c.OutputDir, c.VMName+".console")
+	if c.CommConfig.SerialSocketPath != expected {
+		t.Fatalf("bad serial socket path: %s", c.CommConfig.SerialSocketPath)
+	}
+	if c.CommConfig.SerialTimeout != 5*time.Minute {
+		t.Fatalf("bad serial timeout: %s", c.CommConfig.SerialTimeout)
+	}
+	if c.CommConfig.usesNetwork() {
+		t.Fatal("the serial communicator shouldn't use the guest network")
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"missing username": {"communicator": "serial"},
+		"bad shell prompt": {"communicator": "serial", "serial_username": "root", "serial_shell_prompt": "[#"},
+		"bad login prompt": {"communicator": "serial", "serial_username": "root", "serial_login_prompt": "(login"},
+	}
+	for name, extra := range badConfigs {
+		config := testConfig()
+		for k, v := range extra {
+			config[k] = v
+		}
+		c = Config{}
+		_, err := c.Prepare(config)
+		if err == nil {
+			t.Errorf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_CommunicatorTransport(t *testing.T) {
+	// Good: tcp by default
+	var c Config
+	config := testConfig()
+	_, err := c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.CommConfig.CommunicatorTransport != "tcp" {
+		t.Fatalf("bad communicator transport: %s", c.CommConfig.CommunicatorTransport)
+	}
+
+	// Good: vsock with the default CID range
+	config = testConfig()
+	config["communicator_transport"] = "vsock"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.CommConfig.VsockCIDMin != 3 || c.CommConfig.VsockCIDMax != 65535 {
+		t.Fatalf("bad vsock CID range: %d-%d", c.CommConfig.VsockCIDMin, c.CommConfig.VsockCIDMax)
+	}
+	if c.CommConfig.usesNetwork() {
+		t.Fatal("the communicator shouldn't use the guest network with vsock")
+	}
+
+	badConfigs := map[string]map[string]interface{}{
+		"unknown transport": {"communicator_transport": "udp"},
+		"winrm communicator": {
+			"communicator_transport": "vsock",
+			"communicator":           "winrm",
+			"winrm_username":         "packer",
+		},
+		"bastion host":       {"communicator_transport": "vsock", "ssh_bastion_host": "bastion.example.com"},
+		"skip_nat_mapping":   {"communicator_transport": "vsock", "skip_nat_mapping": true},
+		"reserved CID":       {"communicator_transport": "vsock", "vsock_cid_min": 2},
+		"inverted CID range": {"communicator_transport": "vsock", "vsock_cid_min": 100, "vsock_cid_max": 10},
+	}
+	for name, extra := range badConfigs {
+		config := testConfig()
+		for k, v := range extra {
+			config[k] = v
+		}
+		c = Config{}
+		_, err := c.Prepare(config)
+		if err == nil {
+			t.Errorf("%s: should have error", name)
+		}
+	}
+}
+
 func TestCommConfigPrepare_BackwardsCompatibility(t *testing.T) {
 	var c Config
 	config := testConfig()
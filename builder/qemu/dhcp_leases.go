@@ -0,0 +1,122 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dhcpLease is a lease read from a dnsmasq lease file or from a libvirt
+// network status file.
+type dhcpLease struct {
+	IPAddress  string `json:"ip-address"`
+	MACAddress string `json:"mac-address"`
+	Hostname   string `json:"hostname"`
+	ExpiryTime int64  `json:"expiry-time"`
+}
+
+// getDHCPLeaseIPAddress returns the IP address leased to the given MAC
+// address in a DHCP lease file.
+//
+// When several leases were handed to the same MAC address, e.g. because the
+// guest rebooted during the build, the one expiring last is returned.
+// Expired leases are ignored.
+func getDHCPLeaseIPAddress(path string, macAddress string) (string, error) {
+	leases, err := readDHCPLeases(path)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().Unix()
+	var found *dhcpLease
+	for i, lease := range leases {
+		if !strings.EqualFold(lease.MACAddress, macAddress) {
+			continue
+		}
+		// An expiry time of 0 is an infinite lease.
+		if lease.ExpiryTime != 0 && lease.ExpiryTime < now {
+			continue
+		}
+		if found == nil || found.ExpiryTime != 0 && (lease.ExpiryTime == 0 || lease.ExpiryTime > found.ExpiryTime) {
+			found = &leases[i]
+		}
+	}
+
+	if found == nil {
+		return "", fmt.Errorf("could not find a lease for %s in %s", macAddress, path)
+	}
+	return found.IPAddress, nil
+}
+
+// readDHCPLeases reads a lease file in either the libvirt JSON status file
+// format or the dnsmasq lease file format.
+func readDHCPLeases(path string) ([]dhcpLease, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DHCP lease file: %w", err)
+	}
+
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	if content[0] == '[' {
+		var leases []dhcpLease
+		if err := json.Unmarshal(content, &leases); err != nil {
+			return nil, fmt.Errorf("failed to parse DHCP status file %s: %w", path, err)
+		}
+		return leases, nil
+	}
+
+	return parseDnsmasqLeases(content)
+}
+
+func parseDnsmasqLeases(content []byte) ([]dhcpLease, error) {
+	// The dnsmasq lease file is normally something alike:
+	//
+	// 		1700000000 52:54:00:12:34:56 192.168.122.111 myhost 01:52:54:00:12:34:56
+	// 		duid 00:01:00:01:2c:5e:3a:1b:52:54:00:00:00:01
+	// 		1700000000 1234567 fd00::111 myhost 00:01:00:01:2c:5e:3a:1b:52:54:00:12:34:56
+	//
+	// DHCPv6 leases, after the duid line, are identified by an IAID rather
+	// than a MAC address and won't match any MAC address.
+
+	const (
+		ExpiryTimeIndex int = iota
+		MACAddressIndex
+		IPAddressIndex
+		HostnameIndex
+	)
+
+	var leases []dhcpLease
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) <= HostnameIndex || fields[0] == "duid" {
+			continue
+		}
+
+		expiryTime, err := strconv.ParseInt(fields[ExpiryTimeIndex], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dnsmasq lease expiry time %s: %w", fields[ExpiryTimeIndex], err)
+		}
+
+		leases = append(leases, dhcpLease{
+			IPAddress:  fields[IPAddressIndex],
+			MACAddress: fields[MACAddressIndex],
+			Hostname:   fields[HostnameIndex],
+			ExpiryTime: expiryTime,
+		})
+	}
+
+	return leases, s.Err()
+}
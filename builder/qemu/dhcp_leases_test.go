@@ -0,0 +1,65 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetDHCPLeaseIPAddress(t *testing.T) {
+	now := time.Now().Unix()
+
+	testcases := map[string]string{
+		"dnsmasq": fmt.Sprintf(`%d 52:54:00:12:34:56 192.168.122.10 old 01:52:54:00:12:34:56
+%d 52:54:00:12:34:56 192.168.122.11 guest 01:52:54:00:12:34:56
+%d 52:54:00:12:34:56 192.168.122.12 expired 01:52:54:00:12:34:56
+%d 52:54:00:ab:cd:ef 192.168.122.13 other *
+duid 00:01:00:01:2c:5e:3a:1b:52:54:00:00:00:01
+%d 1234567 fd00::11 guest 00:01:00:01:2c:5e:3a:1b:52:54:00:12:34:56
+`, now+60, now+3600, now-60, now+7200, now+3600),
+		"libvirt": fmt.Sprintf(`[
+  {
+    "ip-address": "192.168.122.10",
+    "mac-address": "52:54:00:12:34:56",
+    "hostname": "old",
+    "expiry-time": %d
+  },
+  {
+    "ip-address": "192.168.122.11",
+    "mac-address": "52:54:00:12:34:56",
+    "hostname": "guest",
+    "expiry-time": %d
+  },
+  {
+    "ip-address": "192.168.122.13",
+    "mac-address": "52:54:00:ab:cd:ef",
+    "expiry-time": %d
+  }
+]
+`, now+60, now+3600, now+7200),
+	}
+
+	for name, content := range testcases {
+		path := filepath.Join(t.TempDir(), "leases")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ipAddress, err := getDHCPLeaseIPAddress(path, "52:54:00:12:34:56")
+		if err != nil {
+			t.Fatalf("%s: should not have error: %s", name, err)
+		}
+		if ipAddress != "192.168.122.11" {
+			t.Errorf("%s: bad ip address: %s", name, ipAddress)
+		}
+
+		if _, err := getDHCPLeaseIPAddress(path, "52:54:00:00:00:00"); err == nil {
+			t.Errorf("%s: should have error for an unknown MAC address", name)
+		}
+	}
+}
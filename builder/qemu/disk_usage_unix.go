@@ -0,0 +1,22 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+
+package qemu
+
+import (
+	"os"
+	"syscall"
+)
+
+// allocatedSize returns the space the file takes on the host, which is less
+// than its size when it's sparse.
+func allocatedSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	// st_blocks is in 512 bytes units, whatever the block size
+	return info.Sys().(*syscall.Stat_t).Blocks * 512, nil
+}
@@ -0,0 +1,14 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package qemu
+
+import (
+	"errors"
+)
+
+func allocatedSize(path string) (int64, error) {
+	return 0, errors.New("the allocated size of files is not supported on Windows hosts")
+}
@@ -122,8 +122,15 @@ func (d *QemuDriver) Qemu(qemuArgs ...string) error {
 		return err
 	}
 
+	// Keep the end of stderr around to report why Qemu failed to start, if
+	// it did.
+	stderr := &tailBuffer{max: qemuStderrTailSize}
+	stderrDone := make(chan struct{})
 	go logReader("Qemu stdout", stdout_r)
-	go logReader("Qemu stderr", stderr_r)
+	go func() {
+		defer close(stderrDone)
+		logReader("Qemu stderr", io.TeeReader(stderr_r, stderr))
+	}()
 
 	log.Printf("Started Qemu. Pid: %d", cmd.Process.Pid)
 
@@ -158,6 +165,13 @@ func (d *QemuDriver) Qemu(qemuArgs ...string) error {
 	select {
 	case exit := <-endCh:
 		if exit != 0 {
+			select {
+			case <-stderrDone:
+				if output := strings.TrimSpace(stderr.String()); output != "" {
+					return fmt.Errorf("Qemu failed to start: %s", output)
+				}
+			case <-time.After(time.Second):
+			}
 			return fmt.Errorf("Qemu failed to start. Please run with PACKER_LOG=1 to get more info.")
 		}
 	case <-time.After(2 * time.Second):
@@ -170,6 +184,33 @@ func (d *QemuDriver) Qemu(qemuArgs ...string) error {
 	return nil
 }
 
+// qemuStderrTailSize is how much of the end of the Qemu stderr is kept to
+// report startup failures.
+const qemuStderrTailSize = 64 * 1024
+
+// tailBuffer is a writer keeping the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
 func (d *QemuDriver) WaitForShutdown(cancelCh <-chan struct{}) bool {
 	d.lock.Lock()
 	endCh := d.vmEndCh
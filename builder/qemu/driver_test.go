@@ -0,0 +1,26 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	for _, s := range []string{"qemu: ", "could not ", "open disk"} {
+		if n, err := b.Write([]byte(s)); err != nil || n != len(s) {
+			t.Fatalf("bad write: %d, %v", n, err)
+		}
+	}
+	if got := b.String(); got != "pen disk" {
+		t.Fatalf("should keep the last 8 bytes, got %q", got)
+	}
+
+	b.Write([]byte(strings.Repeat("x", 20)))
+	if got := b.String(); got != strings.Repeat("x", 8) {
+		t.Fatalf("should keep the last 8 bytes, got %q", got)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type EFISecureBootConfig
+
+package qemu
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultSignatureOwner is the owner GUID of the enrolled certificates when
+// `signature_owner` is not set, the all-zero GUID recording no owner.
+const defaultSignatureOwner = "00000000-0000-0000-0000-000000000000"
+
+// EFISecureBootConfig enables UEFI Secure Boot, and optionally enrolls
+// custom keys in the EFI variables of the VM.
+//
+// The machine is set up for the Secure Boot firmware: on `x86_64`, SMM is
+// enabled and the firmware flash is restricted to SMM, which requires a q35
+// `machine_type`. A `-machine` argument of `qemuargs` replaces the default
+// one, and must then include `smm=on`. Unless `efi_firmware_code` is set,
+// the firmware is picked from the firmware descriptors with the
+// `secure-boot` feature, and the `enrolled-keys` feature when no `pk` is
+// provided.
+//
+// The certificates are enrolled in a copy of the VARS template, so the
+// template can be a blank one. Each of `pk`, `kek`, `db` and `dbx` replaces
+// the variable of the template when set, and leaves it untouched otherwise.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	efi_secure_boot {
+//	  pk  = "keys/pk.pem"
+//	  kek = ["keys/kek.pem"]
+//	  db  = ["keys/db.pem", "keys/microsoft-uefi-ca.der"]
+//	}
+//
+// ```
+type EFISecureBootConfig struct {
+	// Enable Secure Boot. This is implied when any certificate is set.
+	Enabled bool `mapstructure:"enabled" required:"false"`
+	// The Platform Key certificate to enroll, a PEM or DER X.509
+	// certificate file.
+	PK string `mapstructure:"pk" required:"false"`
+	// The Key Exchange Key certificates to enroll.
+	KEK []string `mapstructure:"kek" required:"false"`
+	// The certificates to enroll in the allowed signatures database.
+	DB []string `mapstructure:"db" required:"false"`
+	// The certificates to enroll in the forbidden signatures database.
+	DBX []string `mapstructure:"dbx" required:"false"`
+	// The owner GUID recorded with the enrolled certificates, usually the
+	// GUID identifying the organization owning the keys. Defaults to the
+	// all-zero GUID, which records no owner.
+	SignatureOwner string `mapstructure:"signature_owner" required:"false"`
+}
+
+// enabled tells whether Secure Boot is enabled.
+func (sb *EFISecureBootConfig) enabled() bool {
+	return sb.Enabled || sb.enrollsKeys()
+}
+
+// enrollsKeys tells whether any certificate is enrolled.
+func (sb *EFISecureBootConfig) enrollsKeys() bool {
+	return sb.PK != "" || len(sb.KEK) > 0 || len(sb.DB) > 0 || len(sb.DBX) > 0
+}
+
+func (sb *EFISecureBootConfig) Prepare(arch string, machineType string) []error {
+	if !sb.enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	if sb.SignatureOwner == "" {
+		sb.SignatureOwner = defaultSignatureOwner
+	}
+	if _, err := parseEFIGUID(sb.SignatureOwner); err != nil {
+		errs = append(errs, fmt.Errorf("efi_secure_boot: bad signature_owner: %s", err))
+	}
+
+	certificates := append([]string{}, sb.KEK...)
+	certificates = append(certificates, sb.DB...)
+	certificates = append(certificates, sb.DBX...)
+	if sb.PK != "" {
+		certificates = append(certificates, sb.PK)
+	}
+	for _, path := range certificates {
+		if _, err := readEFICertificate(path); err != nil {
+			errs = append(errs, fmt.Errorf("efi_secure_boot: %s", err))
+		}
+	}
+
+	if arch == "x86_64" && !strings.Contains(machineType, "q35") {
+		errs = append(errs, fmt.Errorf("efi_secure_boot requires a q35 machine_type on x86_64, got %s", machineType))
+	}
+
+	return errs
+}
+
+// firmwareFeatures returns the features the firmware must have.
+func (sb *EFISecureBootConfig) firmwareFeatures() []string {
+	if !sb.enabled() {
+		return nil
+	}
+	if sb.PK == "" {
+		return []string{"secure-boot", "enrolled-keys"}
+	}
+	return []string{"secure-boot"}
+}
+
+// readEFICertificate reads a PEM or DER X.509 certificate, and returns it
+// in DER.
+func readEFICertificate(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %s", err)
+	}
+
+	der := content
+	if block, _ := pem.Decode(content); block != nil {
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("%s: expected a CERTIFICATE PEM block, got %s", path, block.Type)
+		}
+		der = block.Bytes
+	}
+
+	if _, err := x509.ParseCertificate(der); err != nil {
+		return nil, fmt.Errorf("%s is not a PEM or DER X.509 certificate: %s", path, err)
+	}
+	return der, nil
+}
+
+// secureBootSMM tells whether the VM runs with SMM, which the x86_64 Secure
+// Boot firmwares need to protect their variables from the guest.
+func (c *Config) secureBootSMM() bool {
+	return c.QemuEFIBootConfig.EFISecureBoot.enabled() && c.Architecture == "x86_64"
+}
@@ -0,0 +1,41 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package qemu
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatEFISecureBootConfig is an auto-generated flat version of EFISecureBootConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatEFISecureBootConfig struct {
+	Enabled        *bool    `mapstructure:"enabled" required:"false" cty:"enabled" hcl:"enabled"`
+	PK             *string  `mapstructure:"pk" required:"false" cty:"pk" hcl:"pk"`
+	KEK            []string `mapstructure:"kek" required:"false" cty:"kek" hcl:"kek"`
+	DB             []string `mapstructure:"db" required:"false" cty:"db" hcl:"db"`
+	DBX            []string `mapstructure:"dbx" required:"false" cty:"dbx" hcl:"dbx"`
+	SignatureOwner *string  `mapstructure:"signature_owner" required:"false" cty:"signature_owner" hcl:"signature_owner"`
+}
+
+// FlatMapstructure returns a new FlatEFISecureBootConfig.
+// FlatEFISecureBootConfig is an auto-generated flat version of EFISecureBootConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*EFISecureBootConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatEFISecureBootConfig)
+}
+
+// HCL2Spec returns the hcl spec of a EFISecureBootConfig.
+// This spec is used by HCL to read the fields of EFISecureBootConfig.
+// The decoded values from this spec will then be applied to a FlatEFISecureBootConfig.
+func (*FlatEFISecureBootConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"enabled":         &hcldec.AttrSpec{Name: "enabled", Type: cty.Bool, Required: false},
+		"pk":              &hcldec.AttrSpec{Name: "pk", Type: cty.String, Required: false},
+		"kek":             &hcldec.AttrSpec{Name: "kek", Type: cty.List(cty.String), Required: false},
+		"db":              &hcldec.AttrSpec{Name: "db", Type: cty.List(cty.String), Required: false},
+		"dbx":             &hcldec.AttrSpec{Name: "dbx", Type: cty.List(cty.String), Required: false},
+		"signature_owner": &hcldec.AttrSpec{Name: "signature_owner", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,351 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// This file implements enough of the EDK2 variable store format to add
+// authenticated variables to an OVMF VARS file, see
+// MdeModulePkg/Include/Guid/VariableFormat.h in the EDK2 sources.
+
+const (
+	efiVariableStartID      = 0x55aa
+	efiVariableStoreFormat  = 0x5a
+	efiVariableStoreHealthy = 0xfe
+
+	efiVariableAdded               = 0x3f
+	efiVariableInDeletedTransition = 0xfe
+	efiVariableDeleted             = 0xfd
+
+	// The headers are aligned on 4 bytes.
+	efiVariableAlignment = 4
+
+	efiFirmwareVolumeHeaderSize = 0x38
+	efiVariableStoreHeaderSize  = 28
+	efiAuthVariableHeaderSize   = 60
+
+	efiVariableNonVolatile                       = 0x01
+	efiVariableBootServiceAccess                 = 0x02
+	efiVariableRuntimeAccess                     = 0x04
+	efiVariableTimeBasedAuthenticatedWriteAccess = 0x20
+)
+
+// efiGUID is a GUID in its EFI binary form, with its first three fields in
+// little endian.
+type efiGUID [16]byte
+
+func parseEFIGUID(s string) (efiGUID, error) {
+	var guid efiGUID
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return guid, fmt.Errorf("%q is not a GUID", s)
+	}
+	b, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return guid, fmt.Errorf("%q is not a GUID: %s", s, err)
+	}
+	binary.LittleEndian.PutUint32(guid[0:4], binary.BigEndian.Uint32(b[0:4]))
+	binary.LittleEndian.PutUint16(guid[4:6], binary.BigEndian.Uint16(b[4:6]))
+	binary.LittleEndian.PutUint16(guid[6:8], binary.BigEndian.Uint16(b[6:8]))
+	copy(guid[8:], b[8:])
+	return guid, nil
+}
+
+func mustParseEFIGUID(s string) efiGUID {
+	guid, err := parseEFIGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return guid
+}
+
+var (
+	efiFirmwareVolumeSignature = []byte("_FVH")
+
+	errEFINotAuthenticated = errors.New("not an authenticated variable store, Secure Boot keys can't be enrolled")
+
+	efiAuthenticatedVariableGUID = mustParseEFIGUID("aaf32c78-947b-439a-a180-2e144ec37792")
+	efiGlobalVariableGUID        = mustParseEFIGUID("8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	efiImageSecurityDatabaseGUID = mustParseEFIGUID("d719b2cb-3d3a-4596-a3bc-dad00e67656f")
+	efiSecureBootEnableGUID      = mustParseEFIGUID("f0a30bc7-af08-4556-99c4-001009c93a44")
+	efiCustomModeGUID            = mustParseEFIGUID("c076ec0c-7028-4399-a072-71ee5c448b9f")
+	efiCertX509GUID              = mustParseEFIGUID("a5c059a1-94e4-4aa7-87b5-ab155c2bf072")
+)
+
+// efiVariable is a variable of the store.
+type efiVariable struct {
+	Name       string
+	Vendor     efiGUID
+	Attributes uint32
+	Data       []byte
+
+	offset int
+	state  byte
+}
+
+// efiVariableStore is the variable store of an OVMF VARS file.
+type efiVariableStore struct {
+	data []byte
+	// start and end are the offsets of the variables in data.
+	start int
+	end   int
+}
+
+func openEFIVariableStore(data []byte) (*efiVariableStore, error) {
+	if len(data) < efiFirmwareVolumeHeaderSize || !bytes.Equal(data[40:44], efiFirmwareVolumeSignature) {
+		return nil, errors.New("not an EFI firmware volume")
+	}
+	headerLength := int(binary.LittleEndian.Uint16(data[48:50]))
+	if headerLength+efiVariableStoreHeaderSize > len(data) {
+		return nil, errors.New("truncated variable store header")
+	}
+
+	header := data[headerLength:]
+	var signature efiGUID
+	copy(signature[:], header[0:16])
+	if signature != efiAuthenticatedVariableGUID {
+		return nil, errEFINotAuthenticated
+	}
+	if header[20] != efiVariableStoreFormat || header[21] != efiVariableStoreHealthy {
+		return nil, errors.New("the variable store is not formatted or not healthy")
+	}
+	size := int(binary.LittleEndian.Uint32(header[16:20]))
+	if headerLength+size > len(data) {
+		return nil, errors.New("the variable store is larger than the firmware volume")
+	}
+
+	return &efiVariableStore{
+		data:  data,
+		start: alignEFIVariable(headerLength + efiVariableStoreHeaderSize),
+		end:   headerLength + size,
+	}, nil
+}
+
+func alignEFIVariable(offset int) int {
+	return (offset + efiVariableAlignment - 1) &^ (efiVariableAlignment - 1)
+}
+
+// variables returns all the variables of the store, including the deleted
+// ones, and the offset of the free space.
+func (s *efiVariableStore) variables() ([]efiVariable, int, error) {
+	var variables []efiVariable
+	offset := s.start
+	for offset+efiAuthVariableHeaderSize <= s.end {
+		header := s.data[offset : offset+efiAuthVariableHeaderSize]
+		if binary.LittleEndian.Uint16(header[0:2]) != efiVariableStartID {
+			break
+		}
+		nameSize := int(binary.LittleEndian.Uint32(header[36:40]))
+		dataSize := int(binary.LittleEndian.Uint32(header[40:44]))
+		next := offset + efiAuthVariableHeaderSize + nameSize + dataSize
+		if next > s.end {
+			return nil, 0, fmt.Errorf("the variable at offset %#x overflows the store", offset)
+		}
+
+		name := s.data[offset+efiAuthVariableHeaderSize : offset+efiAuthVariableHeaderSize+nameSize]
+		v := efiVariable{
+			Name:       decodeEFIVariableName(name),
+			Attributes: binary.LittleEndian.Uint32(header[4:8]),
+			Data:       s.data[offset+efiAuthVariableHeaderSize+nameSize : next],
+			offset:     offset,
+			state:      header[2],
+		}
+		copy(v.Vendor[:], header[44:60])
+		variables = append(variables, v)
+
+		offset = alignEFIVariable(next)
+	}
+	return variables, offset, nil
+}
+
+// valid tells whether the variable is live, including when it is being
+// deleted after a new version was added.
+func (v efiVariable) valid() bool {
+	return v.state == efiVariableAdded || v.state == efiVariableAdded&efiVariableInDeletedTransition
+}
+
+// Get returns the live variable with the name and vendor.
+func (s *efiVariableStore) Get(name string, vendor efiGUID) (*efiVariable, error) {
+	variables, _, err := s.variables()
+	if err != nil {
+		return nil, err
+	}
+	for i := range variables {
+		if variables[i].valid() && variables[i].Name == name && variables[i].Vendor == vendor {
+			return &variables[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Set replaces the variable with the name and vendor, deleting its current
+// version, if any.
+func (s *efiVariableStore) Set(name string, vendor efiGUID, attributes uint32, timestamp time.Time, value []byte) error {
+	variables, free, err := s.variables()
+	if err != nil {
+		return err
+	}
+
+	encodedName := encodeEFIVariableName(name)
+	size := efiAuthVariableHeaderSize + len(encodedName) + len(value)
+	if free+size > s.end {
+		return fmt.Errorf("not enough space left in the variable store for %s", name)
+	}
+	for _, b := range s.data[free : free+size] {
+		if b != 0xff {
+			return fmt.Errorf("the free space of the variable store is not erased")
+		}
+	}
+
+	for _, v := range variables {
+		if v.valid() && v.Name == name && v.Vendor == vendor {
+			s.data[v.offset+2] &= efiVariableDeleted
+		}
+	}
+
+	header := s.data[free : free+efiAuthVariableHeaderSize]
+	binary.LittleEndian.PutUint16(header[0:2], efiVariableStartID)
+	header[2] = efiVariableAdded
+	header[3] = 0
+	binary.LittleEndian.PutUint32(header[4:8], attributes)
+	binary.LittleEndian.PutUint64(header[8:16], 0)
+	if attributes&efiVariableTimeBasedAuthenticatedWriteAccess != 0 {
+		putEFITime(header[16:32], timestamp)
+	} else {
+		copy(header[16:32], make([]byte, 16))
+	}
+	binary.LittleEndian.PutUint32(header[32:36], 0)
+	binary.LittleEndian.PutUint32(header[36:40], uint32(len(encodedName)))
+	binary.LittleEndian.PutUint32(header[40:44], uint32(len(value)))
+	copy(header[44:60], vendor[:])
+	copy(s.data[free+efiAuthVariableHeaderSize:], encodedName)
+	copy(s.data[free+efiAuthVariableHeaderSize+len(encodedName):], value)
+
+	return nil
+}
+
+// putEFITime writes an EFI_TIME structure.
+func putEFITime(b []byte, t time.Time) {
+	t = t.UTC()
+	binary.LittleEndian.PutUint16(b[0:2], uint16(t.Year()))
+	b[2] = byte(t.Month())
+	b[3] = byte(t.Day())
+	b[4] = byte(t.Hour())
+	b[5] = byte(t.Minute())
+	b[6] = byte(t.Second())
+	b[7] = 0
+	// Nanosecond, TimeZone, Daylight and padding.
+	copy(b[8:16], make([]byte, 8))
+}
+
+func encodeEFIVariableName(name string) []byte {
+	codes := utf16.Encode([]rune(name + "\x00"))
+	b := make([]byte, 2*len(codes))
+	for i, c := range codes {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+func decodeEFIVariableName(b []byte) string {
+	codes := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		codes = append(codes, c)
+	}
+	return string(utf16.Decode(codes))
+}
+
+// efiSignatureList returns an EFI_SIGNATURE_LIST per certificate, each
+// holding one EFI_SIGNATURE_DATA owned by owner.
+func efiSignatureList(owner efiGUID, certificates [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certificates {
+		signatureSize := 16 + len(cert)
+		buf.Write(efiCertX509GUID[:])
+		binary.Write(&buf, binary.LittleEndian, uint32(28+signatureSize))
+		binary.Write(&buf, binary.LittleEndian, uint32(0))
+		binary.Write(&buf, binary.LittleEndian, uint32(signatureSize))
+		buf.Write(owner[:])
+		buf.Write(cert)
+	}
+	return buf.Bytes()
+}
+
+// enrollSecureBootKeys enrolls the certificates of the configuration in the
+// VARS file at path, and enables Secure Boot in it.
+func enrollSecureBootKeys(path string, sb *EFISecureBootConfig, timestamp time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	store, err := openEFIVariableStore(data)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	owner, err := parseEFIGUID(sb.SignatureOwner)
+	if err != nil {
+		return err
+	}
+
+	const authenticatedAttributes = efiVariableNonVolatile | efiVariableBootServiceAccess |
+		efiVariableRuntimeAccess | efiVariableTimeBasedAuthenticatedWriteAccess
+
+	// PK is enrolled last, enrolling it ends the setup mode.
+	keys := []struct {
+		name   string
+		vendor efiGUID
+		paths  []string
+	}{
+		{"db", efiImageSecurityDatabaseGUID, sb.DB},
+		{"dbx", efiImageSecurityDatabaseGUID, sb.DBX},
+		{"KEK", efiGlobalVariableGUID, sb.KEK},
+		{"PK", efiGlobalVariableGUID, nil},
+	}
+	if sb.PK != "" {
+		keys[3].paths = []string{sb.PK}
+	}
+
+	for _, key := range keys {
+		if len(key.paths) == 0 {
+			continue
+		}
+		var certificates [][]byte
+		for _, certPath := range key.paths {
+			cert, err := readEFICertificate(certPath)
+			if err != nil {
+				return err
+			}
+			certificates = append(certificates, cert)
+		}
+		if err := store.Set(key.name, key.vendor, authenticatedAttributes, timestamp, efiSignatureList(owner, certificates)); err != nil {
+			return err
+		}
+	}
+
+	// These are the OVMF settings of the Secure Boot configuration menu.
+	const bootServiceAttributes = efiVariableNonVolatile | efiVariableBootServiceAccess
+	if err := store.Set("SecureBootEnable", efiSecureBootEnableGUID, bootServiceAttributes, timestamp, []byte{1}); err != nil {
+		return err
+	}
+	if err := store.Set("CustomMode", efiCustomModeGUID, bootServiceAttributes, timestamp, []byte{0}); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0660)
+}
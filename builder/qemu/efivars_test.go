@@ -0,0 +1,208 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestEFIVars writes a blank VARS file, with an authenticated variable
+// store like the OVMF ones.
+func writeTestEFIVars(t *testing.T, path string) {
+	const (
+		volumeSize  = 0x20000
+		headerSize  = 0x48
+		storeLength = 0xe000
+	)
+	data := bytes.Repeat([]byte{0xff}, volumeSize)
+
+	fv := data[:headerSize]
+	copy(fv[0:16], make([]byte, 16))
+	binary.LittleEndian.PutUint64(fv[32:40], volumeSize)
+	copy(fv[40:44], efiFirmwareVolumeSignature)
+	binary.LittleEndian.PutUint16(fv[48:50], headerSize)
+
+	store := data[headerSize : headerSize+efiVariableStoreHeaderSize]
+	copy(store[0:16], efiAuthenticatedVariableGUID[:])
+	binary.LittleEndian.PutUint32(store[16:20], storeLength)
+	store[20] = efiVariableStoreFormat
+	store[21] = efiVariableStoreHealthy
+	copy(store[22:28], make([]byte, 6))
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate, in PEM when pemEncoded
+// is set and in DER otherwise, and returns its DER.
+func writeTestCertificate(t *testing.T, path, commonName string, pemEncoded bool) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := der
+	if pemEncoded {
+		content = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestParseEFIGUID(t *testing.T) {
+	guid, err := parseEFIGUID("8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected := efiGUID{0x61, 0xdf, 0xe4, 0x8b, 0xca, 0x93, 0xd2, 0x11, 0xaa, 0x0d, 0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c}
+	if guid != expected {
+		t.Fatalf("bad GUID: %x", guid)
+	}
+
+	for _, bad := range []string{"", "8be4df61-93ca-11d2-aa0d", "8be4df61-93ca-11d2-aa0d-00e098032b8z", "8be4df6193ca11d2aa0d00e098032b8c"} {
+		if _, err := parseEFIGUID(bad); err == nil {
+			t.Fatalf("should have error parsing %q", bad)
+		}
+	}
+}
+
+func TestEnrollSecureBootKeys(t *testing.T) {
+	dir := t.TempDir()
+	vars := filepath.Join(dir, "efivars.fd")
+	writeTestEFIVars(t, vars)
+	pk := writeTestCertificate(t, filepath.Join(dir, "pk.pem"), "PK", true)
+	kek := writeTestCertificate(t, filepath.Join(dir, "kek.der"), "KEK", false)
+	db1 := writeTestCertificate(t, filepath.Join(dir, "db1.pem"), "db1", true)
+	db2 := writeTestCertificate(t, filepath.Join(dir, "db2.pem"), "db2", true)
+
+	sb := &EFISecureBootConfig{
+		PK:  filepath.Join(dir, "pk.pem"),
+		KEK: []string{filepath.Join(dir, "kek.der")},
+		DB:  []string{filepath.Join(dir, "db1.pem"), filepath.Join(dir, "db2.pem")},
+	}
+	if errs := sb.Prepare("aarch64", "virt"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	timestamp := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	if err := enrollSecureBootKeys(vars, sb, timestamp); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	// Enrolling again replaces the variables
+	if err := enrollSecureBootKeys(vars, sb, timestamp); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	data, err := os.ReadFile(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := openEFIVariableStore(data)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	owner, _ := parseEFIGUID(defaultSignatureOwner)
+	tests := []struct {
+		name     string
+		vendor   efiGUID
+		expected []byte
+	}{
+		{"PK", efiGlobalVariableGUID, efiSignatureList(owner, [][]byte{pk})},
+		{"KEK", efiGlobalVariableGUID, efiSignatureList(owner, [][]byte{kek})},
+		{"db", efiImageSecurityDatabaseGUID, efiSignatureList(owner, [][]byte{db1, db2})},
+		{"SecureBootEnable", efiSecureBootEnableGUID, []byte{1}},
+		{"CustomMode", efiCustomModeGUID, []byte{0}},
+	}
+	for _, tt := range tests {
+		v, err := store.Get(tt.name, tt.vendor)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if v == nil {
+			t.Fatalf("%s should be set", tt.name)
+		}
+		if !bytes.Equal(v.Data, tt.expected) {
+			t.Fatalf("bad %s data: %x", tt.name, v.Data)
+		}
+	}
+
+	if v, _ := store.Get("dbx", efiImageSecurityDatabaseGUID); v != nil {
+		t.Fatal("dbx should not be set")
+	}
+
+	variables, _, err := store.variables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := 0
+	for _, v := range variables {
+		if v.valid() {
+			valid++
+		}
+	}
+	if len(variables) != 10 || valid != 5 {
+		t.Fatalf("bad variables: %d, %d valid", len(variables), valid)
+	}
+
+	// The signature list of db holds one list per certificate
+	db, _ := store.Get("db", efiImageSecurityDatabaseGUID)
+	listSize := binary.LittleEndian.Uint32(db.Data[16:20])
+	if int(listSize) != 28+16+len(db1) {
+		t.Fatalf("bad signature list size: %d", listSize)
+	}
+	if !bytes.Equal(db.Data[28:44], owner[:]) || !bytes.Equal(db.Data[44:listSize], db1) {
+		t.Fatal("bad signature data")
+	}
+}
+
+func TestEnrollSecureBootKeys_NotAuthenticated(t *testing.T) {
+	dir := t.TempDir()
+	vars := filepath.Join(dir, "efivars.fd")
+	writeTestEFIVars(t, vars)
+	writeTestCertificate(t, filepath.Join(dir, "pk.pem"), "PK", true)
+
+	// Turn the store into a non authenticated one
+	data, err := os.ReadFile(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0x48] ^= 0xff
+	if err := os.WriteFile(vars, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sb := &EFISecureBootConfig{
+		PK:             filepath.Join(dir, "pk.pem"),
+		SignatureOwner: defaultSignatureOwner,
+	}
+	if err := enrollSecureBootKeys(vars, sb, time.Now()); err == nil {
+		t.Fatal("should have error")
+	}
+}
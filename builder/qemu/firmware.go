@@ -0,0 +1,234 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// firmwareDescriptorDirs returns the directories firmware descriptors are
+// looked up in, by decreasing priority, as described in the QEMU firmware
+// interoperability specification (docs/interop/firmware.json). The share
+// directory of the QEMU installation is looked up last, for installations
+// outside of /usr like on NixOS or with Homebrew.
+var firmwareDescriptorDirs = func(qemuBinary string) []string {
+	dirs := []string{"/etc/qemu/firmware"}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(configDir, "qemu", "firmware"))
+	}
+	dirs = append(dirs, "/usr/share/qemu/firmware")
+
+	if qemuPath, err := exec.LookPath(qemuBinary); err == nil {
+		if resolved, err := filepath.EvalSymlinks(qemuPath); err == nil {
+			qemuPath = resolved
+		}
+		shareDir := filepath.Join(filepath.Dir(filepath.Dir(qemuPath)), "share", "qemu", "firmware")
+		if shareDir != "/usr/share/qemu/firmware" {
+			dirs = append(dirs, shareDir)
+		}
+	}
+
+	return dirs
+}
+
+// confidentialFirmwareFeatures are only picked when required, these
+// firmwares can't boot a regular guest.
+var confidentialFirmwareFeatures = []string{"amd-sev", "amd-sev-es", "amd-sev-snp", "intel-tdx"}
+
+type firmwareFlashFile struct {
+	Filename string `json:"filename"`
+	Format   string `json:"format"`
+}
+
+type firmwareMapping struct {
+	Device        string            `json:"device"`
+	Mode          string            `json:"mode"`
+	Executable    firmwareFlashFile `json:"executable"`
+	NVRAMTemplate firmwareFlashFile `json:"nvram-template"`
+}
+
+type firmwareTarget struct {
+	Architecture string   `json:"architecture"`
+	Machines     []string `json:"machines"`
+}
+
+// firmwareDescriptor is a firmware descriptor file, see
+// docs/interop/firmware.json in the QEMU sources.
+type firmwareDescriptor struct {
+	Description    string           `json:"description"`
+	InterfaceTypes []string         `json:"interface-types"`
+	Mapping        firmwareMapping  `json:"mapping"`
+	Targets        []firmwareTarget `json:"targets"`
+	Features       []string         `json:"features"`
+
+	path string
+}
+
+// firmwareRequirements describe the firmware the VM needs.
+type firmwareRequirements struct {
+	Architecture string
+	MachineType  string
+	Features     []string
+	// SMM tells whether the VM has System Management Mode enabled, which
+	// the firmwares with the requires-smm feature need.
+	SMM bool
+}
+
+func (r firmwareRequirements) String() string {
+	s := fmt.Sprintf("architecture %s and machine type %s", r.Architecture, r.MachineType)
+	if len(r.Features) > 0 {
+		s += fmt.Sprintf(" with features %s", strings.Join(r.Features, ", "))
+	}
+	return s
+}
+
+// loadFirmwareDescriptors reads the descriptors of the directories. A
+// descriptor overrides the descriptors with the same file name in the
+// directories of lower priority, and an empty file masks them. Descriptors
+// are returned in file name order, which sets their priority.
+func loadFirmwareDescriptors(dirs []string) ([]firmwareDescriptor, error) {
+	paths := map[string]string{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		matches, err := filepath.Glob(filepath.Join(dirs[i], "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			paths[filepath.Base(match)] = match
+		}
+	}
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var descriptors []firmwareDescriptor
+	for _, name := range names {
+		content, err := os.ReadFile(paths[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read firmware descriptor: %s", err)
+		}
+		if len(strings.TrimSpace(string(content))) == 0 {
+			continue
+		}
+
+		var descriptor firmwareDescriptor
+		if err := json.Unmarshal(content, &descriptor); err != nil {
+			return nil, fmt.Errorf("failed to parse firmware descriptor %s: %s", paths[name], err)
+		}
+		descriptor.path = paths[name]
+		descriptors = append(descriptors, descriptor)
+	}
+
+	return descriptors, nil
+}
+
+// versionedMachineTypes maps the machine type aliases to the prefix of the
+// versioned machine types descriptors list.
+var versionedMachineTypes = map[string]string{
+	"pc":  "pc-i440fx",
+	"q35": "pc-q35",
+}
+
+// matchesMachineType tells whether a machine type glob of a descriptor
+// matches the machine type, or the versioned machine type it's an alias of.
+func matchesMachineType(pattern, machineType string) bool {
+	if matched, _ := path.Match(pattern, machineType); matched {
+		return true
+	}
+	versioned, ok := versionedMachineTypes[machineType]
+	if !ok {
+		versioned = machineType
+	}
+	matched, _ := path.Match(pattern, versioned+"-0.0")
+	return matched
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mismatch returns why the descriptor doesn't match the requirements, or an
+// empty string when it does.
+func (d firmwareDescriptor) mismatch(req firmwareRequirements) string {
+	if !containsString(d.InterfaceTypes, "uefi") {
+		return "not a UEFI firmware"
+	}
+	if d.Mapping.Device != "flash" {
+		return fmt.Sprintf("%s device, only flash is supported", d.Mapping.Device)
+	}
+	if d.Mapping.Mode != "" && d.Mapping.Mode != "split" {
+		return fmt.Sprintf("%s flash mode, only split is supported", d.Mapping.Mode)
+	}
+	if d.Mapping.Executable.Format != "raw" || d.Mapping.NVRAMTemplate.Format != "raw" {
+		return "not in raw format"
+	}
+
+	target := false
+	for _, t := range d.Targets {
+		if t.Architecture != req.Architecture {
+			continue
+		}
+		for _, machine := range t.Machines {
+			if matchesMachineType(machine, req.MachineType) {
+				target = true
+			}
+		}
+	}
+	if !target {
+		return fmt.Sprintf("doesn't support architecture %s and machine type %s", req.Architecture, req.MachineType)
+	}
+
+	for _, feature := range req.Features {
+		if !containsString(d.Features, feature) {
+			return fmt.Sprintf("doesn't have the %s feature", feature)
+		}
+	}
+	for _, feature := range confidentialFirmwareFeatures {
+		if containsString(d.Features, feature) && !containsString(req.Features, feature) {
+			return fmt.Sprintf("has the %s feature, which is not required", feature)
+		}
+	}
+	if containsString(d.Features, "requires-smm") && !req.SMM {
+		return "requires SMM, which is not enabled"
+	}
+
+	return ""
+}
+
+// selectFirmware picks the first descriptor matching the requirements. When
+// none matches, the error lists the descriptors considered and why they
+// were skipped.
+func selectFirmware(descriptors []firmwareDescriptor, req firmwareRequirements) (*firmwareDescriptor, error) {
+	var skipped []string
+	for i := range descriptors {
+		reason := descriptors[i].mismatch(req)
+		if reason == "" {
+			log.Printf("Using firmware descriptor %s: %s", descriptors[i].path, descriptors[i].Description)
+			return &descriptors[i], nil
+		}
+		skipped = append(skipped, fmt.Sprintf("  %s: %s", descriptors[i].path, reason))
+	}
+
+	if len(skipped) == 0 {
+		return nil, fmt.Errorf("no firmware descriptor was found for %s", req)
+	}
+	return nil, fmt.Errorf("no firmware descriptor matches %s, considered:\n%s",
+		req, strings.Join(skipped, "\n"))
+}
@@ -0,0 +1,186 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testFirmwareOVMF = `{
+    "description": "OVMF for x86_64, with SB, without enrolled keys, requires SMM",
+    "interface-types": ["uefi"],
+    "mapping": {
+        "device": "flash",
+        "executable": {"filename": "/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd", "format": "raw"},
+        "nvram-template": {"filename": "/usr/share/edk2/ovmf/OVMF_VARS.fd", "format": "raw"}
+    },
+    "targets": [{"architecture": "x86_64", "machines": ["pc-q35-*"]}],
+    "features": ["acpi-s3", "requires-smm", "secure-boot", "verbose-dynamic"],
+    "tags": []
+}`
+	testFirmwareOVMFNoSB = `{
+    "description": "OVMF without SB, without SMM",
+    "interface-types": ["uefi"],
+    "mapping": {
+        "device": "flash",
+        "mode": "split",
+        "executable": {"filename": "/usr/share/edk2/ovmf/OVMF_CODE.fd", "format": "raw"},
+        "nvram-template": {"filename": "/usr/share/edk2/ovmf/OVMF_VARS.fd", "format": "raw"}
+    },
+    "targets": [{"architecture": "x86_64", "machines": ["pc-i440fx-*", "pc-q35-*"]}],
+    "features": ["acpi-s3", "amd-sev", "verbose-dynamic"],
+    "tags": []
+}`
+	testFirmwareOVMFQcow2 = `{
+    "description": "OVMF without SB, qcow2",
+    "interface-types": ["uefi"],
+    "mapping": {
+        "device": "flash",
+        "executable": {"filename": "/usr/share/edk2/ovmf/OVMF_CODE.qcow2", "format": "qcow2"},
+        "nvram-template": {"filename": "/usr/share/edk2/ovmf/OVMF_VARS.qcow2", "format": "qcow2"}
+    },
+    "targets": [{"architecture": "x86_64", "machines": ["pc-i440fx-*", "pc-q35-*"]}],
+    "features": ["acpi-s3"],
+    "tags": []
+}`
+	testFirmwareAAVMF = `{
+    "description": "UEFI firmware for ARM64 virtual machines",
+    "interface-types": ["uefi"],
+    "mapping": {
+        "device": "flash",
+        "executable": {"filename": "/usr/share/AAVMF/AAVMF_CODE.fd", "format": "raw"},
+        "nvram-template": {"filename": "/usr/share/AAVMF/AAVMF_VARS.fd", "format": "raw"}
+    },
+    "targets": [{"architecture": "aarch64", "machines": ["virt-*"]}],
+    "features": [],
+    "tags": []
+}`
+)
+
+func writeFirmwareDescriptors(t *testing.T, dir string, descriptors map[string]string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range descriptors {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadFirmwareDescriptors(t *testing.T) {
+	root := t.TempDir()
+	etc := filepath.Join(root, "etc")
+	share := filepath.Join(root, "share")
+	writeFirmwareDescriptors(t, share, map[string]string{
+		"30-edk2-ovmf-x64-sb.json":  testFirmwareOVMF,
+		"40-edk2-ovmf-x64.json":     testFirmwareOVMFNoSB,
+		"60-edk2-aarch64.json":      testFirmwareAAVMF,
+		"20-edk2-ovmf-x64-sev.json": testFirmwareOVMFNoSB,
+	})
+	writeFirmwareDescriptors(t, etc, map[string]string{
+		// Overrides the descriptor of the same name.
+		"40-edk2-ovmf-x64.json": testFirmwareOVMFQcow2,
+		// Masks the descriptor of the same name.
+		"20-edk2-ovmf-x64-sev.json": "",
+	})
+
+	descriptors, err := loadFirmwareDescriptors([]string{etc, share})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	var got []string
+	for _, d := range descriptors {
+		got = append(got, d.path)
+	}
+	expected := []string{
+		filepath.Join(share, "30-edk2-ovmf-x64-sb.json"),
+		filepath.Join(etc, "40-edk2-ovmf-x64.json"),
+		filepath.Join(share, "60-edk2-aarch64.json"),
+	}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("bad descriptors: %v, expected %v", got, expected)
+	}
+
+	writeFirmwareDescriptors(t, etc, map[string]string{"10-broken.json": "{"})
+	if _, err := loadFirmwareDescriptors([]string{etc, share}); err == nil {
+		t.Fatal("should have error for a broken descriptor")
+	}
+}
+
+func TestSelectFirmware(t *testing.T) {
+	dir := t.TempDir()
+	writeFirmwareDescriptors(t, dir, map[string]string{
+		"30-edk2-ovmf-x64-sb.json":    testFirmwareOVMF,
+		"40-edk2-ovmf-x64-qcow2.json": testFirmwareOVMFQcow2,
+		"50-edk2-ovmf-x64-sev.json":   testFirmwareOVMFNoSB,
+		"60-edk2-aarch64.json":        testFirmwareAAVMF,
+	})
+	descriptors, err := loadFirmwareDescriptors([]string{dir})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	testcases := []struct {
+		req      firmwareRequirements
+		expected string
+	}{
+		{
+			firmwareRequirements{Architecture: "aarch64", MachineType: "virt"},
+			"/usr/share/AAVMF/AAVMF_CODE.fd",
+		},
+		{
+			firmwareRequirements{Architecture: "x86_64", MachineType: "q35", Features: []string{"amd-sev"}},
+			"/usr/share/edk2/ovmf/OVMF_CODE.fd",
+		},
+		{
+			firmwareRequirements{Architecture: "x86_64", MachineType: "pc-q35-8.2", Features: []string{"secure-boot"}, SMM: true},
+			"/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd",
+		},
+		{
+			// The SB firmware requires SMM, the qcow2 one isn't
+			// supported and the SEV one isn't picked implicitly.
+			firmwareRequirements{Architecture: "x86_64", MachineType: "q35"},
+			"",
+		},
+		{
+			firmwareRequirements{Architecture: "x86_64", MachineType: "pc", Features: []string{"secure-boot"}},
+			"",
+		},
+	}
+
+	for _, tc := range testcases {
+		firmware, err := selectFirmware(descriptors, tc.req)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("%s: should have error, got %s", tc.req, firmware.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: should not have error: %s", tc.req, err)
+			continue
+		}
+		if firmware.Mapping.Executable.Filename != tc.expected {
+			t.Errorf("%s: got %s, expected %s", tc.req, firmware.Mapping.Executable.Filename, tc.expected)
+		}
+	}
+
+	_, err = selectFirmware(descriptors, firmwareRequirements{Architecture: "x86_64", MachineType: "q35"})
+	for _, reason := range []string{
+		"30-edk2-ovmf-x64-sb.json: requires SMM",
+		"40-edk2-ovmf-x64-qcow2.json: not in raw format",
+		"50-edk2-ovmf-x64-sev.json: has the amd-sev feature",
+		"60-edk2-aarch64.json: doesn't support architecture x86_64",
+	} {
+		if !strings.Contains(err.Error(), reason) {
+			t.Errorf("the error should report %q: %s", reason, err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// guestAgentChannelName is the name of the virtio-serial port the QEMU guest
+// agent listens on in the guest.
+const guestAgentChannelName string = "org.qemu.guest_agent.0"
+
+type guestAgentRequest struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+type guestAgentResponse struct {
+	Return json.RawMessage  `json:"return"`
+	Error  *guestAgentError `json:"error"`
+}
+
+type guestAgentError struct {
+	Class       string `json:"class"`
+	Description string `json:"desc"`
+}
+
+func (e *guestAgentError) Error() string {
+	return fmt.Sprintf("guest agent error %s: %s", e.Class, e.Description)
+}
+
+type guestSyncArguments struct {
+	ID int64 `json:"id"`
+}
+
+type guestAgentInterface struct {
+	Name            string                `json:"name"`
+	HardwareAddress string                `json:"hardware-address"`
+	IPAddresses     []guestAgentIPAddress `json:"ip-addresses"`
+}
+
+type guestAgentIPAddress struct {
+	Type    string `json:"ip-address-type"`
+	Address string `json:"ip-address"`
+	Prefix  int    `json:"prefix"`
+}
+
+// guestAgentClient talks to the QEMU guest agent running in the VM through
+// the host side of its virtio-serial channel.
+//
+// The guest agent protocol is close to QMP, but there's no greeting or
+// capabilities negotiation, and the agent may not be running yet when the
+// host connects, so every exchange is guarded by a deadline.
+type guestAgentClient struct {
+	conn    net.Conn
+	dec     *json.Decoder
+	timeout time.Duration
+	synced  bool
+
+	lock sync.Mutex
+}
+
+func newGuestAgentClient(socketPath string, timeout time.Duration) (*guestAgentClient, error) {
+	conn, err := net.DialTimeout("unix", socketPath, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newGuestAgentClientFromConn(conn, timeout), nil
+}
+
+func newGuestAgentClientFromConn(conn net.Conn, timeout time.Duration) *guestAgentClient {
+	return &guestAgentClient{
+		conn:    conn,
+		dec:     json.NewDecoder(conn),
+		timeout: timeout,
+	}
+}
+
+func (c *guestAgentClient) Close() error {
+	return c.conn.Close()
+}
+
+// Run executes a guest agent command, and decodes its return value into
+// result, unless result is nil.
+func (c *guestAgentClient) Run(command string, arguments interface{}, result interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.synced {
+		if err := c.sync(); err != nil {
+			return fmt.Errorf("failed to synchronize with the guest agent: %w", err)
+		}
+		c.synced = true
+	}
+
+	response, err := c.exchange(guestAgentRequest{
+		Execute:   command,
+		Arguments: arguments,
+	})
+	if err != nil {
+		// We can't know where we are in the stream anymore, the next
+		// command will have to resynchronize.
+		c.synced = false
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Return, result)
+}
+
+// sync flushes any stale response left in the channel, e.g. from a previous
+// connection or a command that timed out, by waiting for the answer of a
+// guest-sync with a random id.
+func (c *guestAgentClient) sync() error {
+	id := rand.Int63()
+	if err := c.send(guestAgentRequest{
+		Execute:   "guest-sync",
+		Arguments: guestSyncArguments{ID: id},
+	}); err != nil {
+		return err
+	}
+
+	for {
+		response, err := c.receive()
+		if err != nil {
+			return err
+		}
+		var returnedID int64
+		if err := json.Unmarshal(response.Return, &returnedID); err == nil && returnedID == id {
+			return nil
+		}
+		log.Printf("Discarding stale guest agent response: %s", response.Return)
+	}
+}
+
+func (c *guestAgentClient) exchange(request guestAgentRequest) (*guestAgentResponse, error) {
+	if err := c.send(request); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+func (c *guestAgentClient) send(request guestAgentRequest) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err = c.conn.Write(payload)
+	return err
+}
+
+func (c *guestAgentClient) receive() (*guestAgentResponse, error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	var response guestAgentResponse
+	if err := c.dec.Decode(&response); err != nil {
+		// The decoder can't recover from a partial read, start afresh
+		c.dec = json.NewDecoder(c.conn)
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *guestAgentClient) NetworkGetInterfaces() ([]guestAgentInterface, error) {
+	var interfaces []guestAgentInterface
+	if err := c.Run("guest-network-get-interfaces", nil, &interfaces); err != nil {
+		return nil, err
+	}
+	return interfaces, nil
+}
+
+// getGuestAgentAddresses returns the addresses reported by the guest agent
+// for the interface with the given MAC address.
+func getGuestAgentAddresses(client *guestAgentClient, macAddress string) ([]net.IP, error) {
+	interfaces, err := client.NetworkGetInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range interfaces {
+		if !strings.EqualFold(iface.HardwareAddress, macAddress) {
+			continue
+		}
+		var addrs []net.IP
+		for _, addr := range iface.IPAddresses {
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				addrs = append(addrs, ip)
+			}
+		}
+		return addrs, nil
+	}
+
+	return nil, fmt.Errorf("could not find guest interface with MAC address %s", macAddress)
+}
@@ -0,0 +1,138 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// guestAgentHandler answers a guest agent command in fakeGuestAgent.
+type guestAgentHandler func(arguments json.RawMessage) (interface{}, error)
+
+// fakeGuestAgent answers guest agent requests on the guest side of a pipe,
+// with the handler of the command. A stale response is written before the
+// answer to the first guest-sync, as left behind by a previous connection.
+func fakeGuestAgent(t *testing.T, conn net.Conn, handlers map[string]guestAgentHandler) {
+	dec := json.NewDecoder(conn)
+	stale := true
+	for {
+		var request struct {
+			Execute   string          `json:"execute"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := dec.Decode(&request); err != nil {
+			return
+		}
+
+		var response interface{}
+		handler, ok := handlers[request.Execute]
+		switch {
+		case request.Execute == "guest-sync":
+			var args guestSyncArguments
+			if err := json.Unmarshal(request.Arguments, &args); err != nil {
+				t.Errorf("bad guest-sync arguments: %s", err)
+				return
+			}
+			if stale {
+				fmt.Fprint(conn, `{"return": 42}`)
+				stale = false
+			}
+			response = map[string]interface{}{"return": args.ID}
+		case ok:
+			result, err := handler(request.Arguments)
+			if err != nil {
+				response = map[string]interface{}{
+					"error": guestAgentError{Class: "GenericError", Description: err.Error()},
+				}
+			} else {
+				response = map[string]interface{}{"return": result}
+			}
+		default:
+			response = map[string]interface{}{
+				"error": guestAgentError{Class: "CommandNotFound", Description: "unknown command"},
+			}
+		}
+		if err := json.NewEncoder(conn).Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+func TestGuestAgentClient(t *testing.T) {
+	host, guest := net.Pipe()
+	defer guest.Close()
+	interfaces := []guestAgentInterface{
+		{
+			Name:            "lo",
+			HardwareAddress: "00:00:00:00:00:00",
+			IPAddresses: []guestAgentIPAddress{
+				{Type: "ipv4", Address: "127.0.0.1", Prefix: 8},
+			},
+		},
+		{
+			Name:            "eth0",
+			HardwareAddress: "52:54:00:12:34:56",
+			IPAddresses: []guestAgentIPAddress{
+				{Type: "ipv6", Address: "fe80::5054:ff:fe12:3456", Prefix: 64},
+				{Type: "ipv4", Address: "192.168.122.10", Prefix: 24},
+			},
+		},
+	}
+	go fakeGuestAgent(t, guest, map[string]guestAgentHandler{
+		"guest-network-get-interfaces": func(json.RawMessage) (interface{}, error) {
+			return interfaces, nil
+		},
+	})
+
+	client := newGuestAgentClientFromConn(host, 5*time.Second)
+	defer client.Close()
+
+	addrs, err := getGuestAgentAddresses(client, "52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("expected 2 addresses, got %v", addrs)
+	}
+	if got := selectGuestAddress(addrs, ""); got != "192.168.122.10" {
+		t.Fatalf("bad guest address: %s", got)
+	}
+
+	if _, err := getGuestAgentAddresses(client, "52:54:00:ff:ff:ff"); err == nil {
+		t.Fatal("should have error for an unknown MAC address")
+	}
+
+	if err := client.Run("guest-unknown", nil, nil); err == nil {
+		t.Fatal("should have error for an unknown command")
+	}
+}
+
+func TestSelectGuestAddress(t *testing.T) {
+	testcases := []struct {
+		addrs    []string
+		zone     string
+		expected string
+	}{
+		{[]string{"127.0.0.1", "10.0.0.2"}, "", "10.0.0.2"},
+		{[]string{"2001:db8::2", "10.0.0.2"}, "", "10.0.0.2"},
+		{[]string{"fe80::1", "2001:db8::2"}, "br0", "2001:db8::2"},
+		{[]string{"fe80::1", "169.254.0.2", "::1"}, "", ""},
+		{[]string{"fe80::1", "169.254.0.2", "::1"}, "br0", "fe80::1%br0"},
+		{nil, "br0", ""},
+	}
+
+	for _, tc := range testcases {
+		var addrs []net.IP
+		for _, addr := range tc.addrs {
+			addrs = append(addrs, net.ParseIP(addr))
+		}
+		if got := selectGuestAddress(addrs, tc.zone); got != tc.expected {
+			t.Errorf("selectGuestAddress(%v, %q) = %q, expected %q", tc.addrs, tc.zone, got, tc.expected)
+		}
+	}
+}
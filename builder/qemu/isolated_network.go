@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	// isolatedHTTPAddress is the address the Packer HTTP server is reachable
+	// at from the guest when the network is isolated.
+	isolatedHTTPAddress string = "10.0.2.100"
+	// The allowed endpoints are reachable from the guest at consecutive
+	// addresses starting at 10.0.2.101, in the order they are configured.
+	isolatedEndpointFirstHost = 101
+	isolatedEndpointLastHost  = 254
+)
+
+// isolatedEndpointAddress returns the address the allowed endpoint at index
+// is reachable at from the guest.
+func isolatedEndpointAddress(index int) string {
+	return fmt.Sprintf("10.0.2.%d", isolatedEndpointFirstHost+index)
+}
+
+func (c *Config) prepareIsolatedNetwork() []error {
+	var errs []error
+
+	if !c.IsolatedNetwork {
+		if len(c.IsolatedNetworkAllowedEndpoints) > 0 {
+			errs = append(errs, fmt.Errorf("isolated_network_allowed_endpoints can only be used with isolated_network"))
+		}
+		return errs
+	}
+
+	if _, ok := c.portForwardInterface(); !ok {
+		errs = append(errs, fmt.Errorf("isolated_network requires a network interface using user-mode networking"))
+	}
+
+	if len(c.IsolatedNetworkAllowedEndpoints) > isolatedEndpointLastHost-isolatedEndpointFirstHost+1 {
+		errs = append(errs, fmt.Errorf("isolated_network_allowed_endpoints can't have more than %d endpoints",
+			isolatedEndpointLastHost-isolatedEndpointFirstHost+1))
+	}
+
+	for _, endpoint := range c.IsolatedNetworkAllowedEndpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("isolated_network_allowed_endpoints: invalid endpoint %q: %s", endpoint, err))
+			continue
+		}
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			errs = append(errs, fmt.Errorf("isolated_network_allowed_endpoints: invalid port in endpoint %q", endpoint))
+		}
+		if host == "" {
+			errs = append(errs, fmt.Errorf("isolated_network_allowed_endpoints: missing host in endpoint %q", endpoint))
+		}
+	}
+
+	return errs
+}
+
+// isolatedNetdevOptions returns the options that restrict a user-mode netdev
+// and forward the guest connections to the HTTP server and the allowed
+// endpoints.
+//
+// guestfwd hands each connection to a command on the host, netcat pipes it
+// to the actual endpoint.
+func (c *Config) isolatedNetdevOptions(httpPort int, guestForwards bool) []string {
+	options := []string{"restrict=on"}
+	if !guestForwards {
+		return options
+	}
+
+	if httpPort > 0 {
+		httpAddress := c.HTTPAddress
+		if httpAddress == "" || httpAddress == "0.0.0.0" {
+			httpAddress = "127.0.0.1"
+		}
+		options = append(options, fmt.Sprintf("guestfwd=tcp:%s:%d-cmd:nc %s %d",
+			isolatedHTTPAddress, httpPort, httpAddress, httpPort))
+	}
+
+	for i, endpoint := range c.IsolatedNetworkAllowedEndpoints {
+		host, port, _ := net.SplitHostPort(endpoint)
+		options = append(options, fmt.Sprintf("guestfwd=tcp:%s:%s-cmd:nc %s %s",
+			isolatedEndpointAddress(i), port, host, port))
+	}
+
+	return options
+}
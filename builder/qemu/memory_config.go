@@ -0,0 +1,161 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// memoryBackendID is the id of the memory backend the memory of the VM is
+// allocated from, suffixed with the node with NUMA.
+const memoryBackendID string = "packer-mem"
+
+// maxMemorySlots is the maximum number of memory hotplug slots of QEMU.
+const maxMemorySlots = 256
+
+// QemuMemoryConfig tunes how the memory of the VM is allocated and exposed
+// to the guest, on top of its `memory` size.
+//
+// The memory is allocated from memory backends when it's backed by
+// hugepages, split in NUMA nodes or shared with virtiofsd, for
+// `shared_folders`. This requires QEMU 5.0 or later. Without `numa`, a
+// `-machine` argument of `qemuargs` must then include
+// `memory-backend=packer-mem`, as it replaces the default one.
+type QemuMemoryConfig struct {
+	// Back the memory of the VM with hugepages, from `memory_hugepages_path`.
+	// The hugepages must be reserved on the host beforehand, e.g. with
+	// `sysctl vm.nr_hugepages`, and the memory of the VM, or of each NUMA
+	// node, must be a multiple of the hugepage size. Only Linux hosts
+	// support hugepages.
+	MemoryHugepages bool `mapstructure:"memory_hugepages" required:"false"`
+	// The hugetlbfs mount the hugepages are allocated from. Defaults to
+	// `/dev/hugepages`.
+	MemoryHugepagesPath string `mapstructure:"memory_hugepages_path" required:"false"`
+	// Allocate all the memory of the VM when it starts, instead of when the
+	// guest first uses it.
+	MemoryPrealloc bool `mapstructure:"memory_prealloc" required:"false"`
+	// Split the VM in a NUMA node per socket, with the CPUs of the socket and
+	// an equal share of the memory. This requires `sockets` to be set, and
+	// `memory` to be a multiple of it.
+	NUMA bool `mapstructure:"numa" required:"false"`
+	// Attach a virtio-balloon device, so the guest can report its free
+	// memory and the host can reclaim it.
+	MemoryBalloon bool `mapstructure:"memory_balloon" required:"false"`
+	// The maximum size of the memory in megabytes, memory can be hotplugged
+	// in the VM up to it. Requires `memory_slots`.
+	MemoryMaxSize int `mapstructure:"memory_max" required:"false"`
+	// The number of slots memory can be hotplugged in, up to 256. Requires
+	// `memory_max`.
+	MemorySlots int `mapstructure:"memory_slots" required:"false"`
+}
+
+// prepareMemory checks the memory configuration, once the memory size and
+// the shared folders are set.
+func (c *Config) prepareMemory() []error {
+	var errs []error
+
+	if c.MemoryHugepages {
+		if runtime.GOOS != "linux" {
+			errs = append(errs, errors.New("memory_hugepages is only supported in Linux based OSes"))
+		}
+		if c.MemoryHugepagesPath == "" {
+			c.MemoryHugepagesPath = "/dev/hugepages"
+		}
+		if info, err := os.Stat(c.MemoryHugepagesPath); err != nil {
+			errs = append(errs, fmt.Errorf("memory_hugepages_path: %s", err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("memory_hugepages_path: %s is not a directory", c.MemoryHugepagesPath))
+		}
+	} else if c.MemoryHugepagesPath != "" {
+		errs = append(errs, errors.New("memory_hugepages_path requires memory_hugepages"))
+	}
+
+	if c.NUMA {
+		if c.SocketCount < 1 {
+			errs = append(errs, errors.New("numa requires sockets, a NUMA node is created per socket"))
+		} else if c.MemorySize%c.SocketCount != 0 {
+			errs = append(errs, fmt.Errorf("numa requires memory to be a multiple of sockets, %dM can't be split in %d nodes",
+				c.MemorySize, c.SocketCount))
+		}
+	}
+
+	if (c.MemoryMaxSize == 0) != (c.MemorySlots == 0) {
+		errs = append(errs, errors.New("memory_max and memory_slots must be set together"))
+	} else if c.MemorySlots != 0 {
+		if c.MemoryMaxSize <= c.MemorySize {
+			errs = append(errs, fmt.Errorf("memory_max must be greater than memory (%dM)", c.MemorySize))
+		}
+		if c.MemorySlots < 0 || c.MemorySlots > maxMemorySlots {
+			errs = append(errs, fmt.Errorf("memory_slots must be between 1 and %d", maxMemorySlots))
+		}
+	}
+
+	return errs
+}
+
+// usesMemoryBackends tells whether the memory of the VM is allocated from
+// explicit memory backends.
+func (c *Config) usesMemoryBackends() bool {
+	return c.MemoryHugepages || c.NUMA || c.SharedFoldersBackend == "virtiofs"
+}
+
+// memoryBackendArg returns the -object argument of the memory backend with
+// the id and size.
+func (c *Config) memoryBackendArg(id string, size int) string {
+	// virtiofsd accesses the memory of the VM
+	share := c.SharedFoldersBackend == "virtiofs"
+
+	var arg string
+	switch {
+	case c.MemoryHugepages:
+		arg = fmt.Sprintf("memory-backend-file,id=%s,size=%dM,mem-path=%s", id, size, c.MemoryHugepagesPath)
+	case share:
+		arg = fmt.Sprintf("memory-backend-memfd,id=%s,size=%dM", id, size)
+	default:
+		arg = fmt.Sprintf("memory-backend-ram,id=%s,size=%dM", id, size)
+	}
+	if share {
+		arg += ",share=on"
+	}
+	if c.MemoryPrealloc {
+		arg += ",prealloc=on"
+	}
+	return arg
+}
+
+// memoryArgs returns the -object arguments of the memory backends, and
+// the -numa arguments of the NUMA nodes using them. Without NUMA, the
+// memory of the VM is allocated from the single backend memoryBackendID.
+func (c *Config) memoryArgs() (objectArgs []string, numaArgs []string) {
+	if !c.usesMemoryBackends() {
+		return nil, nil
+	}
+
+	if !c.NUMA {
+		return []string{c.memoryBackendArg(memoryBackendID, c.MemorySize)}, nil
+	}
+
+	nodeSize := c.MemorySize / c.SocketCount
+	for i := 0; i < c.SocketCount; i++ {
+		id := fmt.Sprintf("%s%d", memoryBackendID, i)
+		objectArgs = append(objectArgs, c.memoryBackendArg(id, nodeSize))
+		numaArgs = append(numaArgs,
+			fmt.Sprintf("node,nodeid=%d,memdev=%s", i, id),
+			fmt.Sprintf("cpu,node-id=%d,socket-id=%d", i, i))
+	}
+	return objectArgs, numaArgs
+}
+
+// memorySizeArg returns the -m argument.
+func (c *Config) memorySizeArg() string {
+	if c.MemorySlots > 0 {
+		return fmt.Sprintf("%dM,slots=%d,maxmem=%dM", c.MemorySize, c.MemorySlots, c.MemoryMaxSize)
+	}
+	return fmt.Sprintf("%dM", c.MemorySize)
+}
@@ -0,0 +1,123 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package qemu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Neighbor attributes and states, see
+// https://github.com/torvalds/linux/blob/v5.4/include/uapi/linux/neighbour.h
+const (
+	ndaDst    uint16 = 1 // NDA_DST
+	ndaLLAddr uint16 = 2 // NDA_LLADDR
+
+	nudReachable uint16 = 0x02 // NUD_REACHABLE
+	nudStale     uint16 = 0x04 // NUD_STALE
+	nudDelay     uint16 = 0x08 // NUD_DELAY
+	nudProbe     uint16 = 0x10 // NUD_PROBE
+	nudPermanent uint16 = 0x80 // NUD_PERMANENT
+
+	nudValid = nudReachable | nudStale | nudDelay | nudProbe | nudPermanent
+
+	// sizeof(struct ndmsg)
+	ndMsgLen = 12
+)
+
+// getIPv6NeighborAddresses returns the IPv6 addresses of the given MAC
+// address in the neighbor table of the host, like `ip -6 neigh` does.
+//
+// The IPv6 neighbor table is not exposed in /proc like the ARP table is, so
+// it's dumped through netlink.
+func getIPv6NeighborAddresses(device string, macAddress string) ([]net.IP, error) {
+	ifindex := 0
+	if device != "" {
+		iface, err := net.InterfaceByName(device)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the %s interface: %w", device, err)
+		}
+		ifindex = iface.Index
+	}
+
+	hwAddr, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump the IPv6 neighbor table: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the IPv6 neighbor table: %w", err)
+	}
+
+	return parseIPv6Neighbors(msgs, ifindex, hwAddr), nil
+}
+
+func parseIPv6Neighbors(msgs []syscall.NetlinkMessage, ifindex int, hwAddr net.HardwareAddr) []net.IP {
+	var addrs []net.IP
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < ndMsgLen {
+			continue
+		}
+
+		// struct ndmsg {
+		//	__u8	ndm_family;
+		//	__u8	ndm_pad1;
+		//	__u16	ndm_pad2;
+		//	__s32	ndm_ifindex;
+		//	__u16	ndm_state;
+		//	__u8	ndm_flags;
+		//	__u8	ndm_type;
+		// };
+		family := m.Data[0]
+		index := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+
+		if family != syscall.AF_INET6 || state&nudValid == 0 {
+			continue
+		}
+		if ifindex != 0 && index != ifindex {
+			continue
+		}
+
+		var dst net.IP
+		var lladdr net.HardwareAddr
+		for attrs := m.Data[ndMsgLen:]; len(attrs) >= syscall.SizeofRtAttr; {
+			attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+			attrType := binary.NativeEndian.Uint16(attrs[2:4])
+			if attrLen < syscall.SizeofRtAttr || attrLen > len(attrs) {
+				break
+			}
+			value := attrs[syscall.SizeofRtAttr:attrLen]
+			switch attrType {
+			case ndaDst:
+				dst = net.IP(append([]byte(nil), value...))
+			case ndaLLAddr:
+				lladdr = net.HardwareAddr(append([]byte(nil), value...))
+			}
+			if rtaAlign(attrLen) >= len(attrs) {
+				break
+			}
+			attrs = attrs[rtaAlign(attrLen):]
+		}
+
+		if dst != nil && bytes.Equal(lladdr, hwAddr) {
+			addrs = append(addrs, dst)
+		}
+	}
+	return addrs
+}
+
+func rtaAlign(length int) int {
+	return (length + syscall.RTA_ALIGNTO - 1) & ^(syscall.RTA_ALIGNTO - 1)
+}
@@ -0,0 +1,62 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package qemu
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func neighborMessage(family uint8, ifindex int32, state uint16, dst net.IP, lladdr net.HardwareAddr) syscall.NetlinkMessage {
+	data := make([]byte, ndMsgLen)
+	data[0] = family
+	binary.NativeEndian.PutUint32(data[4:8], uint32(ifindex))
+	binary.NativeEndian.PutUint16(data[8:10], state)
+
+	for _, attr := range []struct {
+		typ   uint16
+		value []byte
+	}{{ndaDst, dst}, {ndaLLAddr, lladdr}} {
+		header := make([]byte, syscall.SizeofRtAttr)
+		binary.NativeEndian.PutUint16(header[0:2], uint16(syscall.SizeofRtAttr+len(attr.value)))
+		binary.NativeEndian.PutUint16(header[2:4], attr.typ)
+		data = append(data, header...)
+		data = append(data, attr.value...)
+		data = append(data, make([]byte, rtaAlign(len(attr.value))-len(attr.value))...)
+	}
+
+	return syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: syscall.RTM_NEWNEIGH},
+		Data:   data,
+	}
+}
+
+func TestParseIPv6Neighbors(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	other, _ := net.ParseMAC("52:54:00:ab:cd:ef")
+
+	msgs := []syscall.NetlinkMessage{
+		neighborMessage(syscall.AF_INET6, 3, nudReachable, net.ParseIP("fe80::5054:ff:fe12:3456"), mac),
+		neighborMessage(syscall.AF_INET6, 3, nudStale, net.ParseIP("2001:db8::10"), mac),
+		neighborMessage(syscall.AF_INET6, 3, 0x20, net.ParseIP("2001:db8::11"), mac), // NUD_FAILED
+		neighborMessage(syscall.AF_INET6, 4, nudReachable, net.ParseIP("2001:db8::12"), mac),
+		neighborMessage(syscall.AF_INET6, 3, nudReachable, net.ParseIP("2001:db8::13"), other),
+	}
+
+	addrs := parseIPv6Neighbors(msgs, 3, mac)
+	expected := []net.IP{net.ParseIP("fe80::5054:ff:fe12:3456"), net.ParseIP("2001:db8::10")}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Fatalf("bad addresses: %v, expected %v", addrs, expected)
+	}
+
+	addrs = parseIPv6Neighbors(msgs, 0, mac)
+	if len(addrs) != 3 {
+		t.Fatalf("expected 3 addresses on all interfaces, got %v", addrs)
+	}
+}
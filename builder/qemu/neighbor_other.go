@@ -0,0 +1,15 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !linux
+
+package qemu
+
+import (
+	"fmt"
+	"net"
+)
+
+func getIPv6NeighborAddresses(device string, macAddress string) ([]net.IP, error) {
+	return nil, fmt.Errorf("the IPv6 neighbor table lookup is only supported in Linux based OSes")
+}
@@ -0,0 +1,61 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The host interface checks run when the configuration is prepared. They are
+// variables so that tests can run on hosts without these interfaces.
+var (
+	checkHostBridge    = hostBridgeCheck
+	checkHostTapDevice = hostTapDeviceCheck
+)
+
+// bridgeHelperACLFile is the default ACL file of qemu-bridge-helper.
+const bridgeHelperACLFile string = "/etc/qemu/bridge.conf"
+
+// netdevFailureHints returns suggestions to fix the network backends of the
+// VM, based on the errors QEMU reported when failing to start.
+func netdevFailureHints(nics []QemuNetworkInterface, qemuOutput string) []string {
+	var hints []string
+	for _, nic := range nics {
+		switch nic.Backend {
+		case "bridge":
+			if !strings.Contains(qemuOutput, "bridge") {
+				continue
+			}
+			switch {
+			case strings.Contains(qemuOutput, "access denied by acl file"):
+				hints = append(hints, fmt.Sprintf("qemu-bridge-helper is not allowed to use the %s bridge, add 'allow %s' to %s.",
+					nic.Bridge, nic.Bridge, bridgeHelperACLFile))
+			case strings.Contains(qemuOutput, "failed to parse default acl file"):
+				hints = append(hints, fmt.Sprintf("qemu-bridge-helper could not read %s, create it with an 'allow %s' line and make it readable by qemu-bridge-helper.",
+					bridgeHelperACLFile, nic.Bridge))
+			case strings.Contains(qemuOutput, "failed to launch bridge helper"),
+				strings.Contains(qemuOutput, "bridge helper failed"):
+				hints = append(hints, "qemu-bridge-helper could not be run, make sure it is installed, and that it is setuid root "+
+					"(chmod u+s) or has the CAP_NET_ADMIN capability (setcap cap_net_admin+ep).")
+			case strings.Contains(qemuOutput, "failed to create tun device"):
+				hints = append(hints, "qemu-bridge-helper is not allowed to create a tap device, make sure it is setuid root "+
+					"(chmod u+s) or has the CAP_NET_ADMIN capability (setcap cap_net_admin+ep).")
+			}
+		case "tap":
+			if !strings.Contains(qemuOutput, nic.TapDevice) {
+				continue
+			}
+			switch {
+			case strings.Contains(qemuOutput, "Operation not permitted"):
+				hints = append(hints, fmt.Sprintf("The %s tap device is not owned by the user running Packer, recreate it with 'ip tuntap add dev %s mode tap user $USER'.",
+					nic.TapDevice, nic.TapDevice))
+			case strings.Contains(qemuOutput, "Device or resource busy"):
+				hints = append(hints, fmt.Sprintf("The %s tap device is already used by another process, make sure no other VM is attached to it.",
+					nic.TapDevice))
+			}
+		}
+	}
+	return hints
+}
@@ -0,0 +1,174 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package qemu
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// IFF_TAP, see https://github.com/torvalds/linux/blob/v5.4/include/uapi/linux/if_tun.h#L67
+	iffTap int64 = 0x0002
+)
+
+func hostBridgeCheck(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	if _, err := net.InterfaceByName(name); err != nil {
+		return fmt.Errorf("the %s bridge does not exist, create it with 'ip link add %s type bridge' "+
+			"or start the libvirt network it belongs to", name, name)
+	}
+	if _, err := os.Stat(filepath.Join("/sys/class/net", name, "bridge")); err != nil {
+		return fmt.Errorf("the %s interface is not a bridge", name)
+	}
+
+	// root doesn't go through qemu-bridge-helper's ACL.
+	if os.Getuid() == 0 {
+		return nil
+	}
+
+	allowed, err := bridgeHelperAllows(bridgeHelperACLFile, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s does not exist, qemu-bridge-helper needs it to contain 'allow %s' to attach the VM to the %s bridge",
+			bridgeHelperACLFile, name, name)
+	}
+	if err != nil {
+		// The ACL file is usually only readable by root and the setuid
+		// helper, QEMU will report the problem if there is one.
+		return nil
+	}
+	if !allowed {
+		return fmt.Errorf("qemu-bridge-helper is not allowed to use the %s bridge, add 'allow %s' to %s",
+			name, name, bridgeHelperACLFile)
+	}
+
+	return nil
+}
+
+// bridgeHelperAllows tells whether the qemu-bridge-helper ACL file allows
+// the given bridge. As in qemu-bridge-helper, deny rules take precedence over
+// allow rules, and included files are processed as if they were inlined.
+func bridgeHelperAllows(path string, bridge string) (bool, error) {
+	var allowed, denied bool
+
+	var parse func(path string) error
+	parse = func(path string) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			switch fields[0] {
+			case "allow":
+				allowed = allowed || fields[1] == "all" || fields[1] == bridge
+			case "deny":
+				denied = denied || fields[1] == "all" || fields[1] == bridge
+			case "include":
+				matches, _ := filepath.Glob(fields[1])
+				for _, match := range matches {
+					if err := parse(match); err != nil && !errors.Is(err, os.ErrPermission) {
+						return err
+					}
+				}
+			}
+		}
+		return s.Err()
+	}
+
+	if err := parse(path); err != nil {
+		return false, err
+	}
+	return allowed && !denied, nil
+}
+
+func hostTapDeviceCheck(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	if _, err := net.InterfaceByName(name); err != nil {
+		return fmt.Errorf("the %s tap device does not exist, create it with 'ip tuntap add dev %s mode tap user $USER'", name, name)
+	}
+
+	sysfsPath := filepath.Join("/sys/class/net", name)
+	content, err := os.ReadFile(filepath.Join(sysfsPath, "tun_flags"))
+	if err != nil {
+		return fmt.Errorf("the %s interface is not a tap device", name)
+	}
+	flags, err := strconv.ParseInt(strings.TrimSpace(string(content)), 0, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse the %s tap device flags %q: %s", name, content, err)
+	}
+	if flags&iffTap == 0 {
+		return fmt.Errorf("the %s interface is a tun device, recreate it with 'ip tuntap add dev %s mode tap user $USER'", name, name)
+	}
+
+	if err := syscall.Access("/dev/net/tun", 0x6); err != nil { // R_OK | W_OK
+		return fmt.Errorf("/dev/net/tun is not accessible, the current user needs read and write access to it to use the %s tap device: %s", name, err)
+	}
+
+	uid := os.Getuid()
+	if uid == 0 {
+		return nil
+	}
+	owner := readSysfsInt(filepath.Join(sysfsPath, "owner"))
+	group := readSysfsInt(filepath.Join(sysfsPath, "group"))
+	if owner == uid || (group != -1 && isInGroup(group)) {
+		return nil
+	}
+	if owner == -1 && group == -1 {
+		return fmt.Errorf("the %s tap device has no owner, only root can use it, recreate it with 'ip tuntap add dev %s mode tap user $USER'", name, name)
+	}
+	return fmt.Errorf("the %s tap device is owned by uid %d and gid %d, which the current user is not, "+
+		"recreate it with 'ip tuntap add dev %s mode tap user $USER'", name, owner, group, name)
+}
+
+// readSysfsInt reads an integer sysfs attribute, returning -1 if it can't be
+// read, which is also what sysfs reports for unset tap owners and groups.
+func readSysfsInt(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return -1
+	}
+	return value
+}
+
+func isInGroup(gid int) bool {
+	if os.Getgid() == gid {
+		return true
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return false
+	}
+	for _, g := range groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package qemu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHostNetworkChecks(t *testing.T) {
+	if err := hostBridgeCheck("packer-nonexistent0"); err == nil {
+		t.Error("should have error for a missing bridge")
+	}
+	if err := hostTapDeviceCheck("packer-nonexistent0"); err == nil {
+		t.Error("should have error for a missing tap device")
+	}
+
+	if _, err := os.Stat("/sys/class/net/lo"); err != nil {
+		t.Skip("no loopback interface in sysfs")
+	}
+	if err := hostBridgeCheck("lo"); err == nil {
+		t.Error("should have error for an interface that is not a bridge")
+	}
+	if err := hostTapDeviceCheck("lo"); err == nil {
+		t.Error("should have error for an interface that is not a tap device")
+	}
+}
+
+func TestBridgeHelperAllows(t *testing.T) {
+	dir := t.TempDir()
+	included := filepath.Join(dir, "virbr0.conf")
+	if err := os.WriteFile(included, []byte("allow virbr0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	acl := filepath.Join(dir, "bridge.conf")
+	content := "# bridges\nallow br0\ndeny br1\nallow br1\ninclude " + filepath.Join(dir, "*.conf.d") + "\ninclude " + included + "\n"
+	if err := os.WriteFile(acl, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := map[string]bool{
+		"br0":    true,
+		"br1":    false,
+		"br2":    false,
+		"virbr0": true,
+	}
+	for bridge, expected := range testcases {
+		allowed, err := bridgeHelperAllows(acl, bridge)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if allowed != expected {
+			t.Errorf("bridgeHelperAllows(%q) = %t, expected %t", bridge, allowed, expected)
+		}
+	}
+
+	if _, err := bridgeHelperAllows(filepath.Join(dir, "missing.conf"), "br0"); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
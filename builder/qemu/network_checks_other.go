@@ -0,0 +1,16 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !linux
+
+package qemu
+
+// The bridge backend is only supported in Linux based OSes, which is already
+// reported by Prepare.
+func hostBridgeCheck(name string) error {
+	return nil
+}
+
+func hostTapDeviceCheck(name string) error {
+	return nil
+}
@@ -0,0 +1,493 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type QemuNetworkInterface,QemuPortForward
+
+package qemu
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var netBackends = map[string]bool{
+	"user":   true,
+	"bridge": true,
+	"tap":    true,
+	"socket": true,
+	"none":   true,
+}
+
+var netSocketModes = map[string]bool{
+	"mcast": true,
+	"udp":   true,
+}
+
+var guestAddressSources = map[string]bool{
+	"arp":         true,
+	"dhcp_leases": true,
+	"guest_agent": true,
+	"static":      true,
+}
+
+var portForwardProtocols = map[string]bool{
+	"tcp": true,
+	"udp": true,
+}
+
+// QemuNetworkInterface describes a network interface to attach to the VM.
+//
+// Each `network_interface` block adds one NIC to the VM, with its own
+// device model and network backend. When no `network_interface` block is
+// defined, the builder attaches a single NIC configured through
+// `net_device`, `net_backend` and `net_bridge`.
+type QemuNetworkInterface struct {
+	// The device model to use for this interface. Any of the values
+	// accepted by `net_device` can be used here. Defaults to the value of
+	// `net_device`.
+	Model string `mapstructure:"model" required:"false"`
+	// The MAC address of the interface, e.g. `52:54:00:12:34:56`. If unset,
+	// QEMU will generate one.
+	MACAddress string `mapstructure:"mac_address" required:"false"`
+	// The network backend the interface is connected to. Allowed values are
+	// `user`, `bridge`, `tap`, `socket` or `none`. `none` attaches a NIC
+	// with no backend, as if its cable was unplugged. Defaults to `user`.
+	Backend string `mapstructure:"backend" required:"false"`
+	// The host bridge to connect the interface to. Required when `backend`
+	// is `bridge`. This only works in Linux based OSes.
+	Bridge string `mapstructure:"bridge" required:"false"`
+	// The name of a pre-created tap device on the host. Required when
+	// `backend` is `tap`.
+	TapDevice string `mapstructure:"tap_device" required:"false"`
+	// The socket mode to use when `backend` is `socket`, either `mcast` or
+	// `udp`. Defaults to `mcast`. When the communicator interface uses the
+	// socket backend, `http_bind_address` must be set to an address of the
+	// host the guest can reach, which `{{ .HTTPIP }}` is set to.
+	SocketMode string `mapstructure:"socket_mode" required:"false"`
+	// The address of the socket backend, in `host:port` form. For `mcast`
+	// this is the multicast group shared by all the VMs of the network,
+	// for `udp` this is the remote endpoint.
+	SocketAddress string `mapstructure:"socket_address" required:"false"`
+	// The local `host:port` to bind to when `socket_mode` is `udp`.
+	SocketLocalAddress string `mapstructure:"socket_local_address" required:"false"`
+	// Extra port forwarding rules for the `user` backend, in the QEMU
+	// `hostfwd` syntax, e.g. `tcp::8080-:80`.
+	HostForwards []string `mapstructure:"hostfwd" required:"false"`
+	// Use this interface to reach the communicator (SSH, WinRM).
+	// At most one interface can set this. If none does, the first
+	// interface is used.
+	Communicator bool `mapstructure:"communicator" required:"false"`
+
+	// netdevID is the id of the `-netdev` backing this interface.
+	netdevID string
+	// deviceID is the id of the `-device` for this interface, empty for the
+	// implicit interface so its command-line stays as it always was.
+	deviceID string
+}
+
+func (nic *QemuNetworkInterface) Prepare(index int, defaultModel string) []error {
+	var errs []error
+
+	if nic.Model == "" {
+		nic.Model = defaultModel
+	}
+
+	if nic.Backend == "" {
+		nic.Backend = "user"
+	}
+
+	if !netBackends[nic.Backend] {
+		errs = append(errs, fmt.Errorf("network_interface %d: unrecognized backend %q, only 'user', 'bridge', 'tap', 'socket' or 'none' are allowed", index, nic.Backend))
+	}
+
+	if nic.MACAddress != "" {
+		if _, err := net.ParseMAC(nic.MACAddress); err != nil {
+			errs = append(errs, fmt.Errorf("network_interface %d: invalid mac_address: %s", index, err))
+		}
+	}
+
+	if nic.Backend == "bridge" {
+		if nic.Bridge == "" {
+			errs = append(errs, fmt.Errorf("network_interface %d: bridge must be set when backend is 'bridge'", index))
+		}
+		if runtime.GOOS != "linux" {
+			errs = append(errs, fmt.Errorf("network_interface %d: the 'bridge' backend is only supported in Linux based OSes", index))
+		}
+	}
+
+	if nic.Backend == "tap" && nic.TapDevice == "" {
+		errs = append(errs, fmt.Errorf("network_interface %d: tap_device must be set when backend is 'tap'", index))
+	}
+
+	if nic.Backend == "socket" {
+		if nic.SocketMode == "" {
+			nic.SocketMode = "mcast"
+		}
+		if !netSocketModes[nic.SocketMode] {
+			errs = append(errs, fmt.Errorf("network_interface %d: unrecognized socket_mode %q, only 'mcast' or 'udp' are allowed", index, nic.SocketMode))
+		}
+		if nic.SocketAddress == "" {
+			errs = append(errs, fmt.Errorf("network_interface %d: socket_address must be set when backend is 'socket'", index))
+		}
+		if nic.SocketMode == "udp" && nic.SocketLocalAddress == "" {
+			errs = append(errs, fmt.Errorf("network_interface %d: socket_local_address must be set when socket_mode is 'udp'", index))
+		}
+	}
+
+	if len(nic.HostForwards) > 0 && nic.Backend != "user" {
+		errs = append(errs, fmt.Errorf("network_interface %d: hostfwd can only be used with the 'user' backend", index))
+	}
+
+	return errs
+}
+
+// getNetdevArg returns the `-netdev` argument for the interface, or an empty
+// string if the interface has no backend.
+//
+// extraHostForwards are prepended to the interface's own hostfwd rules, this
+// is used to forward the communicator port.
+func (nic *QemuNetworkInterface) getNetdevArg(extraHostForwards ...string) string {
+	switch nic.Backend {
+	case "bridge":
+		return fmt.Sprintf("bridge,id=%s,br=%s", nic.netdevID, nic.Bridge)
+	case "tap":
+		return fmt.Sprintf("tap,id=%s,ifname=%s,script=no,downscript=no", nic.netdevID, nic.TapDevice)
+	case "socket":
+		arg := fmt.Sprintf("socket,id=%s,%s=%s", nic.netdevID, nic.SocketMode, nic.SocketAddress)
+		if nic.SocketMode == "udp" {
+			arg = fmt.Sprintf("%s,localaddr=%s", arg, nic.SocketLocalAddress)
+		}
+		return arg
+	case "none":
+		return ""
+	}
+
+	arg := fmt.Sprintf("user,id=%s", nic.netdevID)
+	for _, fwd := range append(extraHostForwards, nic.HostForwards...) {
+		arg = fmt.Sprintf("%s,hostfwd=%s", arg, fwd)
+	}
+	return arg
+}
+
+// getDeviceArg returns the `-device` argument for the interface.
+func (nic *QemuNetworkInterface) getDeviceArg() string {
+	args := []string{nic.Model}
+	if nic.Backend != "none" {
+		args = append(args, fmt.Sprintf("netdev=%s", nic.netdevID))
+	}
+	if nic.MACAddress != "" {
+		args = append(args, fmt.Sprintf("mac=%s", nic.MACAddress))
+	}
+	if nic.deviceID != "" {
+		args = append(args, fmt.Sprintf("id=%s", nic.deviceID))
+	}
+	return strings.Join(args, ",")
+}
+
+// getDeviceMatch returns a string that identifies the interface device among
+// user-supplied `-device` arguments.
+func (nic *QemuNetworkInterface) getDeviceMatch() string {
+	if nic.deviceID == "" {
+		// Historically, the implicit interface is considered present as soon
+		// as any device of the configured model is.
+		return nic.Model
+	}
+	if nic.Backend == "none" {
+		return fmt.Sprintf("id=%s", nic.deviceID)
+	}
+	return fmt.Sprintf("netdev=%s", nic.netdevID)
+}
+
+// QemuPortForward forwards a port of the host to the guest through user-mode
+// networking.
+//
+// The host port is picked among the available ports of the
+// `host_port_min`-`host_port_max` range, the same way the communicator port
+// is. The chosen port is exposed to provisioners as the `HostPort<PROTOCOL><GUEST_PORT>`
+// build variable, e.g. `build.HostPortTCP8080` for a forward of the TCP port
+// 8080 of the guest.
+type QemuPortForward struct {
+	// The port of the guest to forward.
+	GuestPort int `mapstructure:"guest_port" required:"true"`
+	// The protocol to forward, either `tcp` or `udp`. Defaults to `tcp`.
+	Protocol string `mapstructure:"protocol" required:"false"`
+	// The minimum port to use on the host. Defaults to the communicator
+	// `host_port_min`.
+	HostPortMin int `mapstructure:"host_port_min" required:"false"`
+	// The maximum port to use on the host. Defaults to `host_port_min` if
+	// that was set, or to the communicator `host_port_max` otherwise.
+	HostPortMax int `mapstructure:"host_port_max" required:"false"`
+}
+
+func (pf *QemuPortForward) Prepare(index int, commConfig *CommConfig) []error {
+	var errs []error
+
+	if pf.Protocol == "" {
+		pf.Protocol = "tcp"
+	}
+
+	if !portForwardProtocols[pf.Protocol] {
+		errs = append(errs, fmt.Errorf("port_forwards %d: unrecognized protocol %q, only 'tcp' or 'udp' are allowed", index, pf.Protocol))
+	}
+
+	if pf.GuestPort <= 0 || pf.GuestPort > 65535 {
+		errs = append(errs, fmt.Errorf("port_forwards %d: guest_port must be a valid port number", index))
+	}
+
+	if pf.HostPortMin == 0 && pf.HostPortMax == 0 {
+		pf.HostPortMin = commConfig.HostPortMin
+		pf.HostPortMax = commConfig.HostPortMax
+	}
+
+	if pf.HostPortMax == 0 {
+		pf.HostPortMax = pf.HostPortMin
+	}
+
+	if pf.HostPortMin <= 0 || pf.HostPortMax > 65535 {
+		errs = append(errs, fmt.Errorf("port_forwards %d: host_port_min and host_port_max must be valid port numbers", index))
+	}
+
+	if pf.HostPortMin > pf.HostPortMax {
+		errs = append(errs, fmt.Errorf("port_forwards %d: host_port_min must be less than host_port_max", index))
+	}
+
+	return errs
+}
+
+// generatedDataKey returns the name under which the host port is exposed to
+// provisioners.
+func (pf *QemuPortForward) generatedDataKey() string {
+	return fmt.Sprintf("HostPort%s%d", strings.ToUpper(pf.Protocol), pf.GuestPort)
+}
+
+// networkInterfaces returns the network interfaces to attach to the VM.
+//
+// If no `network_interface` was configured, this is the single interface
+// described by `net_device` and the `net_*` backend options.
+func (c *Config) networkInterfaces() []QemuNetworkInterface {
+	if len(c.NetworkInterfaces) > 0 {
+		nics := make([]QemuNetworkInterface, len(c.NetworkInterfaces))
+		for i, nic := range c.NetworkInterfaces {
+			nic.netdevID = fmt.Sprintf("net%d", i)
+			nic.deviceID = fmt.Sprintf("nic%d", i)
+			if c.Reproducible && nic.MACAddress == "" {
+				nic.MACAddress = reproducibleMACAddress(i)
+			}
+			nics[i] = nic
+		}
+		return nics
+	}
+
+	nic := QemuNetworkInterface{
+		Model:              c.NetDevice,
+		Backend:            c.NetBackend,
+		Bridge:             c.NetBridge,
+		TapDevice:          c.NetTapDevice,
+		SocketMode:         c.NetSocketMode,
+		SocketAddress:      c.NetSocketAddress,
+		SocketLocalAddress: c.NetSocketLocalAddress,
+		Communicator:       true,
+		netdevID:           "user.0",
+	}
+	if nic.Backend == "" {
+		nic.Backend = "user"
+		if c.NetBridge != "" {
+			nic.Backend = "bridge"
+		}
+	}
+	if c.Reproducible {
+		nic.MACAddress = reproducibleMACAddress(0)
+	}
+
+	return []QemuNetworkInterface{nic}
+}
+
+// communicatorInterface returns the network interface the communicator
+// connects through.
+func (c *Config) communicatorInterface() QemuNetworkInterface {
+	nics := c.networkInterfaces()
+	for _, nic := range nics {
+		if nic.Communicator {
+			return nic
+		}
+	}
+	return nics[0]
+}
+
+// portForwardInterface returns the user-mode network interface the
+// `port_forwards` are set on: the communicator interface if possible, or
+// the first user-mode interface otherwise.
+func (c *Config) portForwardInterface() (QemuNetworkInterface, bool) {
+	if commNIC := c.communicatorInterface(); commNIC.Backend == "user" {
+		return commNIC, true
+	}
+	for _, nic := range c.networkInterfaces() {
+		if nic.Backend == "user" {
+			return nic, true
+		}
+	}
+	return QemuNetworkInterface{}, false
+}
+
+func (c *Config) prepareNetworkInterfaces() []error {
+	var errs []error
+
+	forwardedPorts := map[string]int{}
+	for i := range c.PortForwards {
+		pf := &c.PortForwards[i]
+		errs = append(errs, pf.Prepare(i, &c.CommConfig)...)
+
+		key := pf.generatedDataKey()
+		if j, ok := forwardedPorts[key]; ok {
+			errs = append(errs, fmt.Errorf("port_forwards %d: %s port %d is already forwarded by port_forwards %d", i, pf.Protocol, pf.GuestPort, j))
+			continue
+		}
+		forwardedPorts[key] = i
+	}
+
+	if len(c.NetworkInterfaces) == 0 {
+		errs = append(errs, c.prepareNetBackend()...)
+		if len(c.PortForwards) > 0 && c.communicatorInterface().Backend != "user" {
+			errs = append(errs, fmt.Errorf("port_forwards cannot be used with the %s network backend, they require user-mode networking", c.communicatorInterface().Backend))
+		}
+		return append(errs, c.checkHostNetworkInterfaces()...)
+	}
+
+	if c.NetBridge != "" {
+		errs = append(errs, fmt.Errorf("net_bridge cannot be used together with network_interface, use a network_interface with the 'bridge' backend instead"))
+	}
+	if c.NetBackend != "" || c.NetTapDevice != "" || c.NetSocketAddress != "" {
+		errs = append(errs, fmt.Errorf("net_backend, net_tap_device and net_socket_* cannot be used together with network_interface, set the backend of each network_interface instead"))
+	}
+
+	commNICs := 0
+	for i := range c.NetworkInterfaces {
+		errs = append(errs, c.NetworkInterfaces[i].Prepare(i, c.NetDevice)...)
+		if c.NetworkInterfaces[i].Communicator {
+			commNICs++
+		}
+	}
+
+	if commNICs > 1 {
+		errs = append(errs, fmt.Errorf("only one network_interface can have communicator set"))
+	}
+
+	commNIC := c.communicatorInterface()
+	if c.CommConfig.usesNetwork() && commNIC.Backend == "none" {
+		errs = append(errs, fmt.Errorf("the communicator network_interface must have a backend other than 'none'"))
+	}
+
+	if _, ok := c.portForwardInterface(); len(c.PortForwards) > 0 && !ok {
+		errs = append(errs, fmt.Errorf("port_forwards require a network_interface with the 'user' backend"))
+	}
+
+	return append(errs, c.checkHostNetworkInterfaces()...)
+}
+
+// prepareNetBackend validates the `net_*` options of the network interface
+// used when no `network_interface` is configured.
+func (c *Config) prepareNetBackend() []error {
+	var errs []error
+
+	if c.NetBackend == "" {
+		c.NetBackend = "user"
+		if c.NetBridge != "" {
+			c.NetBackend = "bridge"
+		}
+	}
+
+	switch c.NetBackend {
+	case "user":
+	case "bridge":
+		if c.NetBridge == "" {
+			errs = append(errs, fmt.Errorf("net_bridge must be set when net_backend is 'bridge'"))
+		}
+	case "tap":
+		if c.NetTapDevice == "" {
+			errs = append(errs, fmt.Errorf("net_tap_device must be set when net_backend is 'tap'"))
+		}
+	case "socket":
+		if c.NetSocketMode == "" {
+			c.NetSocketMode = "mcast"
+		}
+		if !netSocketModes[c.NetSocketMode] {
+			errs = append(errs, fmt.Errorf("unrecognized net_socket_mode %q, only 'mcast' or 'udp' are allowed", c.NetSocketMode))
+		}
+		if c.NetSocketAddress == "" {
+			errs = append(errs, fmt.Errorf("net_socket_address must be set when net_backend is 'socket'"))
+		}
+		if c.NetSocketMode == "udp" && c.NetSocketLocalAddress == "" {
+			errs = append(errs, fmt.Errorf("net_socket_local_address must be set when net_socket_mode is 'udp'"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unrecognized net_backend %q, only 'user', 'bridge', 'tap' or 'socket' are allowed", c.NetBackend))
+	}
+
+	if c.NetBridge != "" && c.NetBackend != "bridge" {
+		errs = append(errs, fmt.Errorf("net_bridge can only be used when net_backend is 'bridge'"))
+	}
+
+	return errs
+}
+
+// checkHostNetworkInterfaces checks that the host interfaces the bridge and
+// tap backends connect to exist and are usable by the current user.
+func (c *Config) checkHostNetworkInterfaces() []error {
+	var errs []error
+	for _, nic := range c.networkInterfaces() {
+		var err error
+		switch nic.Backend {
+		case "bridge":
+			err = checkHostBridge(nic.Bridge)
+		case "tap":
+			err = checkHostTapDevice(nic.TapDevice)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (c *Config) prepareGuestAddressSource() []error {
+	var errs []error
+
+	if c.GuestAddressSource == "" {
+		return nil
+	}
+	if !guestAddressSources[c.GuestAddressSource] {
+		return []error{fmt.Errorf("guest_address_source must be one of arp, dhcp_leases, guest_agent or static, got %q", c.GuestAddressSource)}
+	}
+
+	commNIC := c.communicatorInterface()
+	if commNIC.Backend == "user" {
+		errs = append(errs, fmt.Errorf("guest_address_source cannot be used when the communicator uses user-mode networking"))
+	}
+
+	switch c.GuestAddressSource {
+	case "arp":
+		if commNIC.Backend != "bridge" && commNIC.Backend != "tap" {
+			errs = append(errs, fmt.Errorf("guest_address_source 'arp' requires the communicator to use the 'bridge' or 'tap' backend"))
+		}
+	case "dhcp_leases":
+		if c.DHCPLeasesFile == "" {
+			if commNIC.Bridge == "" {
+				errs = append(errs, fmt.Errorf("dhcp_leases_file must be set when the communicator is not attached to a bridge"))
+			} else {
+				c.DHCPLeasesFile = filepath.Join("/var/lib/libvirt/dnsmasq", commNIC.Bridge+".status")
+			}
+		}
+	case "guest_agent":
+		c.GuestAgentEnable = true
+	case "static":
+		if c.CommConfig.Comm.Host() == "" {
+			errs = append(errs, fmt.Errorf("guest_address_source 'static' requires ssh_host or winrm_host to be set"))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,78 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package qemu
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatQemuNetworkInterface is an auto-generated flat version of QemuNetworkInterface.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatQemuNetworkInterface struct {
+	Model              *string  `mapstructure:"model" required:"false" cty:"model" hcl:"model"`
+	MACAddress         *string  `mapstructure:"mac_address" required:"false" cty:"mac_address" hcl:"mac_address"`
+	Backend            *string  `mapstructure:"backend" required:"false" cty:"backend" hcl:"backend"`
+	Bridge             *string  `mapstructure:"bridge" required:"false" cty:"bridge" hcl:"bridge"`
+	TapDevice          *string  `mapstructure:"tap_device" required:"false" cty:"tap_device" hcl:"tap_device"`
+	SocketMode         *string  `mapstructure:"socket_mode" required:"false" cty:"socket_mode" hcl:"socket_mode"`
+	SocketAddress      *string  `mapstructure:"socket_address" required:"false" cty:"socket_address" hcl:"socket_address"`
+	SocketLocalAddress *string  `mapstructure:"socket_local_address" required:"false" cty:"socket_local_address" hcl:"socket_local_address"`
+	HostForwards       []string `mapstructure:"hostfwd" required:"false" cty:"hostfwd" hcl:"hostfwd"`
+	Communicator       *bool    `mapstructure:"communicator" required:"false" cty:"communicator" hcl:"communicator"`
+}
+
+// FlatMapstructure returns a new FlatQemuNetworkInterface.
+// FlatQemuNetworkInterface is an auto-generated flat version of QemuNetworkInterface.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*QemuNetworkInterface) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatQemuNetworkInterface)
+}
+
+// HCL2Spec returns the hcl spec of a QemuNetworkInterface.
+// This spec is used by HCL to read the fields of QemuNetworkInterface.
+// The decoded values from this spec will then be applied to a FlatQemuNetworkInterface.
+func (*FlatQemuNetworkInterface) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"model":                &hcldec.AttrSpec{Name: "model", Type: cty.String, Required: false},
+		"mac_address":          &hcldec.AttrSpec{Name: "mac_address", Type: cty.String, Required: false},
+		"backend":              &hcldec.AttrSpec{Name: "backend", Type: cty.String, Required: false},
+		"bridge":               &hcldec.AttrSpec{Name: "bridge", Type: cty.String, Required: false},
+		"tap_device":           &hcldec.AttrSpec{Name: "tap_device", Type: cty.String, Required: false},
+		"socket_mode":          &hcldec.AttrSpec{Name: "socket_mode", Type: cty.String, Required: false},
+		"socket_address":       &hcldec.AttrSpec{Name: "socket_address", Type: cty.String, Required: false},
+		"socket_local_address": &hcldec.AttrSpec{Name: "socket_local_address", Type: cty.String, Required: false},
+		"hostfwd":              &hcldec.AttrSpec{Name: "hostfwd", Type: cty.List(cty.String), Required: false},
+		"communicator":         &hcldec.AttrSpec{Name: "communicator", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatQemuPortForward is an auto-generated flat version of QemuPortForward.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatQemuPortForward struct {
+	GuestPort   *int    `mapstructure:"guest_port" required:"true" cty:"guest_port" hcl:"guest_port"`
+	Protocol    *string `mapstructure:"protocol" required:"false" cty:"protocol" hcl:"protocol"`
+	HostPortMin *int    `mapstructure:"host_port_min" required:"false" cty:"host_port_min" hcl:"host_port_min"`
+	HostPortMax *int    `mapstructure:"host_port_max" required:"false" cty:"host_port_max" hcl:"host_port_max"`
+}
+
+// FlatMapstructure returns a new FlatQemuPortForward.
+// FlatQemuPortForward is an auto-generated flat version of QemuPortForward.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*QemuPortForward) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatQemuPortForward)
+}
+
+// HCL2Spec returns the hcl spec of a QemuPortForward.
+// This spec is used by HCL to read the fields of QemuPortForward.
+// The decoded values from this spec will then be applied to a FlatQemuPortForward.
+func (*FlatQemuPortForward) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"guest_port":    &hcldec.AttrSpec{Name: "guest_port", Type: cty.Number, Required: false},
+		"protocol":      &hcldec.AttrSpec{Name: "protocol", Type: cty.String, Required: false},
+		"host_port_min": &hcldec.AttrSpec{Name: "host_port_min", Type: cty.Number, Required: false},
+		"host_port_max": &hcldec.AttrSpec{Name: "host_port_max", Type: cty.Number, Required: false},
+	}
+	return s
+}
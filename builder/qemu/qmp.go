@@ -136,3 +136,25 @@ func getNetDevices(qmpMonitor *qmp.SocketMonitor) ([]netDevice, error) {
 	}
 	return devices, nil
 }
+
+type queryCPUsFastResponse struct {
+	Return []queryCPUsFastReturn `json:"return"`
+}
+
+type queryCPUsFastReturn struct {
+	CPUIndex int `json:"cpu-index"`
+	ThreadID int `json:"thread-id"`
+}
+
+// qmpQueryCPUsFast returns the vCPUs of the VM with their host thread.
+func qmpQueryCPUsFast(qmpMonitor *qmp.SocketMonitor) ([]queryCPUsFastReturn, error) {
+	result, err := qmpMonitor.Run([]byte(`{"execute": "query-cpus-fast"}`))
+	if err != nil {
+		return nil, err
+	}
+	var response queryCPUsFastResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		return nil, err
+	}
+	return response.Return, nil
+}
@@ -0,0 +1,105 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// reproducibleQcow2Options pins the options of the QCOW2 images written by
+// reproducible builds, so they don't depend on the defaults of qemu-img.
+const reproducibleQcow2Options = "compat=1.1,cluster_size=65536,lazy_refcounts=off,refcount_bits=16"
+
+// reproducibleDiskInterfaces are the disk interfaces disks can be given a
+// serial number on.
+var reproducibleDiskInterfaces = map[string]bool{
+	"ide":         true,
+	"virtio":      true,
+	"virtio-scsi": true,
+}
+
+// prepareReproducible checks the reproducible configuration and defaults the
+// source date from the SOURCE_DATE_EPOCH environment variable.
+func (c *Config) prepareReproducible() []error {
+	if !c.Reproducible {
+		if c.SourceDateEpoch != nil {
+			return []error{errors.New("source_date_epoch requires reproducible")}
+		}
+		return nil
+	}
+
+	var errs []error
+	if c.SourceDateEpoch == nil {
+		if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+			sourceDateEpoch, err := strconv.ParseInt(epoch, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %s", epoch, err))
+			}
+			c.SourceDateEpoch = &sourceDateEpoch
+		} else {
+			errs = append(errs, errors.New("reproducible requires source_date_epoch or the SOURCE_DATE_EPOCH environment variable"))
+			c.SourceDateEpoch = new(int64)
+		}
+	}
+	if *c.SourceDateEpoch < 0 {
+		errs = append(errs, errors.New("source_date_epoch must be positive"))
+	}
+	if !reproducibleDiskInterfaces[c.DiskInterface] {
+		errs = append(errs, fmt.Errorf("reproducible requires disk_interface ide, virtio or virtio-scsi, not %s", c.DiskInterface))
+	}
+	if c.UseBackingFile {
+		errs = append(errs, errors.New("reproducible can't be used with use_backing_file, the disks are rewritten once the VM is shut down"))
+	}
+	return errs
+}
+
+// sourceDate returns the date reproducible builds pretend to run at.
+func (c *Config) sourceDate() time.Time {
+	return time.Unix(*c.SourceDateEpoch, 0).UTC()
+}
+
+// reproducibleMACAddress returns the MAC address of the ith interface of a
+// reproducible build, in the range QEMU picks its defaults in.
+func reproducibleMACAddress(i int) string {
+	return fmt.Sprintf("52:54:00:12:34:%02x", 0x56+i)
+}
+
+// reproducibleDiskSerial returns the serial number of the ith disk of a
+// reproducible build.
+func reproducibleDiskSerial(i int) string {
+	return fmt.Sprintf("packer-disk%d", i)
+}
+
+// normalizeEFIVariableTimestamps sets the timestamps of the authenticated
+// variables of the VARS file at path, including the deleted ones, to t.
+// VARS files without authenticated variables have no timestamps.
+func normalizeEFIVariableTimestamps(path string, t time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	store, err := openEFIVariableStore(data)
+	if errors.Is(err, errEFINotAuthenticated) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	variables, _, err := store.variables()
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	for _, v := range variables {
+		if v.Attributes&efiVariableTimeBasedAuthenticatedWriteAccess != 0 {
+			putEFITime(data[v.offset+16:v.offset+32], t)
+		}
+	}
+
+	return os.WriteFile(path, data, 0660)
+}
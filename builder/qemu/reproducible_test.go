@@ -0,0 +1,84 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNormalizeEFIVariableTimestamps(t *testing.T) {
+	vars := filepath.Join(t.TempDir(), "efivars.fd")
+	writeTestEFIVars(t, vars)
+
+	data, err := os.ReadFile(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := openEFIVariableStore(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const authenticated = efiVariableNonVolatile | efiVariableBootServiceAccess | efiVariableTimeBasedAuthenticatedWriteAccess
+	// The first version of db is deleted by the second one
+	for _, v := range []struct {
+		name       string
+		attributes uint32
+	}{
+		{"db", authenticated},
+		{"db", authenticated},
+		{"SecureBootEnable", efiVariableNonVolatile | efiVariableBootServiceAccess},
+	} {
+		if err := store.Set(v.name, efiImageSecurityDatabaseGUID, v.attributes, time.Now(), []byte{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(vars, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Unix(1700000000, 0)
+	if err := normalizeEFIVariableTimestamps(vars, date); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	data, err = os.ReadFile(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err = openEFIVariableStore(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables, _, err := store.variables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]byte, 16)
+	putEFITime(expected, date)
+	for _, v := range variables {
+		timestamp := data[v.offset+16 : v.offset+32]
+		if v.Attributes&efiVariableTimeBasedAuthenticatedWriteAccess == 0 {
+			expected = make([]byte, 16)
+		}
+		if !bytes.Equal(timestamp, expected) {
+			t.Fatalf("bad %s timestamp: %x", v.Name, timestamp)
+		}
+	}
+	if len(variables) != 3 {
+		t.Fatalf("bad variables: %d", len(variables))
+	}
+
+	// Without authenticated variables, there is nothing to normalize
+	data[0x48] ^= 0xff
+	if err := os.WriteFile(vars, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := normalizeEFIVariableTimestamps(vars, date); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type SharedFolder
+
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// sharedFoldersTypeKey and sharedFolderTagsKey are the build variables
+	// of the shared folders.
+	sharedFoldersTypeKey string = "SharedFoldersType"
+	sharedFolderTagsKey  string = "SharedFolderTags"
+
+	// The virtiofs tags are limited to 36 bytes.
+	maxSharedFolderTagLength = 36
+)
+
+var sharedFoldersBackends = map[string]bool{
+	"virtiofs": true,
+	"9p":       true,
+}
+
+// virtiofsdPaths are where distributions install virtiofsd, out of PATH.
+var virtiofsdPaths = []string{
+	"/usr/libexec/virtiofsd",
+	"/usr/lib/qemu/virtiofsd",
+	"/usr/lib/virtiofsd",
+}
+
+// SharedFolder is a host directory shared with the guest.
+//
+// Folders are shared with virtiofs when `virtiofsd` is installed on the
+// host, and with 9p otherwise. A `virtiofsd` is started per folder, with
+// the options of the Rust implementation of virtiofsd, and without its
+// sandbox when Packer doesn't run as root. The backend used is exposed to the
+// provisioners as the `SharedFoldersType` build variable, `virtiofs` or
+// `9p`, and the tags as the comma separated `SharedFolderTags` build
+// variable. The guest mounts the folders with their tag, e.g. in a shell
+// provisioner:
+//
+// ```shell
+// mount -t virtiofs toolchain /mnt/toolchain
+// mount -t 9p -o trans=virtio,version=9p2000.L toolchain /mnt/toolchain
+// ```
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	shared_folders {
+//	  host_path = "/opt/toolchains"
+//	  tag       = "toolchain"
+//	  read_only = true
+//	}
+//
+// ```
+type SharedFolder struct {
+	// The host directory to share.
+	HostPath string `mapstructure:"host_path" required:"true"`
+	// The tag the guest mounts the folder with. Defaults to the name of
+	// `host_path`.
+	Tag string `mapstructure:"tag" required:"false"`
+	// Prevent the guest from writing to the folder.
+	ReadOnly bool `mapstructure:"read_only" required:"false"`
+}
+
+func (f *SharedFolder) Prepare(index int) []error {
+	var errs []error
+
+	if f.HostPath == "" {
+		return []error{fmt.Errorf("shared_folders %d: host_path is required", index)}
+	}
+	if info, err := os.Stat(f.HostPath); err != nil {
+		errs = append(errs, fmt.Errorf("shared_folders %d: %s", index, err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("shared_folders %d: %s is not a directory", index, f.HostPath))
+	}
+
+	if f.Tag == "" {
+		f.Tag = filepath.Base(f.HostPath)
+	}
+	if len(f.Tag) > maxSharedFolderTagLength {
+		errs = append(errs, fmt.Errorf("shared_folders %d: the tag %q is longer than %d bytes", index, f.Tag, maxSharedFolderTagLength))
+	}
+
+	return errs
+}
+
+// findVirtiofsd returns the path of virtiofsd.
+func findVirtiofsd() (string, error) {
+	path, err := exec.LookPath("virtiofsd")
+	if err == nil {
+		return path, nil
+	}
+	for _, path := range virtiofsdPaths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", err
+}
+
+// prepareSharedFolders checks the shared folders, and picks their backend
+// when it's not set.
+func (c *Config) prepareSharedFolders() ([]string, []error) {
+	if len(c.SharedFolders) == 0 {
+		if c.SharedFoldersBackend != "" {
+			return nil, []error{errors.New("shared_folders_backend requires shared_folders")}
+		}
+		return nil, nil
+	}
+
+	var warnings []string
+	var errs []error
+
+	tags := make(map[string]bool)
+	for i := range c.SharedFolders {
+		errs = append(errs, c.SharedFolders[i].Prepare(i)...)
+		if tag := c.SharedFolders[i].Tag; tags[tag] {
+			errs = append(errs, fmt.Errorf("shared_folders %d: the tag %q is already used", i, tag))
+		} else {
+			tags[tag] = true
+		}
+	}
+
+	switch c.SharedFoldersBackend {
+	case "":
+		c.SharedFoldersBackend = "9p"
+		if runtime.GOOS == "linux" {
+			if _, err := findVirtiofsd(); err == nil {
+				c.SharedFoldersBackend = "virtiofs"
+			}
+		}
+		if c.SharedFoldersBackend == "9p" {
+			warnings = append(warnings, "virtiofsd was not found, the shared_folders are shared with 9p, "+
+				"which is slower and requires the 9p modules in the guest.")
+		}
+	case "virtiofs":
+		if runtime.GOOS != "linux" {
+			errs = append(errs, errors.New("the virtiofs shared_folders_backend is only supported in Linux based OSes"))
+		} else if _, err := findVirtiofsd(); err != nil {
+			errs = append(errs, fmt.Errorf("the virtiofs shared_folders_backend requires virtiofsd: %s", err))
+		}
+	default:
+		if !sharedFoldersBackends[c.SharedFoldersBackend] {
+			errs = append(errs, fmt.Errorf("unrecognized shared_folders_backend %q, only 'virtiofs' or '9p' are allowed", c.SharedFoldersBackend))
+		}
+	}
+
+	return warnings, errs
+}
@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package qemu
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatSharedFolder is an auto-generated flat version of SharedFolder.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSharedFolder struct {
+	HostPath *string `mapstructure:"host_path" required:"true" cty:"host_path" hcl:"host_path"`
+	Tag      *string `mapstructure:"tag" required:"false" cty:"tag" hcl:"tag"`
+	ReadOnly *bool   `mapstructure:"read_only" required:"false" cty:"read_only" hcl:"read_only"`
+}
+
+// FlatMapstructure returns a new FlatSharedFolder.
+// FlatSharedFolder is an auto-generated flat version of SharedFolder.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SharedFolder) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSharedFolder)
+}
+
+// HCL2Spec returns the hcl spec of a SharedFolder.
+// This spec is used by HCL to read the fields of SharedFolder.
+// The decoded values from this spec will then be applied to a FlatSharedFolder.
+func (*FlatSharedFolder) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"host_path": &hcldec.AttrSpec{Name: "host_path", Type: cty.String, Required: false},
+		"tag":       &hcldec.AttrSpec{Name: "tag", Type: cty.String, Required: false},
+		"read_only": &hcldec.AttrSpec{Name: "read_only", Type: cty.Bool, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// sidecarsKey is the state key of the sidecars running alongside the VM.
+const sidecarsKey string = "qemu_sidecars"
+
+// sidecar is a process serving the VM on a unix socket, like swtpm or
+// virtiofsd, which must run as long as the VM does.
+type sidecar struct {
+	name    string
+	cmd     *exec.Cmd
+	logPath string
+
+	exited   chan struct{}
+	waitErr  error
+	stopping atomic.Bool
+}
+
+// startSidecar starts the program with its output in the log file.
+func startSidecar(name, path string, args []string, logFile *os.File) (*sidecar, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	log.Printf("Executing %s: %+v", name, args)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	s := &sidecar{
+		name:    name,
+		cmd:     cmd,
+		logPath: logFile.Name(),
+		exited:  make(chan struct{}),
+	}
+	go func() {
+		s.waitErr = cmd.Wait()
+		close(s.exited)
+	}()
+	return s, nil
+}
+
+// waitForSocket waits for the process to create the socket, so QEMU doesn't
+// start before it. The socket isn't dialed, virtiofsd serves a single client
+// and exits when it disconnects.
+func (s *sidecar) waitForSocket(ctx context.Context, sockPath string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		if info, err := os.Stat(sockPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+
+		select {
+		case <-s.exited:
+			return fmt.Errorf("%s exited", s.name)
+		case <-deadline:
+			return fmt.Errorf("timeout waiting for the socket %s", sockPath)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// monitor aborts the build when the process exits before being stopped.
+func (s *sidecar) monitor(ui packersdk.Ui, abort context.CancelCauseFunc) {
+	go func() {
+		<-s.exited
+		if s.stopping.Load() {
+			return
+		}
+		err := fmt.Errorf("%s exited unexpectedly: %v%s", s.name, s.waitErr, s.logTail())
+		ui.Error(err.Error())
+		if abort != nil {
+			abort(err)
+		}
+	}()
+}
+
+// registerSidecar adds the sidecar to those expected to exit with the VM.
+func registerSidecar(state multistep.StateBag, s *sidecar) {
+	sidecars, _ := state.Get(sidecarsKey).([]*sidecar)
+	state.Put(sidecarsKey, append(sidecars, s))
+}
+
+// expectSidecarExits stops monitoring the sidecars before the VM shuts
+// down, as they exit when QEMU disconnects from them.
+func expectSidecarExits(state multistep.StateBag) {
+	sidecars, _ := state.Get(sidecarsKey).([]*sidecar)
+	for _, s := range sidecars {
+		s.stopping.Store(true)
+	}
+}
+
+// stop kills the process if it's still running.
+func (s *sidecar) stop() {
+	s.stopping.Store(true)
+	select {
+	case <-s.exited:
+	default:
+		log.Printf("killing %s with PID %d", s.name, s.cmd.Process.Pid)
+		if err := s.cmd.Process.Kill(); err != nil {
+			log.Printf("failed to kill %s: %s", s.name, err)
+		}
+		<-s.exited
+	}
+}
+
+// logTail returns the last lines of the log, for error messages.
+func (s *sidecar) logTail() string {
+	content, err := os.ReadFile(s.logPath)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) > 20 {
+		lines = lines[len(lines)-20:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n%s log:\n%s", s.name, strings.Join(lines, "\n"))
+}
+
+// removeSidecarLog logs the content of the log file and removes it.
+func removeSidecarLog(name, logPath string) {
+	if content, err := os.ReadFile(logPath); err == nil && len(content) > 0 {
+		log.Printf("%s log:\n%s", name, content)
+	}
+	os.Remove(logPath)
+}
@@ -0,0 +1,57 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step allocates the guest CID of the vhost-vsock device the SSH
+// communicator connects through when communicator_transport is vsock.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	vsock_cid uint32
+type stepAllocateVsockCID struct {
+	CIDMin int
+	CIDMax int
+
+	l *vsockCIDLock
+}
+
+func (s *stepAllocateVsockCID) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	log.Printf("Looking for available vsock CID between %d and %d", s.CIDMin, s.CIDMax)
+	l, err := allocateVsockCID(ctx, s.CIDMin, s.CIDMax)
+	if err != nil {
+		err := fmt.Errorf("Error finding vsock CID: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.l = l
+	ui.Say(fmt.Sprintf("Found vsock CID for communicator: %d.", l.CID))
+
+	state.Put("vsock_cid", l.CID)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepAllocateVsockCID) Cleanup(state multistep.StateBag) {
+	if s.l != nil {
+		if err := s.l.Close(); err != nil {
+			log.Printf("failed to remove vsock CID lockfile: %v", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/digitalocean/go-qemu/qmp"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step saves the state of the VM, memory included, in an internal
+// snapshot of its disks, so a failed build can be resumed from it.
+//
+// Uses:
+//
+//	qmp_monitor *qmp.SocketMonitor
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	checkpoints []string - The checkpoints saved by the build.
+type stepCheckpoint struct {
+	Name string
+	// SSHKeyPath is where the temporary SSH private key is saved, so the
+	// resumed build can connect to the restored guest.
+	SSHKeyPath string
+	Comm       *communicator.Config
+}
+
+func (s *stepCheckpoint) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	qmpMonitor := state.Get("qmp_monitor").(*qmp.SocketMonitor)
+
+	ui.Say(fmt.Sprintf("Saving checkpoint %s...", s.Name))
+	if err := saveVM(qmpMonitor, checkpointTag(s.Name)); err != nil {
+		err := fmt.Errorf("Error saving checkpoint %s: %s", s.Name, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if s.SSHKeyPath != "" {
+		if err := os.WriteFile(s.SSHKeyPath, s.Comm.SSHPrivateKey, 0600); err != nil {
+			err := fmt.Errorf("Error saving the SSH key of checkpoint %s: %s", s.Name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	checkpoints, _ := state.Get("checkpoints").([]string)
+	state.Put("checkpoints", append(checkpoints, s.Name))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckpoint) Cleanup(state multistep.StateBag) {}
+
+// saveVM saves an internal snapshot with the savevm HMP command, which has
+// no QMP equivalent before QEMU 6.0.
+func saveVM(qmpMonitor *qmp.SocketMonitor, tag string) error {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"execute": "human-monitor-command",
+		"arguments": map[string]string{
+			"command-line": "savevm " + tag,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := qmpMonitor.Run(cmd)
+	if err != nil {
+		return err
+	}
+	log.Printf("QMP Command: %s\nResult: %s", cmd, result)
+
+	// HMP commands report their errors in their output.
+	var response struct {
+		Return string `json:"return"`
+	}
+	if err := json.Unmarshal(result, &response); err != nil {
+		return err
+	}
+	if output := strings.TrimSpace(response.Return); output != "" {
+		return fmt.Errorf("%s", output)
+	}
+	return nil
+}
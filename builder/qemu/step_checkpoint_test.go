@@ -0,0 +1,164 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-qemu/qmp"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// fakeQMPMonitor serves a QMP socket answering the HMP commands with the
+// output of hmp, and query-cpus-fast with 3 vCPUs, and returns a monitor
+// connected to it.
+func fakeQMPMonitor(t *testing.T, hmp func(command string) string) *qmp.SocketMonitor {
+	path := filepath.Join(t.TempDir(), "qmp.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprintln(conn, `{"QMP": {"version": {"qemu": {"micro": 0, "minor": 2, "major": 8}}, "capabilities": []}}`)
+		// Like QEMU, commands are read as a JSON stream, not lines.
+		decoder := json.NewDecoder(conn)
+		for {
+			var cmd struct {
+				Execute   string `json:"execute"`
+				Arguments struct {
+					CommandLine string `json:"command-line"`
+				} `json:"arguments"`
+			}
+			if err := decoder.Decode(&cmd); err != nil {
+				return
+			}
+			switch cmd.Execute {
+			case "human-monitor-command":
+				output, _ := json.Marshal(hmp(cmd.Arguments.CommandLine))
+				fmt.Fprintf(conn, `{"return": %s}`+"\n", output)
+			case "query-cpus-fast":
+				fmt.Fprintln(conn, `{"return": [{"cpu-index": 0, "thread-id": 101}, {"cpu-index": 1, "thread-id": 102}, {"cpu-index": 2, "thread-id": 103}]}`)
+			default:
+				fmt.Fprintln(conn, `{"return": {}}`)
+			}
+		}
+	}()
+
+	monitor, err := qmp.NewSocketMonitor("unix", path, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := monitor.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { monitor.Disconnect() })
+	return monitor
+}
+
+func TestStepCheckpoint(t *testing.T) {
+	var commands []string
+	state := testState(t)
+	state.Put("qmp_monitor", fakeQMPMonitor(t, func(command string) string {
+		commands = append(commands, command)
+		return ""
+	}))
+
+	keyPath := filepath.Join(t.TempDir(), "packer-foo.checkpoint-key")
+	comm := &communicator.Config{SSH: communicator.SSH{SSHPrivateKey: []byte("private key")}}
+	for _, name := range checkpointNames {
+		step := &stepCheckpoint{Name: name, SSHKeyPath: keyPath, Comm: comm}
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+		}
+	}
+
+	if len(commands) != 2 || commands[0] != "savevm packer-boot_command" || commands[1] != "savevm packer-provision" {
+		t.Fatalf("bad commands: %#v", commands)
+	}
+	checkpoints := state.Get("checkpoints").([]string)
+	if len(checkpoints) != 2 || checkpoints[0] != "boot_command" || checkpoints[1] != "provision" {
+		t.Fatalf("bad checkpoints: %#v", checkpoints)
+	}
+	if key, err := os.ReadFile(keyPath); err != nil || string(key) != "private key" {
+		t.Fatalf("the SSH key should be saved: %q, %v", key, err)
+	}
+}
+
+func TestStepCheckpoint_Error(t *testing.T) {
+	state := testState(t)
+	state.Put("qmp_monitor", fakeQMPMonitor(t, func(command string) string {
+		return "Error: Device 'pflash1' is writable but does not support snapshots\r\n"
+	}))
+
+	step := &stepCheckpoint{Name: "boot_command"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if _, ok := state.GetOk("checkpoints"); ok {
+		t.Fatal("the checkpoint should not be recorded")
+	}
+}
+
+func TestStepDeleteCheckpoints(t *testing.T) {
+	d := new(DriverMock)
+	state := copyTestState(t, d)
+	state.Put("qemu_disk_paths", []string{"out/disk", "out/disk-1"})
+
+	keyPath := filepath.Join(t.TempDir(), "packer-foo.checkpoint-key")
+	if err := os.WriteFile(keyPath, []byte("private key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	step := &stepDeleteCheckpoints{
+		ResumeFrom:      "boot_command",
+		CheckpointAfter: []string{"provision"},
+		SSHKeyPath:      keyPath,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	expected := []string{
+		"snapshot", "-d", "packer-provision", "out/disk",
+		"snapshot", "-d", "packer-boot_command", "out/disk",
+		"snapshot", "-d", "packer-provision", "out/disk-1",
+		"snapshot", "-d", "packer-boot_command", "out/disk-1",
+	}
+	if fmt.Sprint(d.QemuImgCalls) != fmt.Sprint(expected) {
+		t.Fatalf("bad qemu-img calls: %#v", d.QemuImgCalls)
+	}
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Fatalf("the SSH key should be deleted: %v", err)
+	}
+
+	// Nothing to delete without checkpoints
+	d = new(DriverMock)
+	state = copyTestState(t, d)
+	state.Put("qemu_disk_paths", []string{"out/disk"})
+	step = &stepDeleteCheckpoints{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if d.QemuImgCalled {
+		t.Fatal("qemu-img should not be called")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step connects to the host side of the guest agent channel.
+//
+// Connecting doesn't require the agent to be running in the guest yet, the
+// commands sent will only be answered once it is.
+//
+// Uses:
+//
+//	config *config
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	guest_agent *guestAgentClient - The guest agent client.
+type stepConfigureGuestAgent struct {
+	client               *guestAgentClient
+	GuestAgentSocketPath string
+}
+
+func (s *stepConfigureGuestAgent) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if !config.GuestAgentEnable {
+		return multistep.ActionContinue
+	}
+
+	ui.Say(fmt.Sprintf("Guest agent socket at: %s", s.GuestAgentSocketPath))
+
+	var err error
+	s.client, err = newGuestAgentClient(s.GuestAgentSocketPath, 5*time.Second)
+	if err != nil {
+		err := fmt.Errorf("Error opening guest agent socket: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	log.Printf("Guest agent socket open SUCCESS")
+
+	// make the guest agent available to other steps.
+	state.Put("guest_agent", s.client)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConfigureGuestAgent) Cleanup(multistep.StateBag) {
+	if s.client != nil {
+		if err := s.client.Close(); err != nil {
+			log.Printf("failed to close the guest agent socket: %v", err)
+		}
+		// Delete file associated with the guest agent socket.
+		if err := os.Remove(s.GuestAgentSocketPath); err != nil {
+			log.Printf("Failed to delete the guest agent socket file: %s", err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step waits for the guest agent to answer, then sets up the qga
+// communicator. It is run by communicator.StepConnect when the communicator
+// is "qga".
+//
+// Uses:
+//
+//	guest_agent *guestAgentClient
+//	ui          packersdk.Ui
+//
+// Produces:
+//
+//	communicator packersdk.Communicator
+type stepConnectGuestAgent struct {
+	Timeout time.Duration
+}
+
+func (s *stepConnectGuestAgent) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	client, ok := state.Get("guest_agent").(*guestAgentClient)
+	if !ok {
+		err := fmt.Errorf("The qga communicator requires the guest agent channel, which is not set up")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	ui.Say("Waiting for the guest agent to become available...")
+	for {
+		err := client.Run("guest-ping", nil, nil)
+		if err == nil {
+			break
+		}
+		log.Printf("[DEBUG] guest agent is not available yet: %s", err)
+
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("Timeout waiting for the guest agent: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	ui.Say("Connected to the guest agent!")
+	state.Put("communicator", newQGACommunicator(client))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConnectGuestAgent) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,88 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step connects to the serial console of the VM and logs in, then sets
+// up the serial communicator. It is run by communicator.StepConnect when the
+// communicator is "serial".
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	communicator packersdk.Communicator
+type stepConnectSerial struct {
+	SocketPath  string
+	Credentials serialCredentials
+	Timeout     time.Duration
+
+	comm *serialCommunicator
+}
+
+func (s *stepConnectSerial) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	ui.Say(fmt.Sprintf("Logging in on the serial console as %s...", s.Credentials.Username))
+	for {
+		comm, err := s.connect(ctx)
+		if err == nil {
+			s.comm = comm
+			break
+		}
+		log.Printf("[DEBUG] could not log in on the serial console: %s", err)
+
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("Timeout waiting for the serial console login: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	ui.Say("Logged in on the serial console!")
+	state.Put("communicator", s.comm)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConnectSerial) connect(ctx context.Context) (*serialCommunicator, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", s.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	comm, err := newSerialCommunicator(ctx, conn, s.Credentials)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return comm, nil
+}
+
+func (s *stepConnectSerial) Cleanup(multistep.StateBag) {
+	if s.comm != nil {
+		if err := s.comm.Close(); err != nil {
+			log.Printf("failed to close the serial console: %v", err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// This step connects the SSH communicator over AF_VSOCK. It is run by
+// communicator.StepConnect instead of its own SSH step when
+// communicator_transport is vsock, and otherwise behaves like it.
+//
+// Uses:
+//
+//	ui        packersdk.Ui
+//	vsock_cid uint32
+//
+// Produces:
+//
+//	communicator        packersdk.Communicator
+//	communicator_config *communicator.Config
+type stepConnectVsock struct {
+	Config    *communicator.Config
+	SSHConfig func(multistep.StateBag) (*gossh.ClientConfig, error)
+}
+
+func (s *stepConnectVsock) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	cid, ok := state.Get("vsock_cid").(uint32)
+	if !ok {
+		err := fmt.Errorf("The vsock transport requires a guest CID, which is not allocated")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if s.Config.SSHTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Config.SSHTimeout)
+		defer cancel()
+	}
+
+	ui.Say(fmt.Sprintf("Waiting for SSH to become available over vsock (CID %d)...", cid))
+	log.Printf("[INFO] Waiting for SSH over vsock, up to timeout: %s", s.Config.SSHTimeout)
+	comm, err := s.waitForSSH(ctx, state, cid)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("Timeout waiting for SSH.")
+		}
+		state.Put("error", err)
+		ui.Error(fmt.Sprintf("Error waiting for SSH: %s", err))
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Connected to SSH!")
+	state.Put("communicator", comm)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConnectVsock) Cleanup(multistep.StateBag) {}
+
+func (s *stepConnectVsock) waitForSSH(ctx context.Context, state multistep.StateBag, cid uint32) (packersdk.Communicator, error) {
+	port := uint32(s.Config.SSHPort)
+	address := vsockAddr{CID: cid, Port: port}.String()
+	connFunc := func() (net.Conn, error) {
+		return dialVsock(cid, port)
+	}
+
+	var tunnels []ssh.TunnelSpec
+	for _, v := range s.Config.SSHLocalTunnels {
+		t, err := helperssh.ParseTunnelArgument(v, ssh.LocalTunnel)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing port forwarding: %s", err)
+		}
+		tunnels = append(tunnels, t)
+	}
+	for _, v := range s.Config.SSHRemoteTunnels {
+		t, err := helperssh.ParseTunnelArgument(v, ssh.RemoteTunnel)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing port forwarding: %s", err)
+		}
+		tunnels = append(tunnels, t)
+	}
+
+	handshakeAttempts := 0
+	first := true
+	for {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+			}
+		}
+		first = false
+
+		// Store the config so provisioners can access it, like
+		// communicator.StepConnectSSH does.
+		state.Put("communicator_config", s.Config)
+
+		sshConfig, err := s.SSHConfig(state)
+		if err != nil {
+			log.Printf("[DEBUG] Error getting SSH config: %s", err)
+			continue
+		}
+
+		nc, err := connFunc()
+		if err != nil {
+			log.Printf("[DEBUG] vsock connection to SSH port failed: %s", err)
+			continue
+		}
+		nc.Close()
+
+		config := &ssh.Config{
+			Connection:             connFunc,
+			SSHConfig:              sshConfig,
+			Pty:                    s.Config.SSHPty,
+			DisableAgentForwarding: s.Config.SSHDisableAgentForwarding,
+			UseSftp:                s.Config.SSHFileTransferMethod == "sftp",
+			KeepAliveInterval:      s.Config.SSHKeepAliveInterval,
+			Timeout:                s.Config.SSHReadWriteTimeout,
+			Tunnels:                tunnels,
+		}
+
+		log.Printf("[INFO] Attempting SSH connection to vsock %s...", address)
+		comm, err := ssh.New(address, config)
+		if err != nil {
+			log.Printf("[DEBUG] SSH handshake err: %s", err)
+
+			// Same as communicator.StepConnectSSH, only authentication
+			// errors count as handshake attempts.
+			if strings.Contains(err.Error(), "authenticate") {
+				err = fmt.Errorf("Packer experienced an authentication error "+
+					"when trying to connect via SSH. This can happen if your "+
+					"username/password are wrong. You may want to double-check"+
+					" your credentials as part of your debugging process. "+
+					"original error: %s",
+					err)
+				handshakeAttempts += 1
+			}
+
+			if s.Config.SSHHandshakeAttempts > 0 &&
+				handshakeAttempts >= s.Config.SSHHandshakeAttempts {
+				return nil, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+			continue
+		}
+
+		return comm, nil
+	}
+}
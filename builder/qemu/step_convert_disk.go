@@ -25,6 +25,13 @@ type stepConvertDisk struct {
 	OutputDir       string
 	SkipCompaction  bool
 	VMName          string
+	// Flatten converts the disk even when compaction is skipped, to merge
+	// it with its backing file.
+	Flatten bool
+	// Reproducible rewrites all the disks, not only the main one, with
+	// pinned image options, so their layout doesn't depend on the order the
+	// guest wrote in.
+	Reproducible bool
 
 	QemuImgArgs QemuImgArgs
 }
@@ -33,12 +40,32 @@ func (s *stepConvertDisk) Run(ctx context.Context, state multistep.StateBag) mul
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	diskName := s.VMName
-
-	if s.SkipCompaction && !s.DiskCompression {
+	if s.SkipCompaction && !s.DiskCompression && !s.Flatten && !s.Reproducible {
 		return multistep.ActionContinue
 	}
 
+	diskNames := []string{s.VMName}
+	if s.Reproducible {
+		diskNames = nil
+		for _, diskPath := range state.Get("qemu_disk_paths").([]string) {
+			diskNames = append(diskNames, filepath.Base(diskPath))
+		}
+	}
+
+	ui.Say("Converting hard drive...")
+	for _, diskName := range diskNames {
+		if err := s.convertDisk(ctx, driver, ui, diskName); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+// convertDisk converts the disk of the output directory in place.
+func (s *stepConvertDisk) convertDisk(ctx context.Context, driver Driver, ui packersdk.Ui, diskName string) error {
 	name := diskName + ".convert"
 
 	sourcePath := filepath.Join(s.OutputDir, diskName)
@@ -46,7 +73,6 @@ func (s *stepConvertDisk) Run(ctx context.Context, state multistep.StateBag) mul
 
 	command := s.buildConvertCommand(sourcePath, targetPath)
 
-	ui.Say("Converting hard drive...")
 	// Retry the conversion a few times in case it takes the qemu process a
 	// moment to release the lock
 	err := retry.Config{
@@ -66,26 +92,17 @@ func (s *stepConvertDisk) Run(ctx context.Context, state multistep.StateBag) mul
 	if err != nil {
 		switch err.(type) {
 		case *retry.RetryExhaustedError:
-			err = fmt.Errorf("Exhausted retries for getting file lock: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
+			return fmt.Errorf("Exhausted retries for getting file lock: %s", err)
 		default:
-			err := fmt.Errorf("Error converting hard drive: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
+			return fmt.Errorf("Error converting hard drive: %s", err)
 		}
 	}
 
 	if err := os.Rename(targetPath, sourcePath); err != nil {
-		err := fmt.Errorf("Error moving converted hard drive: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+		return fmt.Errorf("Error moving converted hard drive: %s", err)
 	}
 
-	return multistep.ActionContinue
+	return nil
 }
 
 func (s *stepConvertDisk) buildConvertCommand(sourcePath, targetPath string) []string {
@@ -95,6 +112,10 @@ func (s *stepConvertDisk) buildConvertCommand(sourcePath, targetPath string) []s
 		command = append(command, "-c")
 	}
 
+	if s.Reproducible && s.Format == "qcow2" {
+		command = append(command, "-o", reproducibleQcow2Options)
+	}
+
 	// Add user-provided convert args
 	command = append(command, s.QemuImgArgs.Convert...)
 
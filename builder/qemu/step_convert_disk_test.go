@@ -4,9 +4,13 @@
 package qemu
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -44,6 +48,14 @@ func Test_buildConvertCommand(t *testing.T) {
 			[]string{"convert", "-c", "-o", "preallocation=full", "-O", "qcow2", "source.qcow", "target.qcow2"},
 			"Basic, happy path, with compression, one set of extra args",
 		},
+		{
+			&stepConvertDisk{
+				Format:       "qcow2",
+				Reproducible: true,
+			},
+			[]string{"convert", "-o", reproducibleQcow2Options, "-O", "qcow2", "source.qcow", "target.qcow2"},
+			"Pin the image options of reproducible builds",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -53,3 +65,64 @@ func Test_buildConvertCommand(t *testing.T) {
 			fmt.Sprintf("%s. Expected %#v", tc.Reason, tc.Expected))
 	}
 }
+
+func Test_StepConvertDiskFlatten(t *testing.T) {
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputDir, "target.convert"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	step := &stepConvertDisk{
+		Format:         "qcow2",
+		OutputDir:      outputDir,
+		SkipCompaction: true,
+		VMName:         "target",
+		Flatten:        true,
+	}
+	d := new(DriverMock)
+	state := copyTestState(t, d)
+	if action := step.Run(context.TODO(), state); action != multistep.ActionContinue {
+		t.Fatalf("Should have gotten an ActionContinue: %v", state.Get("error"))
+	}
+	assert.True(t, d.QemuImgCalled, "An overlay should be flattened even when compaction is skipped")
+
+	step.Flatten = false
+	d = new(DriverMock)
+	state = copyTestState(t, d)
+	if action := step.Run(context.TODO(), state); action != multistep.ActionContinue {
+		t.Fatalf("Should have gotten an ActionContinue")
+	}
+	assert.False(t, d.QemuImgCalled, "The conversion should be skipped with skip_compaction")
+}
+
+func Test_StepConvertDiskReproducible(t *testing.T) {
+	outputDir := t.TempDir()
+	for _, name := range []string{"target.convert", "target-1.convert"} {
+		if err := os.WriteFile(filepath.Join(outputDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step := &stepConvertDisk{
+		Format:         "qcow2",
+		OutputDir:      outputDir,
+		SkipCompaction: true,
+		VMName:         "target",
+		Reproducible:   true,
+	}
+	d := new(DriverMock)
+	state := copyTestState(t, d)
+	state.Put("qemu_disk_paths", []string{
+		filepath.Join(outputDir, "target"),
+		filepath.Join(outputDir, "target-1"),
+	})
+	if action := step.Run(context.TODO(), state); action != multistep.ActionContinue {
+		t.Fatalf("Should have gotten an ActionContinue: %v", state.Get("error"))
+	}
+
+	expected := []string{
+		"convert", "-o", reproducibleQcow2Options, "-O", "qcow2", filepath.Join(outputDir, "target"), filepath.Join(outputDir, "target.convert"),
+		"convert", "-o", reproducibleQcow2Options, "-O", "qcow2", filepath.Join(outputDir, "target-1"), filepath.Join(outputDir, "target-1.convert"),
+	}
+	assert.Equal(t, expected, d.QemuImgCalls, "All the disks should be rewritten")
+}
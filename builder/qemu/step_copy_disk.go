@@ -20,6 +20,10 @@ type stepCopyDisk struct {
 	OutputDir      string
 	UseBackingFile bool
 	VMName         string
+	// BaseImageCacheDir is the cache of converted source images. When set,
+	// the disk is an overlay on the cached source image.
+	BaseImageCacheDir string
+	ISOChecksum       string
 
 	QemuImgArgs QemuImgArgs
 }
@@ -37,6 +41,10 @@ func (s *stepCopyDisk) Run(ctx context.Context, state multistep.StateBag) multis
 		return multistep.ActionContinue
 	}
 
+	if s.BaseImageCacheDir != "" {
+		return s.createOverlay(isoPath, path, state)
+	}
+
 	// In some cases, the file formats provided are equivalent by comparing the
 	// file extensions. Skip the conversion step
 	// This also serves as a workaround for a QEMU bug: https://bugs.launchpad.net/qemu/+bug/1776920
@@ -66,6 +74,38 @@ func (s *stepCopyDisk) Run(ctx context.Context, state multistep.StateBag) multis
 	return multistep.ActionContinue
 }
 
+// createOverlay creates the disk as an overlay on the source image converted
+// in the base image cache.
+func (s *stepCopyDisk) createOverlay(isoPath, path string, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	key, err := baseImageCacheKey(isoPath, s.ISOChecksum, s.Format, s.QemuImgArgs.Convert)
+	if err == nil {
+		var cached bool
+		var basePath string
+		basePath, cached, err = cachedBaseImage(s.BaseImageCacheDir, key, s.Format, func(targetPath string) error {
+			ui.Say("Converting source image into the base image cache...")
+			return driver.QemuImg(s.buildConvertCommand(isoPath, targetPath)...)
+		})
+		if err == nil {
+			if cached {
+				ui.Say(fmt.Sprintf("Using cached base image %s", basePath))
+			}
+			ui.Say("Creating hard drive overlay...")
+			err = driver.QemuImg("create", "-f", s.Format, "-b", basePath, "-F", s.Format, path)
+		}
+	}
+	if err != nil {
+		err := fmt.Errorf("Error creating hard drive from the base image cache: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
 func (s *stepCopyDisk) buildConvertCommand(sourcePath, targetPath string) []string {
 	command := []string{"convert"}
 
@@ -5,6 +5,8 @@ package qemu
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -123,3 +125,38 @@ func Test_StepQemuImgCalledWithExtraArgs(t *testing.T) {
 			"example_source.qcow2", "output.qcow2"},
 		"should have added user extra args")
 }
+
+func Test_StepCopyBaseImageCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	key, err := baseImageCacheKey("example_source.qcow2", "sha256:abcd", "qcow2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basePath := filepath.Join(cacheDir, key+".qcow2")
+	if err := os.WriteFile(basePath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	step := &stepCopyDisk{
+		DiskImage:         true,
+		Format:            "qcow2",
+		VMName:            "output.qcow2",
+		BaseImageCacheDir: cacheDir,
+		ISOChecksum:       "sha256:abcd",
+	}
+
+	d := new(DriverMock)
+	state := copyTestState(t, d)
+	action := step.Run(context.TODO(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("Should have gotten an ActionContinue")
+	}
+	if d.CopyCalled {
+		t.Fatalf("Should not have copied the cached base image")
+	}
+	assert.Equal(
+		t,
+		[]string{"create", "-f", "qcow2", "-b", basePath, "-F", "qcow2", "output.qcow2"},
+		d.QemuImgCalls,
+		"should have created an overlay on the cached base image")
+}
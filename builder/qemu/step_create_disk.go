@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -24,6 +25,10 @@ type stepCreateDisk struct {
 	UseBackingFile     bool
 	VMName             string
 	QemuImgArgs        QemuImgArgs
+	// Resume reuses the disks of the build being resumed.
+	Resume bool
+	// Reproducible pins the options of the QCOW2 disks.
+	Reproducible bool
 }
 
 func (s *stepCreateDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -46,6 +51,19 @@ func (s *stepCreateDisk) Run(ctx context.Context, state multistep.StateBag) mult
 		diskSizes = append(diskSizes, diskSize)
 	}
 
+	if s.Resume {
+		for _, diskFullPath := range diskFullPaths {
+			if _, err := os.Stat(diskFullPath); err != nil {
+				err := fmt.Errorf("Error resuming with the existing disks: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+		state.Put("qemu_disk_paths", diskFullPaths)
+		return multistep.ActionContinue
+	}
+
 	// Create all required disks
 	for i, diskFullPath := range diskFullPaths {
 		if s.DiskImage && !s.UseBackingFile && i == 0 {
@@ -80,6 +98,10 @@ func (s *stepCreateDisk) buildCreateCommand(path string, size string, i int, sta
 		command = append(command, "-b", isoPath, "-F", "qcow2")
 	}
 
+	if s.Reproducible && s.Format == "qcow2" {
+		command = append(command, "-o", reproducibleQcow2Options)
+	}
+
 	// add user-provided convert args
 	command = append(command, s.QemuImgArgs.Create...)
 
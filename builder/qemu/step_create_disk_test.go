@@ -6,6 +6,8 @@ package qemu
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -177,3 +179,36 @@ func Test_StepCreateCalled(t *testing.T) {
 			fmt.Sprintf("%s. Expected %#v", tc.Reason, tc.Expected))
 	}
 }
+
+func Test_StepCreateResume(t *testing.T) {
+	outputDir := t.TempDir()
+	for _, name := range []string{"target", "target-1"} {
+		if err := os.WriteFile(filepath.Join(outputDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := new(DriverMock)
+	state := copyTestState(t, d)
+	step := &stepCreateDisk{
+		Format:             "qcow2",
+		DiskSize:           "1M",
+		OutputDir:          outputDir,
+		VMName:             "target",
+		AdditionalDiskSize: []string{"3M"},
+		Resume:             true,
+	}
+	if action := step.Run(context.TODO(), state); action != multistep.ActionContinue {
+		t.Fatalf("Should have gotten an ActionContinue")
+	}
+	assert.False(t, d.QemuImgCalled, "The disks of the resumed build should be reused")
+	assert.Equal(t, []string{filepath.Join(outputDir, "target"), filepath.Join(outputDir, "target-1")},
+		state.Get("qemu_disk_paths"))
+
+	// The disks of the resumed build must exist
+	step.AdditionalDiskSize = []string{"3M", "8M"}
+	state = copyTestState(t, d)
+	if action := step.Run(context.TODO(), state); action != multistep.ActionHalt {
+		t.Fatalf("Should have gotten an ActionHalt")
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -19,15 +22,32 @@ type stepCreatevTPM struct {
 	enableVTPM bool
 	vtpmType   string
 	isTPM1     bool
+	// stateSource is a TPM state directory swtpm starts from, instead of a
+	// blank TPM.
+	stateSource string
+	// manufacture runs swtpm_setup on the blank TPM to create the EK and
+	// platform certificates, with pcrBanks active.
+	manufacture bool
+	pcrBanks    []string
+	// logLevel is the swtpm log level, swtpm's default when 0.
+	logLevel int
+	// abort cancels the build when swtpm exits before the VM is done with
+	// it.
+	abort context.CancelCauseFunc
+
+	swtpm   *sidecar
+	logPath string
 }
 
 const (
 	qemuVTPM        string = "qemu_vtpm"
-	swtpmProcess    string = "qemu_swtpm_process"
 	swtpmTmpDir     string = "qemu_swtpm_dir"
 	swtpmSocketPath string = "qemu_swtpm_socket_path"
 )
 
+// swtpmStartTimeout is how long swtpm has to create its control socket.
+var swtpmStartTimeout = 10 * time.Second
+
 func (s *stepCreatevTPM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	if !s.enableVTPM {
 		return multistep.ActionContinue
@@ -56,57 +76,142 @@ func (s *stepCreatevTPM) Run(ctx context.Context, state multistep.StateBag) mult
 
 	state.Put(swtpmTmpDir, vtpmDeviceDir)
 
-	sockPath := fmt.Sprintf("%s/vtpm.sock", vtpmDeviceDir)
+	if s.stateSource != "" {
+		ui.Say(fmt.Sprintf("Seeding vTPM state from %s", s.stateSource))
+		if err := copyvTPMState(s.stateSource, vtpmDeviceDir); err != nil {
+			ui.Error(fmt.Sprintf("failed to seed vtpm state: %s", err))
+			return multistep.ActionHalt
+		}
+	}
 
-	state.Put(swtpmSocketPath, sockPath)
+	if s.manufacture {
+		ui.Say("Manufacturing vTPM...")
+		if err := s.runSetup(ctx, vtpmDeviceDir); err != nil {
+			ui.Error(fmt.Sprintf("failed to manufacture vtpm: %s", err))
+			return multistep.ActionHalt
+		}
+	}
+
+	// The log is kept out of the state directory, which may be persisted.
+	logFile, err := os.CreateTemp("", "swtpm-*.log")
 	if err != nil {
-		ui.Error(fmt.Sprintf(
-			"failed to create swtpm communication: %s",
-			err))
+		ui.Error(fmt.Sprintf("failed to create swtpm log file: %s", err))
 		return multistep.ActionHalt
 	}
+	defer logFile.Close()
+	s.logPath = logFile.Name()
+
+	sockPath := fmt.Sprintf("%s/vtpm.sock", vtpmDeviceDir)
 
+	state.Put(swtpmSocketPath, sockPath)
+
+	logArg := fmt.Sprintf("file=%s", s.logPath)
+	if s.logLevel > 0 {
+		logArg = fmt.Sprintf("%s,level=%d", logArg, s.logLevel)
+	}
 	args := []string{
 		"socket",
 		"--tpmstate", fmt.Sprintf("dir=%s", vtpmDeviceDir),
 		"--ctrl", fmt.Sprintf("type=unixio,path=%s", sockPath),
+		"--log", logArg,
 	}
 
 	if !s.isTPM1 {
 		args = append(args, "--tpm2")
 	}
 
-	swtpm := exec.Command(swtpmPath, args...)
-	swtpm.Stdout = os.Stdout
-	swtpm.Stderr = os.Stderr
-
 	state.Put(qemuVTPM, true)
 
-	log.Printf("Executing swtpm: %+v", args)
-	err = swtpm.Start()
+	swtpm, err := startSidecar("swtpm", swtpmPath, args, logFile)
 	if err != nil {
 		ui.Error(fmt.Sprintf(
 			"failed to start swtpm: %s", err))
 		return multistep.ActionHalt
 	}
+	s.swtpm = swtpm
 
-	state.Put(swtpmProcess, swtpm.Process)
+	if err := swtpm.waitForSocket(ctx, sockPath, swtpmStartTimeout); err != nil {
+		ui.Error(fmt.Sprintf("swtpm failed to start: %s%s", err, swtpm.logTail()))
+		return multistep.ActionHalt
+	}
+	log.Printf("swtpm is listening on %s", sockPath)
+
+	// From now on, swtpm exiting is a failure of the build.
+	swtpm.monitor(ui, s.abort)
+	registerSidecar(state, swtpm)
 
 	return multistep.ActionContinue
 }
 
+// runSetup manufactures the TPM in dir with swtpm_setup.
+func (s *stepCreatevTPM) runSetup(ctx context.Context, dir string) error {
+	setupPath, err := exec.LookPath("swtpm_setup")
+	if err != nil {
+		return fmt.Errorf("failed to locate swtpm_setup (%s), this is required to manufacture the vTPM", err)
+	}
+
+	args := []string{
+		"--tpmstate", dir,
+		"--create-ek-cert",
+		"--create-platform-cert",
+		"--lock-nvram",
+		"--not-overwrite",
+	}
+	if !s.isTPM1 {
+		args = append(args, "--tpm2")
+		if len(s.pcrBanks) > 0 {
+			args = append(args, "--pcr-banks", strings.Join(s.pcrBanks, ","))
+		}
+	}
+
+	log.Printf("Executing swtpm_setup: %+v", args)
+	output, err := exec.CommandContext(ctx, setupPath, args...).CombinedOutput()
+	log.Printf("swtpm_setup output: %s", output)
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
 func (s *stepCreatevTPM) Cleanup(state multistep.StateBag) {
-	process, ok := state.GetOk(swtpmProcess)
-	if !ok {
-		return
+	if s.swtpm != nil {
+		s.swtpm.stop()
 	}
 
-	log.Printf("killing swtpm with PID %d", process.(*os.Process).Pid)
-	err := process.(*os.Process).Kill()
+	if s.logPath != "" {
+		removeSidecarLog("swtpm", s.logPath)
+	}
+
+	if tmpDir, ok := state.GetOk(swtpmTmpDir); ok {
+		os.RemoveAll(tmpDir.(string))
+	}
+}
+
+// copyvTPMState copies the state files of swtpm from src to dst. The socket
+// and the lock file of a running swtpm are skipped.
+func copyvTPMState(src, dst string) error {
+	entries, err := os.ReadDir(src)
 	if err != nil {
-		log.Printf("failed to kill swtpm: %s", err)
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || entry.Name() == ".lock" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), content, info.Mode().Perm()); err != nil {
+			return err
+		}
 	}
 
-	tmpDir := state.Get(swtpmTmpDir).(string)
-	os.RemoveAll(tmpDir)
+	return nil
 }
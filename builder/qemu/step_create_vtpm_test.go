@@ -0,0 +1,247 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// TestHelperSwtpm is not a real test, the fake swtpm of useFakeSwtpm runs
+// it to behave like swtpm, depending on FAKE_SWTPM_MODE.
+func TestHelperSwtpm(t *testing.T) {
+	mode := os.Getenv("FAKE_SWTPM_MODE")
+	if mode == "" {
+		return
+	}
+
+	var sockPath, logPath string
+	args := os.Args
+	for i := range args {
+		if i+1 == len(args) {
+			break
+		}
+		switch args[i] {
+		case "--ctrl":
+			sockPath = strings.TrimPrefix(strings.Split(args[i+1], ",")[1], "path=")
+		case "--log":
+			logPath = strings.TrimPrefix(strings.Split(args[i+1], ",")[0], "file=")
+		}
+	}
+
+	if mode == "fail" {
+		fmt.Fprintln(os.Stderr, "swtpm: Could not open TPM state directory")
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(logPath, []byte("swtpm started\n"), 0644); err != nil {
+		os.Exit(2)
+	}
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		os.Exit(2)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	if mode == "crash" {
+		time.Sleep(500 * time.Millisecond)
+		fmt.Fprintln(os.Stderr, "swtpm: TPM self test failed")
+		os.Exit(1)
+	}
+	select {}
+}
+
+// useFakeSwtpm puts fake swtpm and swtpm_setup commands first in the PATH.
+// swtpm_setup writes its arguments to the returned file.
+func useFakeSwtpm(t *testing.T, mode string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("vTPM is not supported on windows")
+	}
+
+	dir := t.TempDir()
+	swtpm := fmt.Sprintf("#!/bin/sh\nexec %q -test.run=^TestHelperSwtpm$ -- \"$@\"\n", os.Args[0])
+	if err := os.WriteFile(filepath.Join(dir, "swtpm"), []byte(swtpm), 0755); err != nil {
+		t.Fatal(err)
+	}
+	setupArgs := filepath.Join(dir, "swtpm_setup.args")
+	setup := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\n", setupArgs)
+	if err := os.WriteFile(filepath.Join(dir, "swtpm_setup"), []byte(setup), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_SWTPM_MODE", mode)
+	return setupArgs
+}
+
+func TestStepCreatevTPM(t *testing.T) {
+	setupArgs := useFakeSwtpm(t, "run")
+
+	var aborted error
+	state := testState(t)
+	step := &stepCreatevTPM{
+		enableVTPM:  true,
+		manufacture: true,
+		pcrBanks:    []string{"sha256", "sha384"},
+		abort:       func(err error) { aborted = err },
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %s", action, state.Get("ui").(*packersdk.BasicUi).Writer)
+	}
+
+	sockPath := state.Get(swtpmSocketPath).(string)
+	if _, err := os.Stat(sockPath); err != nil {
+		t.Fatalf("the control socket should exist: %s", err)
+	}
+
+	args, err := os.ReadFile(setupArgs)
+	if err != nil {
+		t.Fatalf("swtpm_setup should have run: %s", err)
+	}
+	if !strings.Contains(string(args), "--create-ek-cert") || !strings.Contains(string(args), "--pcr-banks sha256,sha384") {
+		t.Fatalf("bad swtpm_setup arguments: %s", args)
+	}
+
+	stateDir := state.Get(swtpmTmpDir).(string)
+	logPath := step.logPath
+	step.Cleanup(state)
+	if aborted != nil {
+		t.Fatalf("stopping swtpm should not abort the build: %s", aborted)
+	}
+	for _, path := range []string{stateDir, logPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed: %v", path, err)
+		}
+	}
+}
+
+func TestStepCreatevTPM_StartFailure(t *testing.T) {
+	useFakeSwtpm(t, "fail")
+
+	state := testState(t)
+	step := &stepCreatevTPM{enableVTPM: true}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	output := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+	if !strings.Contains(output, "Could not open TPM state directory") {
+		t.Fatalf("the error should contain the swtpm log: %s", output)
+	}
+}
+
+func TestStepCreatevTPM_Crash(t *testing.T) {
+	useFakeSwtpm(t, "crash")
+
+	aborted := make(chan error, 1)
+	state := testState(t)
+	step := &stepCreatevTPM{
+		enableVTPM: true,
+		abort:      func(err error) { aborted <- err },
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	select {
+	case err := <-aborted:
+		if !strings.Contains(err.Error(), "swtpm exited unexpectedly") || !strings.Contains(err.Error(), "TPM self test failed") {
+			t.Fatalf("bad error: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the build should be aborted when swtpm exits")
+	}
+}
+
+func TestStepCreatevTPM_ExitAfterShutdown(t *testing.T) {
+	// swtpm exits shortly after starting, like when QEMU shuts down.
+	useFakeSwtpm(t, "crash")
+
+	aborted := make(chan error, 1)
+	state := testState(t)
+	state.Put("driver", &DriverMock{WaitForShutdownState: true})
+	step := &stepCreatevTPM{
+		enableVTPM: true,
+		abort:      func(err error) { aborted <- err },
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	shutdown := &stepShutdown{
+		ShutdownTimeout: time.Minute,
+		Comm:            &communicator.Config{Type: "none"},
+	}
+	if action := shutdown.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	select {
+	case <-step.swtpm.exited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("swtpm should have exited")
+	}
+	select {
+	case err := <-aborted:
+		t.Fatalf("swtpm exiting with the VM should not abort the build: %s", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestStepCreatevTPM_ExitAfterHalt(t *testing.T) {
+	useFakeSwtpm(t, "run")
+
+	ctx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	state := testState(t)
+	step := &stepCreatevTPM{
+		enableVTPM: true,
+		abort:      abort,
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(ctx, state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// A later step fails, and QEMU is killed in the cleanup, swtpm exiting
+	// with it.
+	buildErr := fmt.Errorf("Timeout waiting for SSH.")
+	state.Put("error", buildErr)
+	(&stepRun{}).Cleanup(state)
+	if err := step.swtpm.cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	<-step.swtpm.exited
+
+	time.Sleep(200 * time.Millisecond)
+	if cause := context.Cause(ctx); cause != nil {
+		t.Fatalf("swtpm exiting with the VM should not abort the build: %s", cause)
+	}
+	if err := buildError(ctx, state); err != buildErr {
+		t.Fatalf("the build should fail with the step error, got: %v", err)
+	}
+}
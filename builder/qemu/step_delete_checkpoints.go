@@ -0,0 +1,69 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step deletes the checkpoints from the disks once the build succeeded,
+// so they are not part of the artifact.
+//
+// Uses:
+//
+//	checkpoints     []string
+//	driver          Driver
+//	qemu_disk_paths []string
+//	ui              packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type stepDeleteCheckpoints struct {
+	// ResumeFrom is the checkpoint the build resumed from, which previous
+	// builds saved.
+	ResumeFrom      string
+	CheckpointAfter []string
+	// SSHKeyPath is where the checkpoints saved the temporary SSH key.
+	SSHKeyPath string
+}
+
+func (s *stepDeleteCheckpoints) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	// The checkpoints saved by previous builds are not tracked, all the ones
+	// which may exist are deleted.
+	names := append([]string{}, s.CheckpointAfter...)
+	if s.ResumeFrom != "" && !containsString(names, s.ResumeFrom) {
+		names = append(names, s.ResumeFrom)
+	}
+	if len(names) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Deleting checkpoints...")
+	diskPaths, _ := state.Get("qemu_disk_paths").([]string)
+	for _, path := range diskPaths {
+		for _, name := range names {
+			if err := driver.QemuImg("snapshot", "-d", checkpointTag(name), path); err != nil {
+				log.Printf("Not deleting checkpoint %s of %s: %s", name, path, err)
+			}
+		}
+	}
+	if s.SSHKeyPath != "" {
+		if err := os.Remove(s.SSHKeyPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to delete the checkpoint SSH key: %s", err)
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepDeleteCheckpoints) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,135 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	gonet "net"
+	"strconv"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+const portForwardsStateKey string = "qemu_port_forwards"
+
+// hostPortForward is a port forward for which a host port was allocated.
+type hostPortForward struct {
+	Protocol  string
+	HostPort  int
+	GuestPort int
+}
+
+func (pf hostPortForward) hostfwd() string {
+	return fmt.Sprintf("%s::%d-:%d", pf.Protocol, pf.HostPort, pf.GuestPort)
+}
+
+// This step finds available host ports for the user-defined port forwards.
+//
+// Uses:
+//
+//	config *config
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	qemu_port_forwards []hostPortForward - The allocated port forwards.
+type stepExtraPortForwards struct {
+	PortForwards []QemuPortForward
+
+	listeners []*net.Listener
+}
+
+func (s *stepExtraPortForwards) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+
+	if len(s.PortForwards) == 0 {
+		return multistep.ActionContinue
+	}
+
+	forwards := make([]hostPortForward, 0, len(s.PortForwards))
+	for _, pf := range s.PortForwards {
+		log.Printf("Looking for available port between %d and %d to forward to guest %s port %d",
+			pf.HostPortMin, pf.HostPortMax, pf.Protocol, pf.GuestPort)
+
+		lc := net.ListenRangeConfig{
+			Addr:    config.VNCBindAddress,
+			Min:     pf.HostPortMin,
+			Max:     pf.HostPortMax,
+			Network: "tcp",
+		}
+		var l *net.Listener
+		var err error
+		if pf.Protocol == "udp" {
+			l, err = listenUDPRange(ctx, lc)
+		} else {
+			l, err = lc.Listen(ctx)
+		}
+		if err != nil {
+			err := fmt.Errorf("Error finding port to forward to guest %s port %d: %s", pf.Protocol, pf.GuestPort, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		l.Listener.Close() // free port, but don't unlock lock file
+		s.listeners = append(s.listeners, l)
+
+		ui.Say(fmt.Sprintf("Forwarding host port %d to guest %s port %d.", l.Port, pf.Protocol, pf.GuestPort))
+		forwards = append(forwards, hostPortForward{
+			Protocol:  pf.Protocol,
+			HostPort:  l.Port,
+			GuestPort: pf.GuestPort,
+		})
+		generatedData.Put(pf.generatedDataKey(), l.Port)
+	}
+
+	state.Put(portForwardsStateKey, forwards)
+
+	return multistep.ActionContinue
+}
+
+// listenUDPRange finds a port of the range which is free for UDP. The SDK
+// listener only opens stream sockets, so the ports it locks are probed with
+// a UDP socket until one is free.
+func listenUDPRange(ctx context.Context, lc net.ListenRangeConfig) (*net.Listener, error) {
+	var busy []*net.Listener
+	defer func() {
+		for _, l := range busy {
+			l.Close()
+		}
+	}()
+
+	// The SDK listener picks the ports in [Min, Max), or Min alone.
+	ports := max(lc.Max-lc.Min, 1)
+	for len(busy) < ports {
+		l, err := lc.Listen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		l.Listener.Close()
+
+		conn, err := gonet.ListenPacket("udp", gonet.JoinHostPort(lc.Addr, strconv.Itoa(l.Port)))
+		if err == nil {
+			conn.Close()
+			return l, nil
+		}
+		log.Printf("UDP port %d is not available: %s", l.Port, err)
+		busy = append(busy, l)
+	}
+	return nil, fmt.Errorf("no UDP port available between %d and %d", lc.Min, lc.Max)
+}
+
+func (s *stepExtraPortForwards) Cleanup(state multistep.StateBag) {
+	for _, l := range s.listeners {
+		if err := l.Close(); err != nil {
+			log.Printf("failed to unlock port lockfile: %v", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepExtraPortForwards_UDP(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+
+	// A UDP port which is taken, while the TCP one may be free
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	busyPort := conn.LocalAddr().(*net.UDPAddr).Port
+
+	state := testState(t)
+	state.Put("config", &Config{VNCBindAddress: "127.0.0.1"})
+	step := &stepExtraPortForwards{
+		PortForwards: []QemuPortForward{
+			{GuestPort: 53, Protocol: "udp", HostPortMin: busyPort, HostPortMax: busyPort},
+		},
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("a busy UDP port should not be forwarded, got %#v", action)
+	}
+
+	conn.Close()
+	state = testState(t)
+	state.Put("config", &Config{VNCBindAddress: "127.0.0.1"})
+	step = &stepExtraPortForwards{
+		PortForwards: []QemuPortForward{
+			{GuestPort: 53, Protocol: "udp", HostPortMin: busyPort, HostPortMax: busyPort},
+		},
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	forwards := state.Get(portForwardsStateKey).([]hostPortForward)
+	expected := hostPortForward{Protocol: "udp", HostPort: busyPort, GuestPort: 53}
+	if len(forwards) != 1 || forwards[0] != expected {
+		t.Fatalf("bad forwards: %#v", forwards)
+	}
+}
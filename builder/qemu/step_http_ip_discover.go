@@ -7,6 +7,8 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -22,42 +24,58 @@ func (s *stepHTTPIPDiscover) Run(ctx context.Context, state multistep.StateBag)
 	ui := state.Get("ui").(packersdk.Ui)
 
 	hostIP := ""
+	// With the tap backend, the guest reaches the host through the tap
+	// device itself, unless it is enslaved to a bridge.
+	commNIC := config.communicatorInterface()
+	netBridge := commNIC.Bridge
+	if commNIC.Backend == "tap" {
+		netBridge = commNIC.TapDevice
+		if master := tapBridge(commNIC.TapDevice); master != "" {
+			netBridge = master
+		}
+	}
 
-	if config.NetBridge == "" {
+	if commNIC.Backend == "socket" {
+		// The host is only reachable at the address the user knows the
+		// socket network routes to it.
+		hostIP = specificHTTPAddress(config.HTTPAddress)
+		if hostIP == "" {
+			err := fmt.Errorf("Error getting the HTTP server address: the socket network backend has no host address, " +
+				"set http_bind_address to an address of the host the guest can reach")
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else if netBridge == "" {
 		hostIP = "10.0.2.2"
+		if config.IsolatedNetwork {
+			hostIP = isolatedHTTPAddress
+		}
 	} else {
-		bridgeInterface, err := net.InterfaceByName(config.NetBridge)
+		bridgeInterface, err := net.InterfaceByName(netBridge)
 		if err != nil {
-			err := fmt.Errorf("Error getting the bridge %s interface: %s", config.NetBridge, err)
+			err := fmt.Errorf("Error getting the bridge %s interface: %s", netBridge, err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
 		addrs, err := bridgeInterface.Addrs()
 		if err != nil {
-			err := fmt.Errorf("Error getting the bridge %s interface addresses: %s", config.NetBridge, err)
+			err := fmt.Errorf("Error getting the bridge %s interface addresses: %s", netBridge, err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+		var ips []net.IP
 		for _, addr := range addrs {
-			var ip net.IP
 			switch v := addr.(type) {
 			case *net.IPNet:
-				ip = v.IP
+				ips = append(ips, v.IP)
 			case *net.IPAddr:
-				ip = v.IP
-			}
-			if ip == nil {
-				continue
+				ips = append(ips, v.IP)
 			}
-			ip = ip.To4()
-			if ip == nil {
-				continue
-			}
-			hostIP = ip.String()
-			break
 		}
+		hostIP = selectHTTPIP(ips, config.HTTPIPAddressType)
 		if hostIP == "" {
-			err := fmt.Errorf("Error getting an IPv4 address from the bridge %s: cannot find any IPv4 address", config.NetBridge)
+			err := fmt.Errorf("Error getting an address from the bridge %s: cannot find any %s address",
+				netBridge, httpIPAddressTypeDescription(config.HTTPIPAddressType))
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
@@ -69,3 +87,82 @@ func (s *stepHTTPIPDiscover) Run(ctx context.Context, state multistep.StateBag)
 }
 
 func (s *stepHTTPIPDiscover) Cleanup(state multistep.StateBag) {}
+
+// sysfsNetPath is where Linux exposes the network interfaces.
+var sysfsNetPath = "/sys/class/net"
+
+// tapBridge returns the bridge the tap device is enslaved to, if any.
+func tapBridge(tap string) string {
+	master, err := os.Readlink(filepath.Join(sysfsNetPath, tap, "master"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(master)
+}
+
+// specificHTTPAddress returns the http_bind_address when it's a specific
+// address, the HTTP server listens on all the addresses otherwise.
+func specificHTTPAddress(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+// selectHTTPIP picks the address the guest reaches the host at, among the
+// addresses of the bridge, according to the http_ip_address_type setting.
+//
+// When addressType is unset, an IPv4 address is preferred, then a global
+// IPv6 address, then a link-local IPv6 address.
+func selectHTTPIP(ips []net.IP, addressType string) string {
+	candidates := map[string]string{}
+	for _, ip := range ips {
+		var kind string
+		switch {
+		case ip.To4() != nil:
+			kind = "ipv4"
+		case ip.IsLinkLocalUnicast():
+			kind = "ipv6_link_local"
+		case ip.IsGlobalUnicast():
+			kind = "ipv6_global"
+		default:
+			continue
+		}
+		if _, ok := candidates[kind]; !ok {
+			candidates[kind] = ip.String()
+		}
+	}
+
+	if addressType != "" {
+		return candidates[addressType]
+	}
+	for _, kind := range []string{"ipv4", "ipv6_global", "ipv6_link_local"} {
+		if ip, ok := candidates[kind]; ok {
+			return ip
+		}
+	}
+	return ""
+}
+
+func httpIPAddressTypeDescription(addressType string) string {
+	switch addressType {
+	case "ipv4":
+		return "IPv4"
+	case "ipv6_global":
+		return "global IPv6"
+	case "ipv6_link_local":
+		return "link-local IPv6"
+	}
+	return "IPv4 or IPv6"
+}
+
+// httpIPTemplateValue returns the value of the HTTPIP template variable for
+// the given http_ip, IPv6 addresses are enclosed in brackets so that they can
+// be used in URLs, e.g. `http://{{ .HTTPIP }}:{{ .HTTPPort }}/`.
+func httpIPTemplateValue(httpIP string) string {
+	if ip := net.ParseIP(httpIP); ip != nil && ip.To4() == nil {
+		return "[" + httpIP + "]"
+	}
+	return httpIP
+}
@@ -6,6 +6,9 @@ package qemu
 import (
 	"bytes"
 	"context"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -35,3 +38,106 @@ func TestStepHTTPIPDiscover_Run(t *testing.T) {
 		t.Fatalf("bad: Http ip is %s but was supposed to be %s", httpIp, hostIp)
 	}
 }
+
+func TestStepHTTPIPDiscover_IsolatedNetwork(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", &Config{IsolatedNetwork: true})
+	step := new(stepHTTPIPDiscover)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if httpIp := state.Get("http_ip").(string); httpIp != isolatedHTTPAddress {
+		t.Fatalf("bad: Http ip is %s but was supposed to be %s", httpIp, isolatedHTTPAddress)
+	}
+}
+
+func TestStepHTTPIPDiscover_Socket(t *testing.T) {
+	nics := []QemuNetworkInterface{{Backend: "socket", SocketMode: "mcast", SocketAddress: "230.0.0.1:1234", netdevID: "net0"}}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	config := &Config{NetworkInterfaces: nics}
+	config.HTTPAddress = "0.0.0.0"
+	state.Put("config", config)
+	step := new(stepHTTPIPDiscover)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	state = new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	config = &Config{NetworkInterfaces: nics}
+	config.HTTPAddress = "192.168.100.1"
+	state.Put("config", config)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if httpIp := state.Get("http_ip").(string); httpIp != "192.168.100.1" {
+		t.Fatalf("bad: Http ip is %s but was supposed to be the bind address", httpIp)
+	}
+}
+
+func TestTapBridge(t *testing.T) {
+	sysfs := t.TempDir()
+	path := sysfsNetPath
+	sysfsNetPath = sysfs
+	t.Cleanup(func() { sysfsNetPath = path })
+
+	for _, dir := range []string{"br0", "tap0", "tap1"} {
+		if err := os.Mkdir(filepath.Join(sysfs, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../br0", filepath.Join(sysfs, "tap0", "master")); err != nil {
+		t.Fatal(err)
+	}
+
+	if bridge := tapBridge("tap0"); bridge != "br0" {
+		t.Fatalf("tap0 should be enslaved to br0, got %q", bridge)
+	}
+	if bridge := tapBridge("tap1"); bridge != "" {
+		t.Fatalf("tap1 should not be enslaved, got %q", bridge)
+	}
+}
+
+func TestSelectHTTPIP(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("fe80::1"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("192.168.122.1"),
+	}
+	testcases := []struct {
+		ips         []net.IP
+		addressType string
+		expected    string
+	}{
+		{ips, "", "192.168.122.1"},
+		{ips, "ipv4", "192.168.122.1"},
+		{ips, "ipv6_global", "2001:db8::1"},
+		{ips, "ipv6_link_local", "fe80::1"},
+		{ips[:2], "", "2001:db8::1"},
+		{ips[:1], "", "fe80::1"},
+		{ips[:1], "ipv4", ""},
+	}
+
+	for _, tc := range testcases {
+		if got := selectHTTPIP(tc.ips, tc.addressType); got != tc.expected {
+			t.Errorf("selectHTTPIP(%v, %q) = %q, expected %q", tc.ips, tc.addressType, got, tc.expected)
+		}
+	}
+}
+
+func TestHTTPIPTemplateValue(t *testing.T) {
+	testcases := map[string]string{
+		"10.0.2.2":    "10.0.2.2",
+		"2001:db8::1": "[2001:db8::1]",
+		"fe80::1":     "[fe80::1]",
+	}
+	for httpIP, expected := range testcases {
+		if got := httpIPTemplateValue(httpIP); got != expected {
+			t.Errorf("httpIPTemplateValue(%q) = %q, expected %q", httpIP, got, expected)
+		}
+	}
+}
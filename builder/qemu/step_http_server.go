@@ -0,0 +1,233 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// httpFetchedPathsKey is the name of the build variable listing the paths
+// the guest fetched from the HTTP server.
+const httpFetchedPathsKey string = "HTTPFetchedPaths"
+
+// httpRequestLog records the paths served by the HTTP server, and lets the
+// builder wait for the guest to fetch a given path.
+type httpRequestLog struct {
+	lock    sync.Mutex
+	fetched []string
+	waiters map[string][]chan struct{}
+}
+
+func newHTTPRequestLog() *httpRequestLog {
+	return &httpRequestLog{
+		waiters: make(map[string][]chan struct{}),
+	}
+}
+
+func (l *httpRequestLog) record(urlPath string) {
+	urlPath = path.Clean("/" + urlPath)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, p := range l.fetched {
+		if p == urlPath {
+			return
+		}
+	}
+	l.fetched = append(l.fetched, urlPath)
+
+	for _, ch := range l.waiters[urlPath] {
+		close(ch)
+	}
+	delete(l.waiters, urlPath)
+}
+
+// Fetched returns the paths served successfully, in the order they were
+// first requested.
+func (l *httpRequestLog) Fetched() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return append([]string(nil), l.fetched...)
+}
+
+// WaitFor blocks until urlPath was served successfully, or ctx is done.
+func (l *httpRequestLog) WaitFor(ctx context.Context, urlPath string) error {
+	urlPath = path.Clean("/" + urlPath)
+
+	l.lock.Lock()
+	for _, p := range l.fetched {
+		if p == urlPath {
+			l.lock.Unlock()
+			return nil
+		}
+	}
+	ch := make(chan struct{})
+	l.waiters[urlPath] = append(l.waiters[urlPath], ch)
+	l.lock.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// putGeneratedData exposes the fetched paths as the HTTPFetchedPaths build
+// variable, as a comma separated list.
+func (l *httpRequestLog) putGeneratedData(state multistep.StateBag) {
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put(httpFetchedPathsKey, strings.Join(l.Fetched(), ","))
+}
+
+// httpStatusRecorder keeps the status and size of a response for logging.
+type httpStatusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *httpStatusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *httpStatusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+// httpAccessLogHandler shows the requests served by handler, and records the
+// successful ones in requestLog.
+func httpAccessLogHandler(handler http.Handler, ui packersdk.Ui, requestLog *httpRequestLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &httpStatusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		ui.Message(fmt.Sprintf("HTTP server: %s %s %s -> %d (%d bytes)",
+			r.RemoteAddr, r.Method, r.URL.Path, recorder.status, recorder.size))
+
+		if recorder.status >= 200 && recorder.status < 300 {
+			requestLog.record(r.URL.Path)
+		}
+	})
+}
+
+// This step serves the handler of commonsteps.StepHTTPServer, wrapped to log
+// the requests it serves.
+//
+// The SDK step doesn't let its handler be wrapped, so Run starts the server
+// when there is one to start, and defers to the SDK step otherwise.
+//
+// Uses:
+//
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	http_port int - The port the HTTP server started on.
+//	http_request_log *httpRequestLog - The paths fetched from the server.
+type stepHTTPServer struct {
+	*commonsteps.StepHTTPServer
+
+	l          *net.Listener
+	requestLog *httpRequestLog
+}
+
+func (s *stepHTTPServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.HTTPDir == "" && len(s.HTTPContent) == 0 {
+		return s.StepHTTPServer.Run(ctx, state)
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.HTTPDir != "" {
+		if _, err := os.Stat(s.HTTPDir); err != nil {
+			err := fmt.Errorf("Error finding %q: %s", s.HTTPDir, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	var err error
+	s.l, err = net.ListenRangeConfig{
+		Min:     s.HTTPPortMin,
+		Max:     s.HTTPPortMax,
+		Addr:    s.HTTPAddress,
+		Network: s.HTTPNetworkProcotol,
+	}.Listen(ctx)
+	if err != nil {
+		err := fmt.Errorf("Error finding port: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
+
+	s.requestLog = newHTTPRequestLog()
+	state.Put("http_request_log", s.requestLog)
+
+	server := &http.Server{Addr: "", Handler: httpAccessLogHandler(s.Handler(), ui, s.requestLog)}
+	go server.Serve(s.l)
+
+	state.Put("http_port", s.l.Port)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepHTTPServer) Cleanup(state multistep.StateBag) {
+	if s.l != nil {
+		if err := s.l.Close(); err != nil {
+			ui := state.Get("ui").(packersdk.Ui)
+			err = fmt.Errorf("Failed closing http server on port %d: %w", s.l.Port, err)
+			ui.Error(err.Error())
+		}
+		log.Printf("Stopped HTTP server on port %d", s.l.Port)
+	}
+}
+
+// This step exposes the paths the guest fetched from the HTTP server as the
+// HTTPFetchedPaths build variable, before the provisioners run.
+//
+// Uses:
+//
+//	http_request_log *httpRequestLog
+//
+// Produces:
+//
+//	<nothing>
+type stepPutHTTPFetchedPaths struct{}
+
+func (s *stepPutHTTPFetchedPaths) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if requestLog, ok := state.Get("http_request_log").(*httpRequestLog); ok {
+		requestLog.putGeneratedData(state)
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepPutHTTPFetchedPaths) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,130 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// syncBuffer is a bytes.Buffer written by the HTTP server goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStepHTTPServer(t *testing.T) {
+	output := new(syncBuffer)
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: output,
+	})
+	state.Put("generated_data", map[string]interface{}{})
+
+	step := &stepHTTPServer{
+		StepHTTPServer: commonsteps.HTTPServerFromHTTPConfig(&commonsteps.HTTPConfig{
+			HTTPContent: map[string]string{
+				"/ks.cfg":   "kickstart",
+				"/post.sh":  "exit 0",
+				"/other.sh": "exit 1",
+			},
+			HTTPPortMin:         8000,
+			HTTPPortMax:         9000,
+			HTTPAddress:         "127.0.0.1",
+			HTTPNetworkProtocol: "tcp",
+		}),
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	requestLog := state.Get("http_request_log").(*httpRequestLog)
+	port := state.Get("http_port").(int)
+
+	waitErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		waitErr <- requestLog.WaitFor(ctx, "/post.sh")
+	}()
+
+	for _, p := range []string{"/ks.cfg", "/missing", "/post.sh", "/ks.cfg"} {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, p))
+		if err != nil {
+			t.Fatalf("failed to get %s: %s", p, err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if err := <-waitErr; err != nil {
+		t.Fatalf("should not have error waiting for /post.sh: %s", err)
+	}
+
+	for _, request := range []string{"GET /ks.cfg -> 200 (9 bytes)", "GET /missing -> 404"} {
+		if !strings.Contains(output.String(), request) {
+			t.Fatalf("the request %q should be shown: %s", request, output.String())
+		}
+	}
+
+	expected := []string{"/ks.cfg", "/post.sh"}
+	if fetched := requestLog.Fetched(); !reflect.DeepEqual(fetched, expected) {
+		t.Fatalf("bad fetched paths: %#v", fetched)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := requestLog.WaitFor(ctx, "/other.sh"); err == nil {
+		t.Fatal("should have error waiting for a path that was not fetched")
+	}
+
+	requestLog.putGeneratedData(state)
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData[httpFetchedPathsKey] != "/ks.cfg,/post.sh" {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestStepPutHTTPFetchedPaths(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("generated_data", map[string]interface{}{})
+	requestLog := newHTTPRequestLog()
+	requestLog.record("/ks.cfg")
+	state.Put("http_request_log", requestLog)
+
+	step := new(stepPutHTTPFetchedPaths)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData[httpFetchedPathsKey] != "/ks.cfg" {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
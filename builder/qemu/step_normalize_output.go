@@ -0,0 +1,64 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step removes what differs between two reproducible builds from the
+// output directory, once the disks are converted: the timestamps of the EFI
+// variables and the modification times of the files.
+//
+// Uses:
+//
+//	EFI_VARS_FILE_PATH string
+//	ui                 packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type stepNormalizeOutput struct {
+	OutputDir  string
+	SourceDate time.Time
+}
+
+func (s *stepNormalizeOutput) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Normalizing the output for a reproducible build...")
+	err := s.normalize(state)
+	if err != nil {
+		err := fmt.Errorf("Error normalizing the output: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepNormalizeOutput) normalize(state multistep.StateBag) error {
+	if efivars, ok := state.GetOk(efivarStateKey); ok {
+		if err := normalizeEFIVariableTimestamps(efivars.(string), s.SourceDate); err != nil {
+			return err
+		}
+	}
+
+	return filepath.Walk(s.OutputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, s.SourceDate, s.SourceDate)
+	})
+}
+
+func (s *stepNormalizeOutput) Cleanup(state multistep.StateBag) {}
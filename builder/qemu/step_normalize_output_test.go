@@ -0,0 +1,50 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepNormalizeOutput(t *testing.T) {
+	outputDir := t.TempDir()
+	files := []string{
+		filepath.Join(outputDir, "packer-foo"),
+		filepath.Join(outputDir, "vtpm", "tpm2-00.permall"),
+	}
+	if err := os.Mkdir(filepath.Join(outputDir, "vtpm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range files {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	date := time.Unix(1700000000, 0)
+	state := testState(t)
+	step := &stepNormalizeOutput{
+		OutputDir:  outputDir,
+		SourceDate: date,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	for _, path := range append(files, outputDir) {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(date) {
+			t.Fatalf("bad modification time of %s: %s", path, info.ModTime())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// vtpmStateDirName is the directory of the output the vTPM state is
+// persisted in.
+const vtpmStateDirName = "vtpm"
+
+// This step copies the state of the vTPM to the output directory once the
+// VM is shut down, so the sealed secrets and measurements of the build can
+// be deployed with the image.
+//
+// Uses:
+//
+//	qemu_swtpm_dir string
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	vtpm_state_dir string - The directory the state was persisted in.
+type stepPersistvTPMState struct {
+	OutputDir string
+}
+
+func (s *stepPersistvTPMState) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	stateDir, ok := state.GetOk(swtpmTmpDir)
+	if !ok {
+		return multistep.ActionContinue
+	}
+
+	dst := filepath.Join(s.OutputDir, vtpmStateDirName)
+	ui.Say(fmt.Sprintf("Persisting vTPM state to %s", dst))
+
+	err := os.MkdirAll(dst, 0700)
+	if err == nil {
+		err = copyvTPMState(stateDir.(string), dst)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to persist vtpm state: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("vtpm_state_dir", dst)
+	return multistep.ActionContinue
+}
+
+func (s *stepPersistvTPMState) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,68 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepPersistvTPMState(t *testing.T) {
+	vtpmDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(vtpmDir, "tpm2-00.permall"), []byte("state"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vtpmDir, ".lock"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", filepath.Join(vtpmDir, "vtpm.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	outputDir := t.TempDir()
+	state := testState(t)
+	state.Put(swtpmTmpDir, vtpmDir)
+
+	step := &stepPersistvTPMState{OutputDir: outputDir}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	dst := filepath.Join(outputDir, vtpmStateDirName)
+	if state.Get("vtpm_state_dir") != dst {
+		t.Fatalf("bad vtpm_state_dir: %v", state.Get("vtpm_state_dir"))
+	}
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "tpm2-00.permall" {
+		t.Fatalf("only the state file should be persisted: %v", entries)
+	}
+	info, err := entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("bad mode: %s", info.Mode())
+	}
+
+	// Without vTPM, nothing is persisted
+	outputDir = t.TempDir()
+	state = testState(t)
+	step = &stepPersistvTPMState{OutputDir: outputDir}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, vtpmStateDirName)); !os.IsNotExist(err) {
+		t.Fatalf("the vtpm directory should not exist: %v", err)
+	}
+}
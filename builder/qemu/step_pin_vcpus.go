@@ -0,0 +1,78 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/digitalocean/go-qemu/qmp"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// setThreadAffinity pins a host thread to a host CPU, replaced in tests.
+var setThreadAffinity = schedSetThreadAffinity
+
+// This step pins the vCPU threads of the running VM to host CPUs.
+//
+// Uses:
+//
+//	qmp_monitor *qmp.SocketMonitor
+//	ui          packersdk.Ui
+//
+// Produces:
+//
+//	vcpu_pinning string - The host CPU of each vCPU, as vcpu=cpu,...
+type stepPinVCPUs struct {
+	HostCPUs []int
+}
+
+func (s *stepPinVCPUs) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	qmpMonitor := state.Get("qmp_monitor").(*qmp.SocketMonitor)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	pinning, err := s.pinVCPUs(qmpMonitor)
+	if err != nil {
+		err := fmt.Errorf("Error pinning vCPUs: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Pinned vCPUs to host CPUs: %s", pinning))
+	state.Put("vcpu_pinning", pinning)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put(vcpuPinningKey, pinning)
+
+	return multistep.ActionContinue
+}
+
+// pinVCPUs pins the vCPU threads in order to the host CPUs, and returns the
+// assignment.
+func (s *stepPinVCPUs) pinVCPUs(qmpMonitor *qmp.SocketMonitor) (string, error) {
+	cpus, err := qmpQueryCPUsFast(qmpMonitor)
+	if err != nil {
+		return "", fmt.Errorf("failed to query the vCPUs: %s", err)
+	}
+	if len(cpus) == 0 {
+		return "", fmt.Errorf("QEMU reported no vCPUs")
+	}
+
+	assignment := make([]string, 0, len(cpus))
+	for i, cpu := range cpus {
+		hostCPU := s.HostCPUs[i%len(s.HostCPUs)]
+		log.Printf("Pinning vCPU %d (thread %d) to host CPU %d", cpu.CPUIndex, cpu.ThreadID, hostCPU)
+		if err := setThreadAffinity(cpu.ThreadID, hostCPU); err != nil {
+			return "", fmt.Errorf("failed to pin vCPU %d to host CPU %d: %s", cpu.CPUIndex, hostCPU, err)
+		}
+		assignment = append(assignment, fmt.Sprintf("%d=%d", cpu.CPUIndex, hostCPU))
+	}
+	return strings.Join(assignment, ","), nil
+}
+
+func (s *stepPinVCPUs) Cleanup(state multistep.StateBag) {}
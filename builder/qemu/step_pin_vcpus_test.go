@@ -0,0 +1,62 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// fakeThreadAffinity records the pinned threads instead of pinning them.
+func fakeThreadAffinity(t *testing.T, err error) map[int]int {
+	pinned := map[int]int{}
+	t.Cleanup(func() { setThreadAffinity = schedSetThreadAffinity })
+	setThreadAffinity = func(tid, cpu int) error {
+		pinned[tid] = cpu
+		return err
+	}
+	return pinned
+}
+
+func TestStepPinVCPUs(t *testing.T) {
+	pinned := fakeThreadAffinity(t, nil)
+	state := testState(t)
+	state.Put("qmp_monitor", fakeQMPMonitor(t, func(string) string { return "" }))
+
+	step := &stepPinVCPUs{HostCPUs: []int{4, 6}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+
+	expected := map[int]int{101: 4, 102: 6, 103: 4}
+	for tid, cpu := range expected {
+		if pinned[tid] != cpu {
+			t.Errorf("thread %d pinned to %d, expected %d", tid, pinned[tid], cpu)
+		}
+	}
+	if pinning := state.Get("vcpu_pinning"); pinning != "0=4,1=6,2=4" {
+		t.Errorf("bad pinning: %v", pinning)
+	}
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData[vcpuPinningKey] != "0=4,1=6,2=4" {
+		t.Errorf("bad generated data: %v", generatedData[vcpuPinningKey])
+	}
+}
+
+func TestStepPinVCPUs_error(t *testing.T) {
+	fakeThreadAffinity(t, errors.New("invalid argument"))
+	state := testState(t)
+	state.Put("qmp_monitor", fakeQMPMonitor(t, func(string) string { return "" }))
+
+	step := &stepPinVCPUs{HostCPUs: []int{4}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -17,7 +17,8 @@ import (
 // on the guest machine.
 type stepPortForward struct {
 	CommunicatorType string
-	NetBridge        string
+	Transport        string
+	NetBackend       string
 
 	l *net.Listener
 }
@@ -30,8 +31,16 @@ func (s *stepPortForward) Run(ctx context.Context, state multistep.StateBag) mul
 		ui.Message("No communicator is set; skipping port forwarding setup.")
 		return multistep.ActionContinue
 	}
-	if s.NetBridge != "" {
-		ui.Message("net_bridge is set; skipping port forwarding setup.")
+	if s.CommunicatorType == "qga" || s.CommunicatorType == "serial" {
+		ui.Message(fmt.Sprintf("The %s communicator doesn't use the network; skipping port forwarding setup.", s.CommunicatorType))
+		return multistep.ActionContinue
+	}
+	if s.Transport == "vsock" {
+		ui.Message("The communicator uses vsock; skipping port forwarding setup.")
+		return multistep.ActionContinue
+	}
+	if s.NetBackend != "user" {
+		ui.Message(fmt.Sprintf("The communicator uses the %s network backend; skipping port forwarding setup.", s.NetBackend))
 		return multistep.ActionContinue
 	}
 
@@ -9,17 +9,22 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // stepPrepareEfivars copies the EFIVars file to the output, so we can boot
-// and use it as a RW flash drive
+// and use it as a RW flash drive, and enrolls the Secure Boot keys in it.
 type stepPrepareEfivars struct {
 	EFIEnabled bool
 	OutputDir  string
 	SourcePath string
+	SecureBoot *EFISecureBootConfig
+	// SourceDate is the timestamp of the enrolled keys, the current time
+	// when zero.
+	SourceDate time.Time
 }
 
 const efivarStateKey string = "EFI_VARS_FILE_PATH"
@@ -56,6 +61,21 @@ func (s *stepPrepareEfivars) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
+	if s.SecureBoot != nil && s.SecureBoot.enrollsKeys() {
+		ui.Say("Enrolling Secure Boot keys in the EFI variables...")
+		// Flush the copy before rewriting it
+		outFile.Close()
+		timestamp := s.SourceDate
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		if err := enrollSecureBootKeys(dstPath, s.SecureBoot, timestamp); err != nil {
+			errMsg := fmt.Sprintf("failed to enroll Secure Boot keys: %s", err)
+			ui.Error(errMsg)
+			return multistep.ActionHalt
+		}
+	}
+
 	return multistep.ActionContinue
 }
 
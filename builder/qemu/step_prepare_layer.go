@@ -0,0 +1,81 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+const (
+	// layerParentKey and layerParentChecksumKey are the build variables of
+	// the parent of a layered output.
+	layerParentKey         string = "LayerParent"
+	layerParentChecksumKey string = "LayerParentChecksum"
+)
+
+// This step identifies the parent of a layered output, and optionally
+// verifies the parent the layer will be deployed on is the source image.
+//
+// Uses:
+//
+//	iso_path string
+//	ui       packersdk.Ui
+//
+// Produces:
+//
+//	layer_parent_checksum string - The checksum of the parent image.
+type stepPrepareLayer struct {
+	Parent      string
+	ISOChecksum string
+	Verify      bool
+}
+
+func (s *stepPrepareLayer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	isoPath := state.Get("iso_path").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	checksum, err := sourceChecksum(isoPath, s.ISOChecksum)
+	if err == nil && s.Verify {
+		err = s.verifyParent(ui, isoPath)
+	}
+	if err != nil {
+		err := fmt.Errorf("Error preparing the layer parent: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("layer_parent_checksum", checksum)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put(layerParentKey, s.Parent)
+	generatedData.Put(layerParentChecksumKey, checksum)
+
+	return multistep.ActionContinue
+}
+
+// verifyParent checks the parent, an absolute path, has the content of the
+// source image.
+func (s *stepPrepareLayer) verifyParent(ui packersdk.Ui, isoPath string) error {
+	parentPath := s.Parent
+	ui.Say(fmt.Sprintf("Verifying the layer parent %s...", parentPath))
+	parentSum, err := fileSHA256(parentPath)
+	if err != nil {
+		return err
+	}
+	sourceSum, err := fileSHA256(isoPath)
+	if err != nil {
+		return err
+	}
+	if parentSum != sourceSum {
+		return fmt.Errorf("%s doesn't match the source image: sha256 %s, expected %s", parentPath, parentSum, sourceSum)
+	}
+	return nil
+}
+
+func (s *stepPrepareLayer) Cleanup(state multistep.StateBag) {}
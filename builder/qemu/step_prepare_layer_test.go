@@ -0,0 +1,72 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepPrepareLayer(t *testing.T) {
+	state := testState(t)
+	state.Put("iso_path", "example_source.qcow2")
+
+	step := &stepPrepareLayer{
+		Parent:      "base.qcow2",
+		ISOChecksum: "sha256:ABCDEF",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	if checksum := state.Get("layer_parent_checksum").(string); checksum != "sha256:abcdef" {
+		t.Fatalf("bad checksum: %s", checksum)
+	}
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData[layerParentKey] != "base.qcow2" || generatedData[layerParentChecksumKey] != "sha256:abcdef" {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestStepPrepareLayer_Verify(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.qcow2")
+	if err := os.WriteFile(source, []byte("source image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tc := []struct {
+		name    string
+		content string
+		action  multistep.StepAction
+	}{
+		{"matching parent", "source image", multistep.ActionContinue},
+		{"different parent", "other image", multistep.ActionHalt},
+		{"missing parent", "", multistep.ActionHalt},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			parent := filepath.Join(dir, "parent.qcow2")
+			os.Remove(parent)
+			if c.content != "" {
+				if err := os.WriteFile(parent, []byte(c.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			state := testState(t)
+			state.Put("iso_path", source)
+			step := &stepPrepareLayer{
+				Parent:      parent,
+				ISOChecksum: "none",
+				Verify:      true,
+			}
+			if action := step.Run(context.Background(), state); action != c.action {
+				t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+			}
+		})
+	}
+}
@@ -5,8 +5,10 @@ package qemu
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -19,7 +21,9 @@ func (stepPrepareOutputDir) Run(ctx context.Context, state multistep.StateBag) m
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	if _, err := os.Stat(config.OutputDir); err == nil && config.PackerForce {
+	if config.ResumeFrom != "" {
+		ui.Say(fmt.Sprintf("Resuming from the %s checkpoint in %s...", config.ResumeFrom, config.OutputDir))
+	} else if _, err := os.Stat(config.OutputDir); err == nil && config.PackerForce {
 		ui.Say("Deleting previous output directory...")
 		os.RemoveAll(config.OutputDir)
 	}
@@ -40,6 +44,17 @@ func (stepPrepareOutputDir) Cleanup(state multistep.StateBag) {
 		config := state.Get("config").(*Config)
 		ui := state.Get("ui").(packersdk.Ui)
 
+		// Keep the checkpoints to resume the build from.
+		checkpoints, _ := state.Get("checkpoints").([]string)
+		if config.ResumeFrom != "" {
+			checkpoints = append([]string{config.ResumeFrom}, checkpoints...)
+		}
+		if len(checkpoints) > 0 {
+			ui.Say(fmt.Sprintf("Keeping output directory to resume the build, set resume_from to one of: %s",
+				strings.Join(checkpoints, ", ")))
+			return
+		}
+
 		ui.Say("Deleting output directory...")
 		for i := 0; i < 5; i++ {
 			err := os.RemoveAll(config.OutputDir)
@@ -0,0 +1,48 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step points the disk of a layered output to its parent instead of
+// the downloaded source image. The parent has the same content, so the
+// rebase is unsafe and only rewrites the backing file reference.
+//
+// Uses:
+//
+//	driver          Driver
+//	qemu_disk_paths []string
+//	ui              packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type stepRebaseLayer struct {
+	Parent string
+}
+
+func (s *stepRebaseLayer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	diskPaths := state.Get("qemu_disk_paths").([]string)
+
+	// Only the 'main' disk has a backing file
+	ui.Say(fmt.Sprintf("Rebasing the layer onto %s...", s.Parent))
+	if err := driver.QemuImg("rebase", "-u", "-b", s.Parent, "-F", "qcow2", diskPaths[0]); err != nil {
+		err := fmt.Errorf("Error rebasing the layer: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRebaseLayer) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,29 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepRebaseLayer(t *testing.T) {
+	d := new(DriverMock)
+	state := copyTestState(t, d)
+	state.Put("qemu_disk_paths", []string{"out/disk", "out/disk-1"})
+
+	step := &stepRebaseLayer{Parent: "base.qcow2"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	expected := []string{"rebase", "-u", "-b", "base.qcow2", "-F", "qcow2", "out/disk"}
+	if fmt.Sprint(d.QemuImgCalls) != fmt.Sprint(expected) {
+		t.Fatalf("bad qemu-img calls: %#v", d.QemuImgCalls)
+	}
+
+}
@@ -58,6 +58,9 @@ func (s *stepRun) Run(ctx context.Context, state multistep.StateBag) multistep.S
 	if err := driver.Qemu(command...); err != nil {
 		err := fmt.Errorf("Error launching VM: %s", err)
 		s.ui.Error(err.Error())
+		for _, hint := range netdevFailureHints(config.networkInterfaces(), err.Error()) {
+			s.ui.Error(hint)
+		}
 		return multistep.ActionHalt
 	}
 
@@ -68,14 +71,20 @@ func (s *stepRun) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
+	// The sidecars exit with the VM, which is killed here when the build
+	// fails before shutting it down.
+	expectSidecarExits(state)
 	if err := driver.Stop(); err != nil {
 		ui.Error(fmt.Sprintf("Error shutting down VM: %s", err))
 	}
 }
 
-func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[string]interface{} {
+// getDefaultArgs returns the default arguments, replaced by the qemuargs with
+// the same switch, and the arguments always appended to the qemuargs ones.
+func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) (map[string]interface{}, map[string][]string) {
 
 	defaultArgs := make(map[string]interface{})
+	appendedArgs := make(map[string][]string)
 
 	// Configure "boot" arguement
 	// Run command is different depending whether we're booting from an
@@ -99,6 +108,11 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 	// configure "-name" arguments
 	defaultArgs["-name"] = config.VMName
 
+	// Start from the checkpoint being resumed
+	if config.ResumeFrom != "" {
+		defaultArgs["-loadvm"] = checkpointTag(config.ResumeFrom)
+	}
+
 	// Configure "-machine" arguments
 	if config.Accelerator == "none" {
 		defaultArgs["-machine"] = fmt.Sprintf("type=%s", config.MachineType)
@@ -108,6 +122,19 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 		defaultArgs["-machine"] = fmt.Sprintf("type=%s,accel=%s",
 			config.MachineType, config.Accelerator)
 	}
+	// Allocate the memory from its backend, the NUMA nodes use theirs
+	if config.usesMemoryBackends() && !config.NUMA {
+		defaultArgs["-machine"] = fmt.Sprintf("%s,memory-backend=%s", defaultArgs["-machine"], memoryBackendID)
+	}
+	if config.secureBootSMM() {
+		defaultArgs["-machine"] = defaultArgs["-machine"].(string) + ",smm=on"
+		appendedArgs["-global"] = []string{"driver=cfi.pflash01,property=secure,value=on"}
+	}
+
+	// Start the real time clock at the same date for reproducible builds
+	if config.Reproducible {
+		defaultArgs["-rtc"] = fmt.Sprintf("base=%s,clock=vm", config.sourceDate().Format("2006-01-02T15:04:05"))
+	}
 
 	// Firmware
 	if config.Firmware != "" && !config.PFlash {
@@ -115,13 +142,52 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 	}
 
 	// Configure "-netdev" arguments
-	defaultArgs["-netdev"] = fmt.Sprintf("bridge,id=user.0,br=%s", config.NetBridge)
-	if config.NetBridge == "" {
-		defaultArgs["-netdev"] = "user,id=user.0"
-		if config.CommConfig.Comm.Type != "none" {
+	var netdevArgs []string
+	portForwardNIC, _ := config.portForwardInterface()
+	commNIC := config.communicatorInterface()
+	for _, nic := range config.networkInterfaces() {
+		var hostForwards []string
+		if nic.netdevID == commNIC.netdevID && nic.Backend == "user" && config.CommConfig.usesNetwork() {
 			commHostPort := state.Get("commHostPort").(int)
-			defaultArgs["-netdev"] = fmt.Sprintf("user,id=user.0,hostfwd=tcp::%v-:%d", commHostPort, config.CommConfig.Comm.Port())
+			hostForwards = append(hostForwards, fmt.Sprintf("tcp::%v-:%d", commHostPort, config.CommConfig.Comm.Port()))
+		}
+		if nic.netdevID == portForwardNIC.netdevID {
+			portForwards, _ := state.Get(portForwardsStateKey).([]hostPortForward)
+			for _, pf := range portForwards {
+				hostForwards = append(hostForwards, pf.hostfwd())
+			}
 		}
+		netdevArg := nic.getNetdevArg(hostForwards...)
+		if config.IsolatedNetwork && nic.Backend == "user" {
+			httpPort, _ := state.Get("http_port").(int)
+			isolatedOptions := config.isolatedNetdevOptions(httpPort, nic.netdevID == portForwardNIC.netdevID)
+			netdevArg = strings.Join(append([]string{netdevArg}, isolatedOptions...), ",")
+		}
+		if netdevArg != "" {
+			netdevArgs = append(netdevArgs, netdevArg)
+		}
+	}
+	if len(netdevArgs) == 1 {
+		defaultArgs["-netdev"] = netdevArgs[0]
+	} else if len(netdevArgs) > 1 {
+		defaultArgs["-netdev"] = netdevArgs
+	}
+
+	// Configure "-object" arguments, added to the qemuargs ones as the
+	// options rely on them
+	var objectArgs []string
+	if config.NetworkCaptureFile != "" {
+		objectArgs = append(objectArgs, fmt.Sprintf("filter-dump,id=capture0,netdev=%s,file=%s",
+			config.communicatorInterface().netdevID, config.NetworkCaptureFile))
+	}
+	memoryObjectArgs, numaArgs := config.memoryArgs()
+	objectArgs = append(objectArgs, memoryObjectArgs...)
+	objectArgs = append(objectArgs, config.ioThreadArgs()...)
+	if len(objectArgs) > 0 {
+		appendedArgs["-object"] = objectArgs
+	}
+	if len(numaArgs) > 0 {
+		appendedArgs["-numa"] = numaArgs
 	}
 
 	// Configure "-vnc" arguments
@@ -147,7 +213,7 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 	}
 
 	// Configure "-m" memory argument
-	defaultArgs["-m"] = fmt.Sprintf("%dM", config.MemorySize)
+	defaultArgs["-m"] = config.memorySizeArg()
 
 	// Configure "-smp" processor hardware arguments
 	defaultArgs["-smp"] = config.QemuSMPConfig.getDefaultCmdLine()
@@ -183,21 +249,61 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 		}
 	}
 
+	var chardevArgs []string
 	if config.VTPM {
 		vtpmSockPath := state.Get(swtpmSocketPath)
-		defaultArgs["-chardev"] = fmt.Sprintf("socket,id=vtpm,path=%s", vtpmSockPath)
+		chardevArgs = append(chardevArgs, fmt.Sprintf("socket,id=vtpm,path=%s", vtpmSockPath))
 		defaultArgs["-tpmdev"] = "emulator,id=tpm0,chardev=vtpm"
 	}
 
+	if config.GuestAgentEnable {
+		chardevArgs = append(chardevArgs, fmt.Sprintf("socket,id=qga0,path=%s,server=on,wait=off", config.GuestAgentSocketPath))
+	}
+
+	if config.CommConfig.Comm.Type == "serial" {
+		chardevArgs = append(chardevArgs, fmt.Sprintf("socket,id=serial0,path=%s,server=on,wait=off", config.CommConfig.SerialSocketPath))
+		defaultArgs["-serial"] = "chardev:serial0"
+	}
+
+	// Configure the shared folders
+	var sharedFolderDevices []string
+	switch config.SharedFoldersBackend {
+	case "virtiofs":
+		sockets, _ := state.Get(virtiofsSocketsKey).([]string)
+		for i, sockPath := range sockets {
+			chardevArgs = append(chardevArgs, fmt.Sprintf("socket,id=fs%d,path=%s", i, sockPath))
+			sharedFolderDevices = append(sharedFolderDevices, fmt.Sprintf("%s,queue-size=1024,chardev=fs%d,tag=%s",
+				config.guestArchitecture().virtioDevice("vhost-user-fs"), i, config.SharedFolders[i].Tag))
+		}
+	case "9p":
+		var virtfsArgs []string
+		for i, f := range config.SharedFolders {
+			virtfsArg := fmt.Sprintf("local,path=%s,mount_tag=%s,security_model=none,id=fs%d", f.HostPath, f.Tag, i)
+			if f.ReadOnly {
+				virtfsArg += ",readonly=on"
+			}
+			virtfsArgs = append(virtfsArgs, virtfsArg)
+		}
+		defaultArgs["-virtfs"] = virtfsArgs
+	}
+
+	if len(chardevArgs) > 0 {
+		defaultArgs["-chardev"] = chardevArgs
+	}
+
 	if config.VGA != "" {
 		defaultArgs["-vga"] = config.VGA
 	}
 
 	deviceArgs, driveArgs := s.getDeviceAndDriveArgs(config, state)
+	deviceArgs = append(deviceArgs, sharedFolderDevices...)
+	if config.MemoryBalloon {
+		deviceArgs = append(deviceArgs, fmt.Sprintf("%s,id=balloon0", config.guestArchitecture().virtioDevice("virtio-balloon")))
+	}
 	defaultArgs["-device"] = deviceArgs
 	defaultArgs["-drive"] = driveArgs
 
-	return defaultArgs
+	return defaultArgs, appendedArgs
 }
 
 func getVncConnectionMessage(headless bool, vnc string, vncPass string) string {
@@ -224,10 +330,12 @@ func getVncConnectionMessage(headless bool, vnc string, vncPass string) string {
 	return ""
 }
 
-func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag) ([]string, []string) {
+// getDiskArgs returns the devices and drives attaching the disks.
+func (s *stepRun) getDiskArgs(config *Config, state multistep.StateBag) ([]string, []string) {
 	var deviceArgs []string
 	var driveArgs []string
 	availableScsiIndex := 0
+	arch := config.guestArchitecture()
 
 	vmName := config.VMName
 	imgPath := filepath.Join(config.OutputDir, vmName)
@@ -250,13 +358,36 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 				// that creates a result that is testably the same as the old
 				// code. A pr will follow fixing this broken behavior.
 				if availableScsiIndex == 0 {
-					deviceArgs = append(deviceArgs, fmt.Sprintf("virtio-scsi-pci,id=scsi%d", 0))
+					scsiController := fmt.Sprintf("%s,id=scsi%d", arch.virtioDevice("virtio-scsi"), 0)
+					if config.IOThreads > 0 {
+						scsiController = fmt.Sprintf("%s,iothread=%s", scsiController, config.ioThreadID(0))
+					}
+					deviceArgs = append(deviceArgs, scsiController)
 				}
 				// TODO: Megan: When you remove above conditional,
 				// set deviceArgs = append(deviceArgs, fmt.Sprintf("scsi-hd,bus=scsi%d.0,drive=drive%d", i, i))
-				deviceArgs = append(deviceArgs, fmt.Sprintf("scsi-hd,bus=scsi0.0,drive=drive%d", i))
+				scsiDevice := fmt.Sprintf("scsi-hd,bus=scsi0.0,drive=drive%d", i)
+				if config.Reproducible {
+					scsiDevice = fmt.Sprintf("%s,serial=%s", scsiDevice, reproducibleDiskSerial(i))
+				}
+				deviceArgs = append(deviceArgs, scsiDevice)
 				driveArgumentString = fmt.Sprintf("if=none,file=%s,id=drive%d,cache=%s,discard=%s,format=%s", drivePath, i, config.DiskCache, config.DiskDiscard, config.Format)
 				availableScsiIndex += 1
+			} else if config.Reproducible || config.IOThreads > 0 {
+				// Serial numbers and I/O threads are properties of the
+				// devices, which the drive interfaces don't give access to.
+				device := fmt.Sprintf("ide-hd,drive=drive%d", i)
+				if config.DiskInterface == "virtio" {
+					device = fmt.Sprintf("%s,drive=drive%d", arch.virtioDevice("virtio-blk"), i)
+				}
+				if config.Reproducible {
+					device = fmt.Sprintf("%s,serial=%s", device, reproducibleDiskSerial(i))
+				}
+				if config.IOThreads > 0 {
+					device = fmt.Sprintf("%s,iothread=%s", device, config.ioThreadID(i))
+				}
+				deviceArgs = append(deviceArgs, device)
+				driveArgumentString = fmt.Sprintf("if=none,file=%s,id=drive%d,cache=%s,discard=%s,format=%s", drivePath, i, config.DiskCache, config.DiskDiscard, config.Format)
 			}
 			if config.DetectZeroes != "off" {
 				driveArgumentString = fmt.Sprintf("%s,detect-zeroes=%s", driveArgumentString, config.DetectZeroes)
@@ -267,7 +398,20 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 		driveArgs = append(driveArgs, fmt.Sprintf("file=%s,if=%s,cache=%s,format=%s", imgPath, config.DiskInterface, config.DiskCache, config.Format))
 	}
 
-	deviceArgs = append(deviceArgs, fmt.Sprintf("%s,netdev=user.0", config.NetDevice))
+	return deviceArgs, driveArgs
+}
+
+func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag) ([]string, []string) {
+	deviceArgs, driveArgs := s.getDiskArgs(config, state)
+	availableScsiIndex := 0
+	if s.atLeastVersion2 && config.DiskInterface == "virtio-scsi" {
+		availableScsiIndex = len(driveArgs)
+	}
+	arch := config.guestArchitecture()
+
+	for _, nic := range config.networkInterfaces() {
+		deviceArgs = append(deviceArgs, nic.getDeviceArg())
+	}
 
 	// Configure virtual CDs
 	cdPaths := []string{}
@@ -288,7 +432,7 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 			driveArgs = append(driveArgs, fmt.Sprintf("file=%s,media=cdrom", cdPath))
 		} else if config.CDROMInterface == "virtio-scsi" {
 			if availableScsiIndex == 0 {
-				deviceArgs = append(deviceArgs, fmt.Sprintf("virtio-scsi-pci,id=scsi%d", 0))
+				deviceArgs = append(deviceArgs, fmt.Sprintf("%s,id=scsi%d", arch.virtioDevice("virtio-scsi"), 0))
 			}
 			driveArgs = append(driveArgs, fmt.Sprintf("file=%s,if=none,index=%d,id=cdrom%d,media=cdrom", cdPath, availableScsiIndex, i))
 			deviceArgs = append(deviceArgs, "virtio-scsi-device", fmt.Sprintf("scsi-cd,drive=cdrom%d", i))
@@ -312,6 +456,22 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 		driveArgs = append(driveArgs, fmt.Sprintf("file=%s,if=pflash,unit=1,format=raw", efivar.(string)))
 	}
 
+	// Guest agent
+	if config.GuestAgentEnable {
+		deviceArgs = append(deviceArgs,
+			"virtio-serial",
+			fmt.Sprintf("virtserialport,chardev=qga0,name=%s", guestAgentChannelName))
+	}
+
+	// vsock
+	if config.CommConfig.usesVsock() {
+		cid := state.Get("vsock_cid").(uint32)
+		deviceArgs = append(deviceArgs, fmt.Sprintf("%s,guest-cid=%d", arch.virtioDevice("vhost-vsock"), cid))
+	}
+
+	// Display and input devices the machine type lacks
+	deviceArgs = append(deviceArgs, arch.Devices...)
+
 	// TPM
 	if config.VTPM {
 		deviceArgs = append(deviceArgs, fmt.Sprintf("%s,tpmdev=tpm0", config.TPMType))
@@ -320,7 +480,7 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 	return deviceArgs, driveArgs
 }
 
-func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config *Config, state multistep.StateBag) ([]string, error) {
+func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, appendedArgs map[string][]string, config *Config, state multistep.StateBag) ([]string, error) {
 	// Done setting up defaults; time to process user args and defaults together
 	// and generate output args
 
@@ -329,7 +489,7 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 		s.ui.Say("Overriding default Qemu arguments with qemuargs template option...")
 
 		commHostPort := 0
-		if config.CommConfig.Comm.Type != "none" {
+		if config.CommConfig.usesNetwork() {
 			if v, ok := state.GetOk("commHostPort"); ok {
 				commHostPort = v.(int)
 			}
@@ -349,7 +509,7 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 
 		ictx := config.ctx
 		ictx.Data = qemuArgsTemplateData{
-			HTTPIP:      httpIp,
+			HTTPIP:      httpIPTemplateValue(httpIp),
 			HTTPPort:    httpPort,
 			HTTPDir:     config.HTTPDir,
 			HTTPContent: config.HTTPContent,
@@ -378,6 +538,8 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 		}
 	}
 
+	_, userDrives := inArgs["-drive"]
+
 	// get any remaining missing default args from the default settings
 	for key := range defaultArgs {
 		if _, ok := inArgs[key]; !ok {
@@ -392,10 +554,28 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 		}
 	}
 
+	for key, values := range appendedArgs {
+		inArgs[key] = append(inArgs[key], values...)
+	}
+
 	// Check if we are missing the netDevice #6804
 	if x, ok := inArgs["-device"]; ok {
-		if !strings.Contains(strings.Join(x, ""), config.NetDevice) {
-			inArgs["-device"] = append(inArgs["-device"], fmt.Sprintf("%s,netdev=user.0", config.NetDevice))
+		userDevices := strings.Join(x, "")
+		for _, nic := range config.networkInterfaces() {
+			if !strings.Contains(userDevices, nic.getDeviceMatch()) {
+				inArgs["-device"] = append(inArgs["-device"], nic.getDeviceArg())
+			}
+		}
+
+		// The default drives not attached through an interface need their
+		// devices, unless the user replaced the drives too.
+		if !userDrives {
+			diskDevices, _ := s.getDiskArgs(config, state)
+			for _, device := range diskDevices {
+				if !hasDeviceProperty(x, diskDeviceMatch(device)) {
+					inArgs["-device"] = append(inArgs["-device"], device)
+				}
+			}
 		}
 	}
 
@@ -414,10 +594,37 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 	return outArgs, nil
 }
 
+// diskDeviceMatch returns the property identifying a disk device among
+// user-supplied `-device` arguments: its drive, or its id for controllers.
+func diskDeviceMatch(device string) string {
+	var id string
+	for _, property := range strings.Split(device, ",")[1:] {
+		if strings.HasPrefix(property, "drive=") {
+			return property
+		}
+		if strings.HasPrefix(property, "id=") {
+			id = property
+		}
+	}
+	return id
+}
+
+// hasDeviceProperty tells whether one of the devices has the property.
+func hasDeviceProperty(devices []string, property string) bool {
+	for _, device := range devices {
+		for _, p := range strings.Split(device, ",") {
+			if p == property {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *stepRun) getCommandArgs(config *Config, state multistep.StateBag) ([]string, error) {
-	defaultArgs := s.getDefaultArgs(config, state)
+	defaultArgs, appendedArgs := s.getDefaultArgs(config, state)
 
-	return s.applyUserOverrides(defaultArgs, config, state)
+	return s.applyUserOverrides(defaultArgs, appendedArgs, config, state)
 }
 
 func processArgs(args [][]string, ctx *interpolate.Context) ([][]string, error) {
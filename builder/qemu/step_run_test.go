@@ -5,6 +5,7 @@ package qemu
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
@@ -88,10 +89,50 @@ func Test_UserOverrides(t *testing.T) {
 			},
 			"Net device gets added",
 		},
+		{
+			&Config{
+				VMName:           "myvm",
+				QemuTuningConfig: QemuTuningConfig{IOThreads: 1},
+				QemuArgs:         [][]string{{"-object", "rng-random,id=rng0,filename=/dev/urandom"}},
+			},
+			[]string{
+				"-display", "gtk",
+				"-object", "rng-random,id=rng0,filename=/dev/urandom",
+				"-object", "iothread,id=iothread0",
+				"-drive", "file=/path/to/test.iso,media=cdrom",
+				"-device", ",netdev=user.0",
+			},
+			"Objects of the options are added to the user ones",
+		},
+		{
+			&Config{
+				VMName:          "myvm",
+				DiskInterface:   "virtio",
+				DiskCache:       "writeback",
+				DiskDiscard:     "ignore",
+				DetectZeroes:    "off",
+				Format:          "qcow2",
+				Reproducible:    true,
+				SourceDateEpoch: new(int64),
+				QemuArgs:        [][]string{{"-device", "virtio-rng-pci"}},
+			},
+			[]string{
+				"-display", "gtk",
+				"-device", "virtio-rng-pci",
+				"-device", "virtio-blk-pci,drive=drive0,serial=packer-disk0",
+				"-rtc", "base=1970-01-01T00:00:00,clock=vm",
+				"-drive", "if=none,file=/path/to/disk0,id=drive0,cache=writeback,discard=ignore,format=qcow2",
+				"-drive", "file=/path/to/test.iso,media=cdrom",
+			},
+			"Disk devices get added",
+		},
 	}
 
 	for _, tc := range testcases {
 		state := runTestState(t, tc.Config)
+		if tc.Config.DiskInterface != "" {
+			state.Put("qemu_disk_paths", []string{"/path/to/disk0"})
+		}
 
 		step := &stepRun{
 			atLeastVersion2: true,
@@ -457,6 +498,104 @@ func Test_DriveAndDeviceArgs(t *testing.T) {
 	}
 }
 
+func Test_NetworkInterfaces(t *testing.T) {
+	c := &Config{
+		VMName: "myvm",
+		NetworkInterfaces: []QemuNetworkInterface{
+			{
+				Model:        "virtio-net",
+				Backend:      "user",
+				HostForwards: []string{"tcp::8080-:80"},
+			},
+			{
+				Model:        "e1000",
+				MACAddress:   "52:54:00:12:34:56",
+				Backend:      "tap",
+				TapDevice:    "tap0",
+				Communicator: true,
+			},
+			{
+				Model:   "rtl8139",
+				Backend: "none",
+			},
+		},
+		CommConfig: CommConfig{
+			Comm: communicator.Config{
+				Type: "ssh",
+				SSH: communicator.SSH{
+					SSHPort: 22,
+				},
+			},
+		},
+	}
+
+	state := runTestState(t, c)
+	step := &stepRun{
+		atLeastVersion2: true,
+		ui:              packersdk.TestUi(t),
+	}
+	args, err := step.getCommandArgs(c, state)
+	if err != nil {
+		t.Fatalf("should not have an error getting args. Error: %s", err)
+	}
+
+	expected := []string{
+		"-display", "gtk",
+		"-m", "0M",
+		"-boot", "once=d",
+		"-fda", "fake_floppy_path",
+		"-name", "myvm",
+		"-netdev", "user,id=net0,hostfwd=tcp::8080-:80",
+		"-netdev", "tap,id=net1,ifname=tap0,script=no,downscript=no",
+		"-device", "virtio-net,netdev=net0,id=nic0",
+		"-device", "e1000,netdev=net1,mac=52:54:00:12:34:56,id=nic1",
+		"-device", "rtl8139,id=nic2",
+		"-vnc", ":5",
+		"-machine", "type=,accel=",
+		"-drive", "file=/path/to/test.iso,media=cdrom",
+		"-smp", "1",
+	}
+
+	assert.ElementsMatch(t, args, expected, "one netdev per interface with a backend, and one device per interface: %s", args)
+
+	// The communicator port is forwarded on the communicator interface only
+	c.NetworkInterfaces[1] = QemuNetworkInterface{
+		Model:        "e1000",
+		Backend:      "user",
+		Communicator: true,
+	}
+	args, err = step.getCommandArgs(c, state)
+	if err != nil {
+		t.Fatalf("should not have an error getting args. Error: %s", err)
+	}
+	if !matchArgument(args, []string{"-netdev", "user,id=net1,hostfwd=tcp::5000-:22"}) {
+		t.Fatalf("communicator port should be forwarded on net1. Got: %#v", args)
+	}
+
+	// Without a communicator interface, the first interface is the one
+	c.NetworkInterfaces[1].Communicator = false
+	args, err = step.getCommandArgs(c, state)
+	if err != nil {
+		t.Fatalf("should not have an error getting args. Error: %s", err)
+	}
+	if !matchArgument(args, []string{"-netdev", "user,id=net0,hostfwd=tcp::5000-:22,hostfwd=tcp::8080-:80"}) {
+		t.Fatalf("communicator port should be forwarded on net0. Got: %#v", args)
+	}
+
+	// User-supplied devices don't remove the interfaces they don't mention
+	c.QemuArgs = [][]string{{"-device", "virtio-net,netdev=net0,id=custom"}}
+	args, err = step.getCommandArgs(c, state)
+	if err != nil {
+		t.Fatalf("should not have an error getting args. Error: %s", err)
+	}
+	if matchArgument(args, []string{"-device", "virtio-net,netdev=net0,id=nic0"}) {
+		t.Fatalf("net0 device should not be added again. Got: %#v", args)
+	}
+	if !matchArgument(args, []string{"-device", "e1000,netdev=net1,id=nic1"}) {
+		t.Fatalf("net1 device should be added. Got: %#v", args)
+	}
+}
+
 func Test_OptionalConfigOptionsGetSet(t *testing.T) {
 	c := &Config{
 		VNCUsePassword: true,
@@ -508,6 +647,7 @@ func Test_Defaults(t *testing.T) {
 		Reason     string
 	}
 
+	sourceDateEpoch := int64(1700000000)
 	testcases := []testCase{
 		{
 			&Config{},
@@ -545,6 +685,131 @@ func Test_Defaults(t *testing.T) {
 			[]string{"-qmp", "unix:,server,nowait"},
 			"Args contain -qmp even when socket path isn't set, if qmp enabled",
 		},
+		{
+			&Config{
+				GuestAgentEnable:     true,
+				GuestAgentSocketPath: "/path/to/qga",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-chardev", "socket,id=qga0,path=/path/to/qga,server=on,wait=off"},
+			"Args should contain the guest agent chardev when guest_agent_enable is set",
+		},
+		{
+			&Config{
+				IsolatedNetwork:                 true,
+				IsolatedNetworkAllowedEndpoints: []string{"mirror.example.com:80"},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "user,id=user.0,hostfwd=tcp::5000-:0,restrict=on," +
+				"guestfwd=tcp:10.0.2.100:1234-cmd:nc 127.0.0.1 1234," +
+				"guestfwd=tcp:10.0.2.101:80-cmd:nc mirror.example.com 80"},
+			"Isolated network restricts the netdev and forwards the HTTP server and allowed endpoints",
+		},
+		{
+			&Config{
+				NetworkCaptureFile: "output/capture.pcap",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-object", "filter-dump,id=capture0,netdev=user.0,file=output/capture.pcap"},
+			"Network capture dumps the communicator netdev",
+		},
+		{
+			&Config{
+				GuestAgentEnable:     true,
+				GuestAgentSocketPath: "/path/to/qga",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtserialport,chardev=qga0,name=org.qemu.guest_agent.0"},
+			"Args should contain the guest agent port when guest_agent_enable is set",
+		},
+		{
+			&Config{
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "ssh",
+					},
+					CommunicatorTransport: "vsock",
+				},
+			},
+			map[string]interface{}{"vsock_cid": uint32(42)},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "vhost-vsock-pci,guest-cid=42"},
+			"Args should contain the vsock device with the allocated CID when the transport is vsock",
+		},
+		{
+			&Config{
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "ssh",
+					},
+					CommunicatorTransport: "vsock",
+				},
+			},
+			map[string]interface{}{"vsock_cid": uint32(42)},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "user,id=user.0"},
+			"The communicator port isn't forwarded when the transport is vsock",
+		},
+		{
+			&Config{
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "serial",
+					},
+					SerialSocketPath: "/path/to/console",
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-chardev", "socket,id=serial0,path=/path/to/console,server=on,wait=off"},
+			"Args should contain the serial console chardev with the serial communicator",
+		},
+		{
+			&Config{
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "serial",
+					},
+					SerialSocketPath: "/path/to/console",
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-serial", "chardev:serial0"},
+			"The serial port should use the console chardev with the serial communicator",
+		},
+		{
+			&Config{
+				Architecture: "aarch64",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "usb-kbd"},
+			"Args should contain a USB keyboard to type the boot command with on aarch64",
+		},
+		{
+			&Config{
+				Architecture:   "s390x",
+				CDROMInterface: "virtio-scsi",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtio-scsi-ccw,id=scsi0"},
+			"The SCSI controller should be attached to the channel subsystem on s390x",
+		},
+		{
+			&Config{
+				ResumeFrom: "boot_command",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-loadvm", "packer-boot_command"},
+			"Start from the checkpoint when resuming a build",
+		},
 		{
 			&Config{
 				VMName: "partyname",
@@ -582,6 +847,48 @@ func Test_Defaults(t *testing.T) {
 			[]string{"-machine", "type=fancymachine,accel=kvm"},
 			"Add accelerator tag when accelerator is set.",
 		},
+		{
+			&Config{
+				Architecture: "x86_64",
+				Accelerator:  "kvm",
+				MachineType:  "q35",
+				QemuEFIBootConfig: QemuEFIBootConfig{
+					EFISecureBoot: EFISecureBootConfig{Enabled: true},
+				},
+				QemuArgs: [][]string{{"-global", "virtio-pci.disable-modern=on"}},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-global", "driver=cfi.pflash01,property=secure,value=on"},
+			"Keep the Secure Boot flash restriction with user globals",
+		},
+		{
+			&Config{
+				Architecture: "x86_64",
+				Accelerator:  "kvm",
+				MachineType:  "q35",
+				QemuEFIBootConfig: QemuEFIBootConfig{
+					EFISecureBoot: EFISecureBootConfig{Enabled: true},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-machine", "type=q35,accel=kvm,smm=on"},
+			"Enable SMM with Secure Boot on x86_64.",
+		},
+		{
+			&Config{
+				Architecture: "x86_64",
+				MachineType:  "q35",
+				QemuEFIBootConfig: QemuEFIBootConfig{
+					EFISecureBoot: EFISecureBootConfig{Enabled: true},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-global", "driver=cfi.pflash01,property=secure,value=on"},
+			"Restrict the firmware flash to SMM with Secure Boot on x86_64.",
+		},
 		{
 			&Config{
 				NetBridge: "fakebridge",
@@ -622,6 +929,28 @@ func Test_Defaults(t *testing.T) {
 			[]string{"-netdev", "user,id=user.0,hostfwd=tcp::1111-:4567"},
 			"Host forwarding when a communicator is configured",
 		},
+		{
+			&Config{
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "ssh",
+						SSH: communicator.SSH{
+							SSHPort: 22,
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"commHostPort": 1111,
+				portForwardsStateKey: []hostPortForward{
+					{Protocol: "tcp", HostPort: 8080, GuestPort: 80},
+					{Protocol: "udp", HostPort: 5353, GuestPort: 53},
+				},
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "user,id=user.0,hostfwd=tcp::1111-:22,hostfwd=tcp::8080-:80,hostfwd=udp::5353-:53"},
+			"Extra port forwards are added after the communicator one",
+		},
 		{
 			&Config{
 				VNCBindAddress: "1.1.1.1",
@@ -742,6 +1071,205 @@ func Test_Defaults(t *testing.T) {
 			[]string{"-display", "gtk"},
 			"Display option should default to gtk",
 		},
+		{
+			&Config{
+				Reproducible:    true,
+				SourceDateEpoch: &sourceDateEpoch,
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-rtc", "base=2023-11-14T22:13:20,clock=vm"},
+			"Start the clock at the source date of reproducible builds",
+		},
+		{
+			&Config{
+				Reproducible:    true,
+				SourceDateEpoch: new(int64),
+				NetDevice:       "virtio-net",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtio-net,netdev=user.0,mac=52:54:00:12:34:56"},
+			"Fix the MAC address of reproducible builds",
+		},
+		{
+			&Config{
+				Reproducible:    true,
+				SourceDateEpoch: new(int64),
+				DiskInterface:   "virtio",
+				DiskCache:       "writeback",
+				Format:          "qcow2",
+				DetectZeroes:    "off",
+			},
+			map[string]interface{}{
+				"qemu_disk_paths": []string{"disk0", "disk1"},
+			},
+			&stepRun{
+				atLeastVersion2: true,
+				ui:              packersdk.TestUi(t),
+			},
+			[]string{"-device", "virtio-blk-pci,drive=drive1,serial=packer-disk1"},
+			"Attach virtio disks as devices to give them a serial number",
+		},
+		{
+			&Config{
+				Reproducible:    true,
+				SourceDateEpoch: new(int64),
+				DiskInterface:   "virtio-scsi",
+			},
+			map[string]interface{}{
+				"qemu_disk_paths": []string{"disk0"},
+			},
+			&stepRun{
+				atLeastVersion2: true,
+				ui:              packersdk.TestUi(t),
+			},
+			[]string{"-device", "scsi-hd,bus=scsi0.0,drive=drive0,serial=packer-disk0"},
+			"Give virtio-scsi disks a serial number",
+		},
+		{
+			&Config{
+				MemorySize:           2048,
+				SharedFolders:        []SharedFolder{{HostPath: "/opt/toolchains", Tag: "toolchain"}},
+				SharedFoldersBackend: "virtiofs",
+			},
+			map[string]interface{}{
+				virtiofsSocketsKey: []string{"/tmp/fs0.sock"},
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "vhost-user-fs-pci,queue-size=1024,chardev=fs0,tag=toolchain"},
+			"Attach the virtiofs shared folders",
+		},
+		{
+			&Config{
+				MemorySize:           2048,
+				SharedFolders:        []SharedFolder{{HostPath: "/opt/toolchains", Tag: "toolchain"}},
+				SharedFoldersBackend: "virtiofs",
+			},
+			map[string]interface{}{
+				virtiofsSocketsKey: []string{"/tmp/fs0.sock"},
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-object", "memory-backend-memfd,id=packer-mem,size=2048M,share=on"},
+			"Share the memory of the VM with virtiofsd",
+		},
+		{
+			&Config{
+				Accelerator:          "kvm",
+				MachineType:          "q35",
+				SharedFolders:        []SharedFolder{{HostPath: "/opt/toolchains", Tag: "toolchain"}},
+				SharedFoldersBackend: "virtiofs",
+			},
+			map[string]interface{}{
+				virtiofsSocketsKey: []string{"/tmp/fs0.sock"},
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-machine", "type=q35,accel=kvm,memory-backend=packer-mem"},
+			"Allocate the memory of the VM from the shared memory backend",
+		},
+		{
+			&Config{
+				SharedFolders:        []SharedFolder{{HostPath: "/opt/toolchains", Tag: "toolchain", ReadOnly: true}},
+				SharedFoldersBackend: "9p",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-virtfs", "local,path=/opt/toolchains,mount_tag=toolchain,security_model=none,id=fs0,readonly=on"},
+			"Share the folders with 9p",
+		},
+		{
+			&Config{
+				MemorySize: 2048,
+				QemuMemoryConfig: QemuMemoryConfig{
+					MemoryMaxSize: 8192,
+					MemorySlots:   4,
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-m", "2048M,slots=4,maxmem=8192M"},
+			"Add memory hotplug slots",
+		},
+		{
+			&Config{
+				MemorySize: 2048,
+				QemuMemoryConfig: QemuMemoryConfig{
+					MemoryHugepages:     true,
+					MemoryHugepagesPath: "/dev/hugepages",
+					MemoryPrealloc:      true,
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-object", "memory-backend-file,id=packer-mem,size=2048M,mem-path=/dev/hugepages,prealloc=on"},
+			"Back the memory with hugepages",
+		},
+		{
+			&Config{
+				MemorySize:    2048,
+				QemuSMPConfig: QemuSMPConfig{SocketCount: 2},
+				QemuMemoryConfig: QemuMemoryConfig{
+					NUMA: true,
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-object", "memory-backend-ram,id=packer-mem1,size=1024M"},
+			"Split the memory between the NUMA nodes",
+		},
+		{
+			&Config{
+				MemorySize:    2048,
+				QemuSMPConfig: QemuSMPConfig{SocketCount: 2},
+				QemuMemoryConfig: QemuMemoryConfig{
+					NUMA: true,
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-numa", "cpu,node-id=1,socket-id=1"},
+			"Put the CPUs of each socket in its NUMA node",
+		},
+		{
+			&Config{
+				QemuMemoryConfig: QemuMemoryConfig{
+					MemoryBalloon: true,
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtio-balloon-pci,id=balloon0"},
+			"Attach a memory balloon",
+		},
+		{
+			&Config{
+				QemuTuningConfig: QemuTuningConfig{IOThreads: 2},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-object", "iothread,id=iothread1"},
+			"Add the I/O threads",
+		},
+		{
+			&Config{
+				DiskInterface:    "virtio",
+				QemuTuningConfig: QemuTuningConfig{IOThreads: 2},
+			},
+			map[string]interface{}{"qemu_disk_paths": []string{"/path/to/disk0", "/path/to/disk1"}},
+			&stepRun{ui: packersdk.TestUi(t), atLeastVersion2: true},
+			[]string{"-device", "virtio-blk-pci,drive=drive1,iothread=iothread1"},
+			"Run the virtio disks in the I/O threads",
+		},
+		{
+			&Config{
+				DiskInterface:    "virtio-scsi",
+				QemuTuningConfig: QemuTuningConfig{IOThreads: 1},
+			},
+			map[string]interface{}{"qemu_disk_paths": []string{"/path/to/disk0"}},
+			&stepRun{ui: packersdk.TestUi(t), atLeastVersion2: true},
+			[]string{"-device", "virtio-scsi-pci,id=scsi0,iothread=iothread0"},
+			"Run the virtio-scsi controller in the I/O thread",
+		},
 		{
 			&Config{
 				VGA: "virtio",
@@ -787,3 +1315,25 @@ func matchArgument(actual []string, expected []string) bool {
 	}
 	return false
 }
+
+func Test_NetdevFailureHints(t *testing.T) {
+	nics := []QemuNetworkInterface{
+		{Backend: "user"},
+		{Backend: "bridge", Bridge: "virbr0"},
+		{Backend: "tap", TapDevice: "tap0"},
+	}
+
+	hints := netdevFailureHints(nics, "Qemu failed to start: access denied by acl file\nqemu-system-x86_64: -netdev bridge,id=net1,br=virbr0: bridge helper failed")
+	if len(hints) != 1 || !strings.Contains(hints[0], "allow virbr0") {
+		t.Fatalf("bad hints: %#v", hints)
+	}
+
+	hints = netdevFailureHints(nics, "qemu-system-x86_64: -netdev tap,id=net2,ifname=tap0,script=no,downscript=no: could not configure /dev/net/tun (tap0): Operation not permitted")
+	if len(hints) != 1 || !strings.Contains(hints[0], "ip tuntap add dev tap0") {
+		t.Fatalf("bad hints: %#v", hints)
+	}
+
+	if hints := netdevFailureHints(nics, "qemu-system-x86_64: unrelated failure"); len(hints) != 0 {
+		t.Fatalf("bad hints: %#v", hints)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+const virtiofsSocketsKey string = "qemu_virtiofs_sockets"
+
+// virtiofsdStartTimeout is how long virtiofsd has to create its socket.
+var virtiofsdStartTimeout = 10 * time.Second
+
+// This step shares the host folders with the guest. With virtiofs, it starts
+// a virtiofsd per folder, running until the end of the build, and aborts
+// the build when one of them exits.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	qemu_virtiofs_sockets []string - The virtiofsd socket of each folder.
+type stepShareFolders struct {
+	Folders []SharedFolder
+	Backend string
+	// abort cancels the build when virtiofsd exits before the VM is done
+	// with it.
+	abort context.CancelCauseFunc
+
+	socketDir string
+	virtiofsd []*sidecar
+	logPaths  []string
+}
+
+func (s *stepShareFolders) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.Backend == "virtiofs" {
+		if err := s.startVirtiofsd(ctx, ui, state); err != nil {
+			err := fmt.Errorf("Error sharing folders: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	tags := make([]string, len(s.Folders))
+	for i, f := range s.Folders {
+		tags[i] = f.Tag
+	}
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put(sharedFoldersTypeKey, s.Backend)
+	generatedData.Put(sharedFolderTagsKey, strings.Join(tags, ","))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepShareFolders) startVirtiofsd(ctx context.Context, ui packersdk.Ui, state multistep.StateBag) error {
+	virtiofsdPath, err := findVirtiofsd()
+	if err != nil {
+		return fmt.Errorf("failed to locate virtiofsd: %s", err)
+	}
+
+	s.socketDir, err = os.MkdirTemp("", "packer-virtiofs")
+	if err != nil {
+		return err
+	}
+
+	var sockets []string
+	for i, f := range s.Folders {
+		ui.Say(fmt.Sprintf("Sharing %s with the guest as %s...", f.HostPath, f.Tag))
+
+		logFile, err := os.CreateTemp("", "virtiofsd-*.log")
+		if err != nil {
+			return fmt.Errorf("failed to create virtiofsd log file: %s", err)
+		}
+		s.logPaths = append(s.logPaths, logFile.Name())
+
+		sockPath := filepath.Join(s.socketDir, fmt.Sprintf("fs%d.sock", i))
+		args := []string{
+			fmt.Sprintf("--socket-path=%s", sockPath),
+			fmt.Sprintf("--shared-dir=%s", f.HostPath),
+			"--cache=auto",
+		}
+		if f.ReadOnly {
+			args = append(args, "--readonly")
+		}
+		// Sandboxing virtiofsd in namespaces requires privileges
+		if os.Geteuid() != 0 {
+			args = append(args, "--sandbox=none")
+		}
+
+		virtiofsd, err := startSidecar("virtiofsd", virtiofsdPath, args, logFile)
+		logFile.Close()
+		if err != nil {
+			return fmt.Errorf("failed to start virtiofsd: %s", err)
+		}
+		s.virtiofsd = append(s.virtiofsd, virtiofsd)
+
+		if err := virtiofsd.waitForSocket(ctx, sockPath, virtiofsdStartTimeout); err != nil {
+			return fmt.Errorf("virtiofsd failed to start: %s%s", err, virtiofsd.logTail())
+		}
+		log.Printf("virtiofsd is listening on %s", sockPath)
+		sockets = append(sockets, sockPath)
+	}
+
+	// From now on, virtiofsd exiting is a failure of the build.
+	for _, virtiofsd := range s.virtiofsd {
+		virtiofsd.monitor(ui, s.abort)
+		registerSidecar(state, virtiofsd)
+	}
+
+	state.Put(virtiofsSocketsKey, sockets)
+	return nil
+}
+
+func (s *stepShareFolders) Cleanup(state multistep.StateBag) {
+	for _, virtiofsd := range s.virtiofsd {
+		virtiofsd.stop()
+	}
+	for _, logPath := range s.logPaths {
+		removeSidecarLog("virtiofsd", logPath)
+	}
+	if s.socketDir != "" {
+		os.RemoveAll(s.socketDir)
+	}
+}
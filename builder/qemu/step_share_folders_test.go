@@ -0,0 +1,253 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// TestHelperVirtiofsd is not a real test, the fake virtiofsd of
+// useFakeVirtiofsd runs it to behave like virtiofsd.
+func TestHelperVirtiofsd(t *testing.T) {
+	argsPath := os.Getenv("FAKE_VIRTIOFSD_ARGS")
+	if argsPath == "" {
+		return
+	}
+
+	var sockPath string
+	var args []string
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = os.Args[i+1:]
+		}
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--socket-path=") {
+			sockPath = strings.TrimPrefix(arg, "--socket-path=")
+		}
+	}
+
+	f, err := os.OpenFile(argsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		os.Exit(2)
+	}
+	fmt.Fprintln(f, strings.Join(args, " "))
+	f.Close()
+
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		os.Exit(2)
+	}
+	// Like virtiofsd, serve a single client and exit when it disconnects.
+	conn, err := l.Accept()
+	if err != nil {
+		os.Exit(2)
+	}
+	io.Copy(io.Discard, conn)
+	fmt.Fprintln(os.Stderr, "Client disconnected, shutting down")
+	os.Exit(0)
+}
+
+// useFakeVirtiofsd puts a fake virtiofsd first in the PATH, or no virtiofsd
+// at all when installed is false. The fake virtiofsd writes its arguments
+// to the returned file.
+func useFakeVirtiofsd(t *testing.T, installed bool) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("virtiofsd is not supported on windows")
+	}
+
+	paths := virtiofsdPaths
+	virtiofsdPaths = nil
+	t.Cleanup(func() { virtiofsdPaths = paths })
+
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "virtiofsd.args")
+	if installed {
+		virtiofsd := fmt.Sprintf("#!/bin/sh\nexec %q -test.run=^TestHelperVirtiofsd$ -- \"$@\"\n", os.Args[0])
+		if err := os.WriteFile(filepath.Join(dir, "virtiofsd"), []byte(virtiofsd), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("PATH", dir)
+	t.Setenv("FAKE_VIRTIOFSD_ARGS", argsPath)
+	return argsPath
+}
+
+func TestStepShareFolders_Virtiofs(t *testing.T) {
+	argsPath := useFakeVirtiofsd(t, true)
+
+	var aborted error
+	state := testState(t)
+	step := &stepShareFolders{
+		Folders: []SharedFolder{
+			{HostPath: "/opt/toolchains", Tag: "toolchain", ReadOnly: true},
+			{HostPath: "/srv/cache", Tag: "cache"},
+		},
+		Backend: "virtiofs",
+		abort:   func(err error) { aborted = err },
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	sockets := state.Get(virtiofsSocketsKey).([]string)
+	if len(sockets) != 2 {
+		t.Fatalf("bad sockets: %#v", sockets)
+	}
+	for _, sockPath := range sockets {
+		if _, err := os.Stat(sockPath); err != nil {
+			t.Fatalf("the socket should exist: %s", err)
+		}
+	}
+	for _, virtiofsd := range step.virtiofsd {
+		select {
+		case <-virtiofsd.exited:
+			t.Fatalf("virtiofsd should wait for QEMU: %s", virtiofsd.logTail())
+		default:
+		}
+	}
+
+	args, err := os.ReadFile(argsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if len(lines) != 2 ||
+		!strings.Contains(lines[0], "--shared-dir=/opt/toolchains") || !strings.Contains(lines[0], "--readonly") ||
+		!strings.Contains(lines[1], "--shared-dir=/srv/cache") || strings.Contains(lines[1], "--readonly") {
+		t.Fatalf("bad virtiofsd arguments: %s", args)
+	}
+
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData[sharedFoldersTypeKey] != "virtiofs" || generatedData[sharedFolderTagsKey] != "toolchain,cache" {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+
+	socketDir := step.socketDir
+	step.Cleanup(state)
+	if aborted != nil {
+		t.Fatalf("stopping virtiofsd should not abort the build: %s", aborted)
+	}
+	if _, err := os.Stat(socketDir); !os.IsNotExist(err) {
+		t.Fatalf("the socket directory should be removed: %v", err)
+	}
+}
+
+func TestStepShareFolders_9p(t *testing.T) {
+	useFakeVirtiofsd(t, false)
+
+	state := testState(t)
+	step := &stepShareFolders{
+		Folders: []SharedFolder{{HostPath: "/opt/toolchains", Tag: "toolchain"}},
+		Backend: "9p",
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	if _, ok := state.GetOk(virtiofsSocketsKey); ok {
+		t.Fatal("virtiofsd should not be started")
+	}
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData[sharedFoldersTypeKey] != "9p" || generatedData[sharedFolderTagsKey] != "toolchain" {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestStepShareFolders_ExitAfterShutdown(t *testing.T) {
+	useFakeVirtiofsd(t, true)
+
+	aborted := make(chan error, 1)
+	state := testState(t)
+	state.Put("driver", &DriverMock{WaitForShutdownState: true})
+	step := &stepShareFolders{
+		Folders: []SharedFolder{{HostPath: "/srv/cache", Tag: "cache"}},
+		Backend: "virtiofs",
+		abort:   func(err error) { aborted <- err },
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	// QEMU connects, then disconnects when the VM shuts down.
+	conn, err := net.Dial("unix", state.Get(virtiofsSocketsKey).([]string)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	shutdown := &stepShutdown{
+		ShutdownTimeout: time.Minute,
+		Comm:            &communicator.Config{Type: "none"},
+	}
+	if action := shutdown.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	conn.Close()
+
+	select {
+	case <-step.virtiofsd[0].exited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("virtiofsd should have exited")
+	}
+	select {
+	case err := <-aborted:
+		t.Fatalf("virtiofsd exiting with the VM should not abort the build: %s", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestStepShareFolders_ExitAfterHalt(t *testing.T) {
+	useFakeVirtiofsd(t, true)
+
+	ctx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	state := testState(t)
+	step := &stepShareFolders{
+		Folders: []SharedFolder{{HostPath: "/srv/cache", Tag: "cache"}},
+		Backend: "virtiofs",
+		abort:   abort,
+	}
+	defer step.Cleanup(state)
+	if action := step.Run(ctx, state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+
+	// QEMU connects, then a later step fails and QEMU is killed in the
+	// cleanup, disconnecting from virtiofsd.
+	conn, err := net.Dial("unix", state.Get(virtiofsSocketsKey).([]string)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildErr := errors.New("Timeout waiting for SSH.")
+	state.Put("error", buildErr)
+	(&stepRun{}).Cleanup(state)
+	conn.Close()
+
+	select {
+	case <-step.virtiofsd[0].exited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("virtiofsd should have exited")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if cause := context.Cause(ctx); cause != nil {
+		t.Fatalf("virtiofsd exiting with the VM should not abort the build: %s", cause)
+	}
+	if err := buildError(ctx, state); err != buildErr {
+		t.Fatalf("the build should fail with the step error, got: %v", err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 //	communicator packersdk.Communicator
 //	config *config
 //	driver Driver
+//	qemu_sidecars []*sidecar
 //	ui     packersdk.Ui
 //
 // Produces:
@@ -38,6 +39,9 @@ func (s *stepShutdown) Run(ctx context.Context, state multistep.StateBag) multis
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
+	// swtpm and virtiofsd exit with the VM, that's not a failure anymore.
+	expectSidecarExits(state)
+
 	if s.Comm.Type == "none" {
 		cancelCh := make(chan struct{}, 1)
 		go func() {
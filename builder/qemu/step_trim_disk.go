@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step discards the free space of the guest file systems, so QEMU
+// releases it from the disks, and reports the space reclaimed.
+//
+// Uses:
+//
+//	communicator    packersdk.Communicator
+//	qemu_disk_paths []string
+//	ui              packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type stepTrimDisk struct {
+	Command string
+}
+
+func (s *stepTrimDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	comm := state.Get("communicator").(packersdk.Communicator)
+	ui := state.Get("ui").(packersdk.Ui)
+	diskPaths := state.Get("qemu_disk_paths").([]string)
+
+	before, measured := disksAllocatedSize(diskPaths)
+
+	ui.Say("Discarding the free space of the guest...")
+	log.Printf("Executing trim command: %s", s.Command)
+	cmd := &packersdk.RemoteCmd{Command: s.Command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err := fmt.Errorf("Failed to run the trim command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	// Some file systems not supporting discard isn't worth failing the
+	// build for, the image is only larger.
+	if status := cmd.ExitStatus(); status != 0 {
+		ui.Error(fmt.Sprintf("The trim command exited with status %d, the free space may not be fully reclaimed", status))
+	}
+
+	after, measuredAfter := disksAllocatedSize(diskPaths)
+	if measured && measuredAfter {
+		ui.Say(fmt.Sprintf("Reclaimed %s of disk space", formatBytes(before-after)))
+	}
+
+	return multistep.ActionContinue
+}
+
+// disksAllocatedSize returns the space the disks take on the host, and
+// whether it could be measured.
+func disksAllocatedSize(paths []string) (int64, bool) {
+	var total int64
+	for _, path := range paths {
+		size, err := allocatedSize(path)
+		if err != nil {
+			log.Printf("Failed to measure the allocated size of %s: %s", path, err)
+			return 0, false
+		}
+		total += size
+	}
+	return total, true
+}
+
+// formatBytes formats a number of bytes with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit && n > -unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		value /= unit
+		if value < unit && value > -unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%.1f TiB", value/unit)
+}
+
+func (s *stepTrimDisk) Cleanup(state multistep.StateBag) {}
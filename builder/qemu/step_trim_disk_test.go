@@ -0,0 +1,69 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepTrimDisk(t *testing.T) {
+	disk := filepath.Join(t.TempDir(), "packer-foo")
+	if err := os.WriteFile(disk, []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		name       string
+		exitStatus int
+		expected   string
+	}{
+		{"trimmed", 0, "Reclaimed 0 B of disk space"},
+		{"partially trimmed", 64, "exited with status 64"},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			comm := &packersdk.MockCommunicator{StartExitStatus: c.exitStatus}
+			state := testState(t)
+			state.Put("communicator", comm)
+			state.Put("qemu_disk_paths", []string{disk})
+
+			step := &stepTrimDisk{Command: linuxTrimCommand}
+			if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+			}
+			if comm.StartCmd.Command != "fstrim -av" {
+				t.Fatalf("bad command: %s", comm.StartCmd.Command)
+			}
+			output := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+			if !strings.Contains(output, c.expected) {
+				t.Fatalf("the output should contain %q: %s", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tc := map[int64]string{
+		0:                  "0 B",
+		1023:               "1023 B",
+		1536:               "1.5 KiB",
+		5 * 1024 * 1024:    "5.0 MiB",
+		-3 * 1024 * 1024:   "-3.0 MiB",
+		3 << 40:            "3.0 TiB",
+		1024 * 1024 * 1024: "1.0 GiB",
+	}
+	for n, expected := range tc {
+		if s := formatBytes(n); s != expected {
+			t.Errorf("formatBytes(%d) = %q, expected %q", n, s, expected)
+		}
+	}
+}
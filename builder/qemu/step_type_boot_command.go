@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
@@ -85,9 +86,10 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 	}
 
 	// Connect to VNC
-	ui.Say(fmt.Sprintf("Connecting to VM via VNC (%s:%d)", vncIP, vncPort))
+	vncAddress := net.JoinHostPort(vncIP, strconv.Itoa(vncPort))
+	ui.Say(fmt.Sprintf("Connecting to VM via VNC (%s)", vncAddress))
 
-	nc, err := net.Dial("tcp", fmt.Sprintf("%s:%d", vncIP, vncPort))
+	nc, err := net.Dial("tcp", vncAddress)
 	if err != nil {
 		err := fmt.Errorf("Error connecting to VNC: %s", err)
 		state.Put("error", err)
@@ -119,7 +121,7 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 	SSHPublicKey := string(config.CommConfig.Comm.SSHPublicKey)
 	configCtx := config.ctx
 	configCtx.Data = &bootCommandTemplateData{
-		hostIP,
+		httpIPTemplateValue(hostIP),
 		httpPort,
 		config.VMName,
 		SSHPublicKey,
@@ -129,7 +131,16 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 
 	ui.Say("Typing the boot commands over VNC...")
 
-	for _, step := range bootSteps {
+	requestLog, _ := state.Get("http_request_log").(*httpRequestLog)
+	for i, step := range bootSteps {
+		if i > 0 && i == config.HTTPWaitForPathAfterStep && config.HTTPWaitForPath != "" && requestLog != nil {
+			if err := waitForHTTPPath(ctx, ui, requestLog, config.HTTPWaitForPath, config.HTTPWaitForPathTimeout); err != nil {
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
 		if len(step) == 0 {
 			continue
 		}
@@ -183,5 +194,26 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 		}
 	}
 
+	if requestLog != nil {
+		if config.HTTPWaitForPath != "" && config.HTTPWaitForPathAfterStep == len(bootSteps) {
+			if err := waitForHTTPPath(ctx, ui, requestLog, config.HTTPWaitForPath, config.HTTPWaitForPathTimeout); err != nil {
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+	}
+
 	return multistep.ActionContinue
 }
+
+func waitForHTTPPath(ctx context.Context, ui packersdk.Ui, requestLog *httpRequestLog, urlPath string, timeout time.Duration) error {
+	ui.Say(fmt.Sprintf("Waiting for the guest to fetch %s from the HTTP server...", urlPath))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := requestLog.WaitFor(ctx, urlPath); err != nil {
+		return fmt.Errorf("Error waiting for the guest to fetch %s: %s", urlPath, err)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -21,9 +22,22 @@ import (
 
 // This step waits for the guest address to become available in the network
 // bridge, then it sets the guestAddress state property.
+//
+// The address is looked up according to AddressSource. When it's unset, the
+// address is asked to the guest agent if it is enabled, and looked up in the
+// ARP table of the host otherwise.
 type stepWaitGuestAddress struct {
 	CommunicatorType string
+	Transport        string
+	AddressSource    string
+	DHCPLeasesFile   string
+	GuestAgent       bool
+	NetBackend       string
 	NetBridge        string
+	TapDevice        string
+	// NetDevice is the id of the netdev the communicator interface is
+	// attached to.
+	NetDevice string
 
 	timeout time.Duration
 }
@@ -35,18 +49,39 @@ func (s *stepWaitGuestAddress) Run(ctx context.Context, state multistep.StateBag
 		ui.Message("No communicator is configured -- skipping StepWaitGuestAddress")
 		return multistep.ActionContinue
 	}
-	if s.NetBridge == "" {
-		ui.Message("Not using a NetBridge -- skipping StepWaitGuestAddress")
+	if s.CommunicatorType == "qga" || s.CommunicatorType == "serial" {
+		ui.Message(fmt.Sprintf("The %s communicator doesn't use the network -- skipping StepWaitGuestAddress", s.CommunicatorType))
+		return multistep.ActionContinue
+	}
+	if s.Transport == "vsock" {
+		ui.Message("The communicator uses vsock -- skipping StepWaitGuestAddress")
+		return multistep.ActionContinue
+	}
+	if s.NetBackend == "user" {
+		ui.Message("Using user-mode networking -- skipping StepWaitGuestAddress")
+		return multistep.ActionContinue
+	}
+	if s.AddressSource == "static" {
+		ui.Message("Using a static guest address -- skipping StepWaitGuestAddress")
+		return multistep.ActionContinue
+	}
+	if s.AddressSource == "" && !s.GuestAgent && s.NetBackend != "bridge" && s.NetBackend != "tap" {
+		ui.Message("Not using a bridged network or the guest agent -- skipping StepWaitGuestAddress")
 		return multistep.ActionContinue
 	}
 
 	qmpMonitor := state.Get("qmp_monitor").(*qmp.SocketMonitor)
+	guestAgent, _ := state.Get("guest_agent").(*guestAgentClient)
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	ui.Say(fmt.Sprintf("Waiting for the guest address to become available in the %s network bridge...", s.NetBridge))
+	if s.NetBridge != "" {
+		ui.Say(fmt.Sprintf("Waiting for the guest address to become available in the %s network bridge...", s.NetBridge))
+	} else {
+		ui.Say("Waiting for the guest address to become available...")
+	}
 	for {
-		guestAddress := getGuestAddress(qmpMonitor, s.NetBridge, "user.0")
+		guestAddress := s.getGuestAddress(qmpMonitor, guestAgent)
 		if guestAddress != "" {
 			log.Printf("Found guest address %s", guestAddress)
 			state.Put("guestAddress", guestAddress)
@@ -64,22 +99,127 @@ func (s *stepWaitGuestAddress) Run(ctx context.Context, state multistep.StateBag
 func (s *stepWaitGuestAddress) Cleanup(state multistep.StateBag) {
 }
 
-func getGuestAddress(qmpMonitor *qmp.SocketMonitor, bridgeName string, deviceName string) string {
-	devices, err := getNetDevices(qmpMonitor)
+// hostInterface returns the name of the host interface the guest is
+// reachable through, which is the zone of the guest IPv6 link-local
+// addresses.
+func (s *stepWaitGuestAddress) hostInterface() string {
+	if s.NetBridge != "" {
+		return s.NetBridge
+	}
+	return s.TapDevice
+}
+
+func (s *stepWaitGuestAddress) getGuestAddress(qmpMonitor *qmp.SocketMonitor, guestAgent *guestAgentClient) string {
+	macAddress, err := getNetDeviceMACAddress(qmpMonitor, s.NetDevice)
 	if err != nil {
-		log.Printf("Could not retrieve QEMU QMP network device list: %v", err)
+		log.Printf("%s", err)
 		return ""
 	}
 
+	switch s.AddressSource {
+	case "arp":
+		return getNeighborGuestAddress(s.NetBridge, s.hostInterface(), macAddress)
+	case "dhcp_leases":
+		ipAddress, err := getDHCPLeaseIPAddress(s.DHCPLeasesFile, macAddress)
+		if err != nil {
+			log.Printf("%s", err)
+		}
+		return ipAddress
+	case "guest_agent":
+		return getGuestAgentAddress(guestAgent, s.hostInterface(), macAddress)
+	}
+
+	if guestAgent != nil {
+		if guestAddress := getGuestAgentAddress(guestAgent, s.hostInterface(), macAddress); guestAddress != "" {
+			return guestAddress
+		}
+	}
+
+	if s.NetBackend != "bridge" && s.NetBackend != "tap" {
+		return ""
+	}
+
+	return getNeighborGuestAddress(s.NetBridge, s.hostInterface(), macAddress)
+}
+
+// getNeighborGuestAddress looks up the guest address in the ARP table of the
+// host, then in its IPv6 neighbor table.
+func getNeighborGuestAddress(device string, zone string, macAddress string) string {
+	ipAddress, err := getDeviceIPAddress(device, macAddress)
+	if err == nil {
+		return ipAddress
+	}
+	log.Printf("%s", err)
+
+	addrs, err := getIPv6NeighborAddresses(device, macAddress)
+	if err != nil {
+		log.Printf("%s", err)
+		return ""
+	}
+	log.Printf("IPv6 neighbor table has addresses %v for %s", addrs, macAddress)
+	return selectGuestAddress(addrs, zone)
+}
+
+func getGuestAgentAddress(guestAgent *guestAgentClient, zone string, macAddress string) string {
+	if guestAgent == nil {
+		return ""
+	}
+	addrs, err := getGuestAgentAddresses(guestAgent, macAddress)
+	if err != nil {
+		log.Printf("Could not get the guest addresses from the guest agent: %v", err)
+		return ""
+	}
+	log.Printf("Guest agent reported addresses %v for %s", addrs, macAddress)
+	return selectGuestAddress(addrs, zone)
+}
+
+// getNetDeviceMACAddress returns the MAC address of the network device
+// attached to the given netdev.
+func getNetDeviceMACAddress(qmpMonitor *qmp.SocketMonitor, deviceName string) (string, error) {
+	devices, err := getNetDevices(qmpMonitor)
+	if err != nil {
+		return "", fmt.Errorf("Could not retrieve QEMU QMP network device list: %v", err)
+	}
+
 	for _, device := range devices {
 		if device.Name == deviceName {
-			ipAddress, _ := getDeviceIPAddress(bridgeName, device.MacAddress)
-			return ipAddress
+			return device.MacAddress, nil
 		}
 	}
 
-	log.Printf("QEMU QMP network device %s was not found", deviceName)
-	return ""
+	return "", fmt.Errorf("QEMU QMP network device %s was not found", deviceName)
+}
+
+// selectGuestAddress picks the address to reach the guest at, among the
+// addresses of its interface.
+//
+// IPv4 addresses are preferred, then global IPv6 addresses. IPv6 link-local
+// addresses are only picked as a last resort when the host interface to use
+// as their zone is known. Loopback and IPv4 link-local addresses are never
+// picked.
+func selectGuestAddress(addrs []net.IP, zone string) string {
+	var ipv6Address, linkLocalAddress string
+	for _, ip := range addrs {
+		if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			if zone != "" && linkLocalAddress == "" {
+				linkLocalAddress = ip.String() + "%" + zone
+			}
+			continue
+		}
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() || !ip.IsGlobalUnicast() {
+			continue
+		}
+		if ip.To4() != nil {
+			return ip.String()
+		}
+		if ipv6Address == "" {
+			ipv6Address = ip.String()
+		}
+	}
+	if ipv6Address != "" {
+		return ipv6Address
+	}
+	return linkLocalAddress
 }
 
 func getDeviceIPAddress(device string, macAddress string) (string, error) {
@@ -0,0 +1,40 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"errors"
+)
+
+const (
+	linuxTrimCommand   = "fstrim -av"
+	windowsTrimCommand = `powershell -NoProfile -Command "Get-Volume | Where-Object { $_.DriveLetter -and $_.DriveType -eq 'Fixed' } | ForEach-Object { Optimize-Volume -DriveLetter $_.DriveLetter -ReTrim -Verbose }"`
+)
+
+// prepareTrim checks the trim configuration and defaults the trim command
+// from the communicator.
+func (c *Config) prepareTrim() []error {
+	if !c.TrimBeforeShutdown {
+		if c.TrimCommand != "" {
+			return []error{errors.New("trim_command requires trim_before_shutdown")}
+		}
+		return nil
+	}
+
+	var errs []error
+	if c.CommConfig.Comm.Type == "none" {
+		errs = append(errs, errors.New("trim_before_shutdown requires a communicator"))
+	}
+	if c.DiskDiscard != "unmap" || c.DetectZeroes != "unmap" {
+		errs = append(errs, errors.New("trim_before_shutdown requires disk_discard and disk_detect_zeroes to be unmap"))
+	}
+
+	if c.TrimCommand == "" {
+		c.TrimCommand = linuxTrimCommand
+		if c.CommConfig.Comm.Type == "winrm" {
+			c.TrimCommand = windowsTrimCommand
+		}
+	}
+	return errs
+}
@@ -0,0 +1,113 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vcpuPinningKey is the generated data key of the host CPUs the vCPUs are
+// pinned to.
+const vcpuPinningKey = "VCPUPinning"
+
+// QemuTuningConfig gives the VM dedicated host resources, so the build
+// duration doesn't depend on what else runs on the host.
+type QemuTuningConfig struct {
+	// The host CPUs the vCPU threads are pinned to, as a list of CPUs and
+	// ranges like `taskset -c` takes, e.g. `2-5,8`. Once QEMU is started the
+	// vCPUs are pinned in order to the CPUs of the list, one CPU each,
+	// wrapping around when there are fewer CPUs than vCPUs. The host CPUs
+	// must be allowed to the Packer process, e.g. by its cgroup.
+	//
+	// The assignment is available as `VCPUPinning` in the generated data, in
+	// the `vcpu=cpu,...` form. This requires a Linux host, and enables the
+	// QMP socket (see `qmp_enable`) to find the vCPU threads.
+	VCPUPinning string `mapstructure:"vcpu_pinning" required:"false"`
+	// The number of dedicated I/O threads of the disks, so the disk I/O
+	// doesn't run in the main QEMU thread. The virtio disks are assigned to
+	// the threads in turn. This requires `disk_interface` to be `virtio` or
+	// `virtio-scsi`; the virtio-scsi disks share a controller, which uses a
+	// single I/O thread.
+	IOThreads int `mapstructure:"iothreads" required:"false"`
+}
+
+// prepareTuning checks the tuning configuration, once the disk interface
+// is set.
+func (c *Config) prepareTuning() []error {
+	var errs []error
+
+	if c.VCPUPinning != "" {
+		cpus, err := parseCPUList(c.VCPUPinning)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vcpu_pinning: %s", err))
+		} else if err := checkHostCPUs(cpus); err != nil {
+			errs = append(errs, fmt.Errorf("vcpu_pinning: %s", err))
+		}
+		c.QMPEnable = true
+	}
+
+	if c.IOThreads < 0 {
+		errs = append(errs, errors.New("iothreads must be positive"))
+	} else if c.IOThreads > 0 {
+		switch c.DiskInterface {
+		case "virtio":
+		case "virtio-scsi":
+			if c.IOThreads > 1 {
+				errs = append(errs, errors.New("iothreads must be 1 with the virtio-scsi disk_interface, the disks share a controller"))
+			}
+		default:
+			errs = append(errs, errors.New("iothreads requires the virtio or virtio-scsi disk_interface"))
+		}
+	}
+
+	return errs
+}
+
+// vcpuHostCPUs returns the host CPUs of vcpu_pinning, once validated.
+func (c *Config) vcpuHostCPUs() []int {
+	cpus, _ := parseCPUList(c.VCPUPinning)
+	return cpus
+}
+
+// parseCPUList parses a list of CPUs and CPU ranges, like "0-3,8".
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, item := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", item)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU range %q", item)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// ioThreadID returns the id of the I/O thread of the disk at index.
+func (c *Config) ioThreadID(index int) string {
+	return fmt.Sprintf("iothread%d", index%c.IOThreads)
+}
+
+// ioThreadArgs returns the -object arguments of the I/O threads.
+func (c *Config) ioThreadArgs() []string {
+	var args []string
+	for i := 0; i < c.IOThreads; i++ {
+		args = append(args, fmt.Sprintf("iothread,id=%s", c.ioThreadID(i)))
+	}
+	return args
+}
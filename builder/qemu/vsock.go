@@ -0,0 +1,104 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// errVsockCIDInUse is returned by checkVsockCID when another VM of the host
+// uses the CID.
+var errVsockCIDInUse = errors.New("CID is in use")
+
+// probeVsockCID checks that a guest CID is free on the host, it's replaced
+// in tests.
+var probeVsockCID = checkVsockCID
+
+// vsockCIDLock holds a guest CID for the duration of a build. Like the
+// communicator host ports, CIDs are locked with a file in the Packer cache
+// so that concurrent builds don't pick the same one.
+type vsockCIDLock struct {
+	CID uint32
+
+	lock *filelock.Flock
+	path string
+}
+
+func (l *vsockCIDLock) Close() error {
+	if err := l.lock.Unlock(); err != nil {
+		log.Printf("cannot unlock lockfile %s: %v", l.path, err)
+	}
+	return os.Remove(l.path)
+}
+
+// allocateVsockCID finds a guest CID between min and max that is neither
+// locked by another build nor used by a running VM, starting at a random
+// CID of the range.
+func allocateVsockCID(ctx context.Context, min, max int) (*vsockCIDLock, error) {
+	size := max - min + 1
+	start := rand.Intn(size)
+	for i := 0; i < size; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		cid := min + (start+i)%size
+		lockFilePath, err := packersdk.CachePath("vsock-cid", strconv.Itoa(cid))
+		if err != nil {
+			return nil, err
+		}
+
+		lock := filelock.New(lockFilePath)
+		locked, err := lock.TryLock()
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			log.Printf("[DEBUG] vsock CID %d is file locked", cid)
+			continue
+		}
+
+		if err := probeVsockCID(uint32(cid)); err != nil {
+			log.Printf("[DEBUG] vsock CID %d can't be used: %s", cid, err)
+			if err := lock.Unlock(); err != nil {
+				log.Printf("cannot unlock lockfile %s: %v", lockFilePath, err)
+			}
+			if errors.Is(err, errVsockCIDInUse) {
+				continue
+			}
+			return nil, err
+		}
+
+		log.Printf("Found available vsock CID: %d", cid)
+		return &vsockCIDLock{
+			CID:  uint32(cid),
+			lock: lock,
+			path: lockFilePath,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no vsock CID is available between %d and %d", min, max)
+}
+
+// vsockAddr is the address of an AF_VSOCK socket.
+type vsockAddr struct {
+	CID  uint32
+	Port uint32
+}
+
+var _ net.Addr = vsockAddr{}
+
+func (a vsockAddr) Network() string { return "vsock" }
+
+func (a vsockAddr) String() string { return fmt.Sprintf("%d:%d", a.CID, a.Port) }
@@ -0,0 +1,93 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package qemu
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// See include/uapi/linux/vhost.h.
+const (
+	vhostSetOwner         = 0xaf01     // _IO(VHOST_VIRTIO, 0x01)
+	vhostVsockSetGuestCID = 0x4008af60 // _IOW(VHOST_VIRTIO, 0x60, __u64)
+)
+
+// checkVsockCID tells whether a guest CID can be used by the VM, by
+// assigning it on a vhost-vsock device like QEMU does. The CID is released
+// when the device is closed.
+func checkVsockCID(cid uint32) error {
+	fd, err := unix.Open("/dev/vhost-vsock", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("could not open /dev/vhost-vsock, is the vhost_vsock module loaded? %w", err)
+	}
+	defer unix.Close(fd)
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), vhostSetOwner, 0); errno != 0 {
+		return fmt.Errorf("VHOST_SET_OWNER failed: %w", errno)
+	}
+
+	guestCID := uint64(cid)
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), vhostVsockSetGuestCID, uintptr(unsafe.Pointer(&guestCID)))
+	if errno == unix.EADDRINUSE {
+		return errVsockCIDInUse
+	}
+	if errno != 0 {
+		return fmt.Errorf("VHOST_VSOCK_SET_GUEST_CID failed: %w", errno)
+	}
+	return nil
+}
+
+// vsockConn is a connected AF_VSOCK socket. The net package doesn't know
+// about AF_VSOCK, the socket is used through a non-blocking os.File, which
+// supports deadlines.
+type vsockConn struct {
+	*os.File
+
+	local  vsockAddr
+	remote vsockAddr
+}
+
+var _ net.Conn = &vsockConn{}
+
+func (c *vsockConn) LocalAddr() net.Addr  { return c.local }
+func (c *vsockConn) RemoteAddr() net.Addr { return c.remote }
+
+// dialVsock connects to the port of the VM with the guest CID.
+func dialVsock(cid, port uint32) (net.Conn, error) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not create vsock socket: %w", err)
+	}
+
+	remote := vsockAddr{CID: cid, Port: port}
+	if err := unix.Connect(fd, &unix.SockaddrVM{CID: cid, Port: port}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("could not connect to vsock %s: %w", remote, err)
+	}
+
+	var local vsockAddr
+	if sa, err := unix.Getsockname(fd); err == nil {
+		if vm, ok := sa.(*unix.SockaddrVM); ok {
+			local = vsockAddr{CID: vm.CID, Port: vm.Port}
+		}
+	}
+
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("could not set vsock socket non-blocking: %w", err)
+	}
+
+	return &vsockConn{
+		File:   os.NewFile(uintptr(fd), "vsock:"+remote.String()),
+		local:  local,
+		remote: remote,
+	}, nil
+}
@@ -0,0 +1,19 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !linux
+
+package qemu
+
+import (
+	"errors"
+	"net"
+)
+
+func checkVsockCID(cid uint32) error {
+	return errors.New("vsock is only supported on Linux hosts")
+}
+
+func dialVsock(cid, port uint32) (net.Conn, error) {
+	return nil, errors.New("vsock is only supported on Linux hosts")
+}
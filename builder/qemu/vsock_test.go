@@ -0,0 +1,51 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package qemu
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAllocateVsockCID(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+
+	inUse := map[uint32]bool{3: true, 5: true}
+	probeVsockCID = func(cid uint32) error {
+		if inUse[cid] {
+			return errVsockCIDInUse
+		}
+		return nil
+	}
+	defer func() { probeVsockCID = checkVsockCID }()
+
+	l, err := allocateVsockCID(context.Background(), 3, 5)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if l.CID != 4 {
+		t.Fatalf("expected CID 4, got %d", l.CID)
+	}
+
+	// The CID is locked until the lock is closed.
+	if _, err := allocateVsockCID(context.Background(), 3, 5); err == nil {
+		t.Fatal("should have error when all CIDs are locked or in use")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	l, err = allocateVsockCID(context.Background(), 3, 5)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	l.Close()
+
+	probeVsockCID = func(uint32) error {
+		return errors.New("could not open /dev/vhost-vsock")
+	}
+	if _, err := allocateVsockCID(context.Background(), 3, 5); err == nil {
+		t.Fatal("should have error when vhost-vsock is not available")
+	}
+}